@@ -0,0 +1,489 @@
+package compose
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// File 是 docker-compose v3 文件中被支持的子集
+type File struct {
+	Version  string              `yaml:"version"`
+	Services map[string]*Service `yaml:"services"`
+	Networks map[string]*Network `yaml:"networks"`
+	Volumes  map[string]*Volume  `yaml:"volumes"`
+}
+
+type Service struct {
+	Image       string          `yaml:"image"`
+	Build       *Build          `yaml:"build"`
+	Command     Command         `yaml:"command"`
+	WorkingDir  string          `yaml:"working_dir"`
+	Environment Environment     `yaml:"environment"`
+	Ports       []Port          `yaml:"ports"`
+	Volumes     []ServiceVolume `yaml:"volumes"`
+	DependsOn   DependsOn       `yaml:"depends_on"`
+	HealthCheck *HealthCheck    `yaml:"healthcheck"`
+	Networks    ServiceNetworks `yaml:"networks"`
+}
+
+type Network struct {
+	Driver string `yaml:"driver"`
+}
+
+type Volume struct {
+	Driver string `yaml:"driver"`
+}
+
+// Build 支持 `build: ./dir` 与 `build: {context: ./dir, dockerfile: Dockerfile}` 两种写法
+type Build struct {
+	Context    string `yaml:"context"`
+	Dockerfile string `yaml:"dockerfile"`
+}
+
+func (b *Build) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var ctx string
+	if err := unmarshal(&ctx); err == nil {
+		b.Context = ctx
+		return nil
+	}
+	type plain Build
+	return unmarshal((*plain)(b))
+}
+
+// Command 支持字符串与列表两种写法，字符串按 shell 规则拆分
+type Command []string
+
+func (c *Command) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		words, err := splitWords(s)
+		if err != nil {
+			return err
+		}
+		*c = words
+		return nil
+	}
+	var list []string
+	if err := unmarshal(&list); err != nil {
+		return err
+	}
+	*c = list
+	return nil
+}
+
+// Environment 支持 `[K=V]` 与 `{K: V}` 两种写法
+type Environment map[string]string
+
+func (e *Environment) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	env := make(Environment)
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		for _, kv := range list {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				env[parts[0]] = parts[1]
+			} else {
+				env[parts[0]] = ""
+			}
+		}
+		*e = env
+		return nil
+	}
+	var m map[string]interface{}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for k, v := range m {
+		if v == nil {
+			env[k] = ""
+		} else {
+			env[k] = fmt.Sprint(v)
+		}
+	}
+	*e = env
+	return nil
+}
+
+// Port 支持 "[ip:][host:]container[/protocol]" 与长格式两种写法
+type Port struct {
+	HostIP    string `yaml:"host_ip"`
+	Published string `yaml:"published"`
+	Target    string `yaml:"target"`
+	Protocol  string `yaml:"protocol"`
+}
+
+func (p *Port) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err == nil {
+		return p.parse(s)
+	}
+	var long struct {
+		HostIP    string      `yaml:"host_ip"`
+		Published interface{} `yaml:"published"`
+		Target    interface{} `yaml:"target"`
+		Protocol  string      `yaml:"protocol"`
+	}
+	if err := unmarshal(&long); err != nil {
+		return err
+	}
+	if long.Target == nil {
+		return fmt.Errorf("port target is required")
+	}
+	p.HostIP = long.HostIP
+	p.Target = fmt.Sprint(long.Target)
+	if long.Published != nil {
+		p.Published = fmt.Sprint(long.Published)
+	}
+	p.Protocol = long.Protocol
+	return nil
+}
+
+func (p *Port) parse(s string) error {
+	spec := s
+	if i := strings.LastIndex(spec, "/"); i >= 0 {
+		p.Protocol = spec[i+1:]
+		spec = spec[:i]
+	}
+	parts := strings.Split(spec, ":")
+	switch len(parts) {
+	case 1:
+		p.Target = parts[0]
+	case 2:
+		p.Published, p.Target = parts[0], parts[1]
+	case 3:
+		p.HostIP, p.Published, p.Target = parts[0], parts[1], parts[2]
+	default:
+		return fmt.Errorf("invalid port %q", s)
+	}
+	if _, err := strconv.ParseUint(p.Target, 10, 16); err != nil {
+		return fmt.Errorf("invalid port %q", s)
+	}
+	return nil
+}
+
+// ServiceVolume 支持 "source:target[:ro]" 与长格式两种写法。
+// source 以 .、/ 或 ~ 开头时为 bind 挂载，否则为顶层 volumes 中声明的命名卷；
+// 长格式中 type 可以为 bind、volume 或 tmpfs
+type ServiceVolume struct {
+	Type     string `yaml:"type"`
+	Source   string `yaml:"source"`
+	Target   string `yaml:"target"`
+	ReadOnly bool   `yaml:"read_only"`
+}
+
+func (v *ServiceVolume) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var s string
+	if err := unmarshal(&s); err != nil {
+		type plain ServiceVolume
+		if err := unmarshal((*plain)(v)); err != nil {
+			return err
+		}
+		if v.Type == "" {
+			v.Type = volumeType(v.Source)
+		}
+		return nil
+	}
+
+	parts := strings.Split(s, ":")
+	switch len(parts) {
+	case 1:
+		// 只有 target 时为匿名卷
+		v.Type, v.Target = "volume", parts[0]
+		return nil
+	case 2, 3:
+		v.Source, v.Target = parts[0], parts[1]
+		v.Type = volumeType(v.Source)
+		if len(parts) == 3 {
+			switch parts[2] {
+			case "ro":
+				v.ReadOnly = true
+			case "rw":
+			default:
+				return fmt.Errorf("invalid volume mode %q in %q", parts[2], s)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid volume %q", s)
+	}
+}
+
+func volumeType(source string) string {
+	if strings.HasPrefix(source, ".") || strings.HasPrefix(source, "/") || strings.HasPrefix(source, "~") {
+		return "bind"
+	}
+	return "volume"
+}
+
+const (
+	ConditionStarted = "service_started"
+	ConditionHealthy = "service_healthy"
+)
+
+// DependsOn 支持列表与 `{svc: {condition: ...}}` 两种写法。
+// 列表写法下，如果依赖的服务定义了健康检查，则等待其健康后再启动
+type DependsOn map[string]string
+
+func (d *DependsOn) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	deps := make(DependsOn)
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		for _, name := range list {
+			deps[name] = ""
+		}
+		*d = deps
+		return nil
+	}
+	var m map[string]struct {
+		Condition string `yaml:"condition"`
+	}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for name, dep := range m {
+		switch dep.Condition {
+		case "", ConditionStarted, ConditionHealthy:
+		default:
+			return fmt.Errorf("unsupported depends_on condition %q", dep.Condition)
+		}
+		deps[name] = dep.Condition
+	}
+	*d = deps
+	return nil
+}
+
+type HealthCheck struct {
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	Retries     int
+	StartPeriod time.Duration
+	Disable     bool
+}
+
+func (h *HealthCheck) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var raw struct {
+		Test        interface{} `yaml:"test"`
+		Interval    string      `yaml:"interval"`
+		Timeout     string      `yaml:"timeout"`
+		Retries     int         `yaml:"retries"`
+		StartPeriod string      `yaml:"start_period"`
+		Disable     bool        `yaml:"disable"`
+	}
+	if err := unmarshal(&raw); err != nil {
+		return err
+	}
+	switch test := raw.Test.(type) {
+	case nil:
+	case string:
+		h.Test = []string{"CMD-SHELL", test}
+	case []interface{}:
+		for _, t := range test {
+			h.Test = append(h.Test, fmt.Sprint(t))
+		}
+	default:
+		return fmt.Errorf("invalid healthcheck test %v", raw.Test)
+	}
+
+	var err error
+	for _, d := range []struct {
+		s   string
+		dst *time.Duration
+	}{
+		{raw.Interval, &h.Interval},
+		{raw.Timeout, &h.Timeout},
+		{raw.StartPeriod, &h.StartPeriod},
+	} {
+		if d.s == "" {
+			continue
+		}
+		if *d.dst, err = time.ParseDuration(d.s); err != nil {
+			return fmt.Errorf("invalid healthcheck duration %q: %w", d.s, err)
+		}
+	}
+	h.Retries = raw.Retries
+	h.Disable = raw.Disable || (len(h.Test) > 0 && h.Test[0] == "NONE")
+	return nil
+}
+
+// ServiceNetworks 支持列表与 `{net: {aliases: [...]}}` 两种写法，value 为别名
+type ServiceNetworks map[string][]string
+
+func (n *ServiceNetworks) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	networks := make(ServiceNetworks)
+	var list []string
+	if err := unmarshal(&list); err == nil {
+		for _, name := range list {
+			networks[name] = nil
+		}
+		*n = networks
+		return nil
+	}
+	var m map[string]*struct {
+		Aliases []string `yaml:"aliases"`
+	}
+	if err := unmarshal(&m); err != nil {
+		return err
+	}
+	for name, net := range m {
+		if net != nil {
+			networks[name] = net.Aliases
+		} else {
+			networks[name] = nil
+		}
+	}
+	*n = networks
+	return nil
+}
+
+// Load 读取并解析 compose 文件
+func Load(path string) (*File, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse 解析 compose 文件内容，并检查服务之间的引用是否合法
+func Parse(data []byte) (*File, error) {
+	f := &File{}
+	if err := yaml.Unmarshal(data, f); err != nil {
+		return nil, err
+	}
+	if err := f.validate(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *File) validate() error {
+	if len(f.Services) <= 0 {
+		return fmt.Errorf("no services defined")
+	}
+	for name, svc := range f.Services {
+		if svc == nil {
+			return fmt.Errorf("service %s: empty definition", name)
+		}
+		if svc.Image == "" && svc.Build == nil {
+			return fmt.Errorf("service %s: image or build is required", name)
+		}
+		for dep := range svc.DependsOn {
+			if _, ok := f.Services[dep]; !ok {
+				return fmt.Errorf("service %s: depends on undefined service %s", name, dep)
+			}
+		}
+		for net := range svc.Networks {
+			if _, ok := f.Networks[net]; !ok && net != defaultNetwork {
+				return fmt.Errorf("service %s: refers to undefined network %s", name, net)
+			}
+		}
+		for _, v := range svc.Volumes {
+			if v.Target == "" {
+				return fmt.Errorf("service %s: volume target is required", name)
+			}
+			switch v.Type {
+			case "bind":
+			case "volume":
+				if _, ok := f.Volumes[v.Source]; v.Source != "" && !ok {
+					return fmt.Errorf("service %s: refers to undefined volume %s", name, v.Source)
+				}
+			case "tmpfs":
+				if v.Source != "" {
+					return fmt.Errorf("service %s: tmpfs volume %s cannot have a source", name, v.Target)
+				}
+			default:
+				return fmt.Errorf("service %s: unsupported volume type %q", name, v.Type)
+			}
+		}
+	}
+	_, err := f.startOrder()
+	return err
+}
+
+// startOrder 按 depends_on 对服务做拓扑排序，同一层级的服务按名称排序
+func (f *File) startOrder() ([]string, error) {
+	inDegree := make(map[string]int, len(f.Services))
+	dependents := make(map[string][]string)
+	for name, svc := range f.Services {
+		inDegree[name] += 0
+		for dep := range svc.DependsOn {
+			inDegree[name]++
+			dependents[dep] = append(dependents[dep], name)
+		}
+	}
+
+	var ready []string
+	for name, degree := range inDegree {
+		if degree == 0 {
+			ready = append(ready, name)
+		}
+	}
+	var order []string
+	for len(ready) > 0 {
+		sort.Strings(ready)
+		name := ready[0]
+		ready = ready[1:]
+		order = append(order, name)
+		for _, dependent := range dependents[name] {
+			inDegree[dependent]--
+			if inDegree[dependent] == 0 {
+				ready = append(ready, dependent)
+			}
+		}
+	}
+	if len(order) != len(f.Services) {
+		return nil, fmt.Errorf("circular dependency between services")
+	}
+	return order, nil
+}
+
+// splitWords 按 shell 的规则拆分命令，支持单引号、双引号与反斜杠转义
+func splitWords(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range s {
+		switch {
+		case escaped:
+			word.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inWord = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote, inWord = r, true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote in command %q", s)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,277 @@
+package compose
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+)
+
+const stackYAML = `
+version: "3.8"
+services:
+  app:
+    build:
+      context: ./app
+      dockerfile: Dockerfile.dev
+    command: ./server --addr ":8080" --name 'demo app'
+    environment:
+      - DB_HOST=db
+      - DEBUG
+    ports:
+      - "8080:8080"
+      - 127.0.0.1:9090:9090/udp
+      - target: 7000
+        published: 17000
+    volumes:
+      - ./config:/etc/app:ro
+      - data:/var/lib/app
+      - type: tmpfs
+        target: /tmp
+    depends_on:
+      - db
+      - cache
+    networks:
+      front:
+        aliases: [web]
+      back:
+  db:
+    image: postgres:13
+    environment:
+      POSTGRES_PASSWORD: secret
+      POSTGRES_PORT: 5432
+    healthcheck:
+      test: pg_isready -U postgres
+      interval: 5s
+      timeout: 3s
+      retries: 5
+      start_period: 10s
+    networks: [back]
+  cache:
+    image: redis:6
+    depends_on:
+      db:
+        condition: service_healthy
+    networks: [back]
+networks:
+  front:
+  back:
+    driver: bridge
+volumes:
+  data:
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(stackYAML))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	app := f.Services["app"]
+	if app.Build.Context != "./app" || app.Build.Dockerfile != "Dockerfile.dev" {
+		t.Errorf("Build = %+v", app.Build)
+	}
+	if want := (Command{"./server", "--addr", ":8080", "--name", "demo app"}); !reflect.DeepEqual(app.Command, want) {
+		t.Errorf("Command = %q, want %q", app.Command, want)
+	}
+	if want := (Environment{"DB_HOST": "db", "DEBUG": ""}); !reflect.DeepEqual(app.Environment, want) {
+		t.Errorf("Environment = %v, want %v", app.Environment, want)
+	}
+	wantPorts := []Port{
+		{Published: "8080", Target: "8080"},
+		{HostIP: "127.0.0.1", Published: "9090", Target: "9090", Protocol: "udp"},
+		{Published: "17000", Target: "7000"},
+	}
+	if !reflect.DeepEqual(app.Ports, wantPorts) {
+		t.Errorf("Ports = %+v, want %+v", app.Ports, wantPorts)
+	}
+	wantVolumes := []ServiceVolume{
+		{Type: "bind", Source: "./config", Target: "/etc/app", ReadOnly: true},
+		{Type: "volume", Source: "data", Target: "/var/lib/app"},
+		{Type: "tmpfs", Target: "/tmp"},
+	}
+	if !reflect.DeepEqual(app.Volumes, wantVolumes) {
+		t.Errorf("Volumes = %+v, want %+v", app.Volumes, wantVolumes)
+	}
+	if want := (ServiceNetworks{"front": {"web"}, "back": nil}); !reflect.DeepEqual(app.Networks, want) {
+		t.Errorf("Networks = %v, want %v", app.Networks, want)
+	}
+
+	db := f.Services["db"]
+	if want := (Environment{"POSTGRES_PASSWORD": "secret", "POSTGRES_PORT": "5432"}); !reflect.DeepEqual(db.Environment, want) {
+		t.Errorf("Environment = %v, want %v", db.Environment, want)
+	}
+	wantHC := &HealthCheck{
+		Test:        []string{"CMD-SHELL", "pg_isready -U postgres"},
+		Interval:    5 * time.Second,
+		Timeout:     3 * time.Second,
+		Retries:     5,
+		StartPeriod: 10 * time.Second,
+	}
+	if !reflect.DeepEqual(db.HealthCheck, wantHC) {
+		t.Errorf("HealthCheck = %+v, want %+v", db.HealthCheck, wantHC)
+	}
+
+	if want := (DependsOn{"db": ConditionHealthy}); !reflect.DeepEqual(f.Services["cache"].DependsOn, want) {
+		t.Errorf("DependsOn = %v, want %v", f.Services["cache"].DependsOn, want)
+	}
+
+	order, err := f.startOrder()
+	if err != nil {
+		t.Fatalf("startOrder() error = %v", err)
+	}
+	if want := []string{"db", "cache", "app"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("startOrder() = %v, want %v", order, want)
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{
+			name: "no-services",
+			yaml: `version: "3"`,
+		},
+		{
+			name: "no-image",
+			yaml: `
+services:
+  app:
+    command: ls`,
+		},
+		{
+			name: "undefined-dependency",
+			yaml: `
+services:
+  app:
+    image: busybox
+    depends_on: [db]`,
+		},
+		{
+			name: "circular-dependency",
+			yaml: `
+services:
+  a:
+    image: busybox
+    depends_on: [b]
+  b:
+    image: busybox
+    depends_on: [a]`,
+		},
+		{
+			name: "undefined-volume",
+			yaml: `
+services:
+  app:
+    image: busybox
+    volumes: ["data:/data"]`,
+		},
+		{
+			name: "tmpfs-with-source",
+			yaml: `
+services:
+  app:
+    image: busybox
+    volumes:
+      - type: tmpfs
+        source: data
+        target: /data
+volumes:
+  data:`,
+		},
+		{
+			name: "unsupported-volume-type",
+			yaml: `
+services:
+  app:
+    image: busybox
+    volumes:
+      - type: npipe
+        source: //./pipe/docker_engine
+        target: //./pipe/docker_engine`,
+		},
+		{
+			name: "undefined-network",
+			yaml: `
+services:
+  app:
+    image: busybox
+    networks: [front]`,
+		},
+		{
+			name: "invalid-port",
+			yaml: `
+services:
+  app:
+    image: busybox
+    ports: ["http"]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tt.yaml)); err == nil {
+				t.Errorf("Parse() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestFile_Up_rollback(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.41")
+		if r.URL.Path == "/_ping" {
+			return
+		}
+		path := r.URL.Path[strings.Index(r.URL.Path[1:], "/")+1:]
+		mu.Lock()
+		requests = append(requests, r.Method+" "+path)
+		mu.Unlock()
+		switch {
+		case r.Method == http.MethodPost && path == "/networks/create":
+			var body struct{ Name string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			if body.Name == "demo_front" {
+				// 同名项目已经在运行，网络已存在
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"message":"network with name demo_front already exists"}`))
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"Id":"created-by-this-up"}`))
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	opt := &UpOption{HostURL: "tcp://" + server.Listener.Addr().String(), ProjectName: "demo"}
+	defer func() { _ = docker.Evict(&docker.InitOption{Host: opt.HostURL}) }()
+
+	f, err := Parse([]byte("services:\n  web:\n    image: nginx\n    networks: [front]\nnetworks:\n  front:\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Up(context.Background(), opt); err == nil {
+		t.Fatalf("Up() should fail when the network already exists")
+	}
+
+	// 只移除本次创建的网络，不按 label 清理已经在运行的同名项目
+	want := []string{"POST /networks/create", "POST /networks/create", "DELETE /networks/created-by-this-up"}
+	if !reflect.DeepEqual(requests, want) {
+		t.Errorf("requests = %v, want %v", requests, want)
+	}
+}
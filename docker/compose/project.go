@@ -0,0 +1,377 @@
+package compose
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+	"github.com/loheagn/loclo/docker/container"
+	"github.com/loheagn/loclo/docker/image"
+	"github.com/loheagn/loclo/docker/network"
+	"github.com/loheagn/loclo/docker/volume"
+)
+
+const (
+	LabelProject = "loclo.compose.project"
+	LabelService = "loclo.compose.service"
+
+	DefaultHealthTimeout = 2 * time.Minute
+
+	defaultNetwork = "default"
+)
+
+type UpOption struct {
+	HostURL     string
+	ProjectName string
+	// WorkDir 用于解析 build context 与 bind 挂载中的相对路径
+	WorkDir string
+	// HealthTimeout 等待单个服务变为健康状态的最长时间
+	HealthTimeout time.Duration
+	// Owner 会记录在项目创建的所有容器、网络与数据卷上
+	Owner docker.Ownership
+}
+
+// Project 是一组已经启动的服务
+type Project struct {
+	Name string
+	// Containers 记录每个服务对应的容器 ID
+	Containers map[string]string
+
+	cli *docker.Client
+}
+
+type projectRunner struct {
+	file    *File
+	opt     *UpOption
+	cli     *docker.Client
+	project *Project
+	labels  map[string]string
+	// networks 记录本次 Up 创建的网络 ID，失败时只清理这些网络
+	networks []string
+}
+
+// Up 依次创建网络、数据卷，构建镜像，并按依赖顺序启动所有服务。
+// 任何一步失败都会移除本次创建的容器与网络，同名项目中已经存在的资源不受影响，数据卷会被保留
+func (f *File) Up(ctx context.Context, opt *UpOption) (project *Project, err error) {
+	if opt.ProjectName == "" {
+		return nil, fmt.Errorf("project name is required")
+	}
+	if opt.HealthTimeout <= 0 {
+		opt.HealthTimeout = DefaultHealthTimeout
+	}
+	cli, err := docker.GetClient(ctx, &docker.InitOption{
+		Host: opt.HostURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	r := &projectRunner{
+		file: f,
+		opt:  opt,
+		cli:  cli,
+		project: &Project{
+			Name:       opt.ProjectName,
+			Containers: make(map[string]string),
+			cli:        cli,
+		},
+		labels: map[string]string{LabelProject: opt.ProjectName},
+	}
+	defer func() {
+		if err != nil {
+			_ = r.rollback(context.Background())
+		}
+	}()
+
+	if err = r.createNetworks(ctx); err != nil {
+		return nil, err
+	}
+	if err = r.createVolumes(ctx); err != nil {
+		return nil, err
+	}
+	if err = r.buildImages(ctx); err != nil {
+		return nil, err
+	}
+
+	order, err := f.startOrder()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range order {
+		if err = r.startService(ctx, name); err != nil {
+			return nil, fmt.Errorf("start service %s: %w", name, err)
+		}
+	}
+	return r.project, nil
+}
+
+func (r *projectRunner) resourceName(name string) string {
+	return fmt.Sprintf("%s_%s", r.opt.ProjectName, name)
+}
+
+func (r *projectRunner) createNetworks(ctx context.Context) error {
+	cli := network.NewClient(r.cli)
+	names := []string{defaultNetwork}
+	for name := range r.file.Networks {
+		if name != defaultNetwork {
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		opt := &network.CreateOption{
+			Name:   r.resourceName(name),
+			Labels: r.labels,
+			Owner:  r.opt.Owner,
+		}
+		if n := r.file.Networks[name]; n != nil {
+			opt.Driver = n.Driver
+		}
+		id, err := cli.Create(ctx, opt)
+		if err != nil {
+			return fmt.Errorf("create network %s: %w", name, err)
+		}
+		r.networks = append(r.networks, id)
+	}
+	return nil
+}
+
+// rollback 移除本次 Up 创建的容器与网络。不能使用 Down，它会按 label 移除同名项目中已有的资源
+func (r *projectRunner) rollback(ctx context.Context) error {
+	var errs []string
+	containerCli := container.NewClient(r.cli)
+	for _, id := range r.project.Containers {
+		if err := containerCli.Remove(ctx, id); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	networkCli := network.NewClient(r.cli)
+	for _, id := range r.networks {
+		if err := networkCli.Remove(ctx, id); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("clean up project %s: %s", r.opt.ProjectName, strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func (r *projectRunner) createVolumes(ctx context.Context) error {
+	cli := volume.NewClient(r.cli)
+	for name, v := range r.file.Volumes {
+		opt := &volume.CreateOption{
+			Name:   r.resourceName(name),
+			Labels: r.labels,
+			Owner:  r.opt.Owner,
+		}
+		if v != nil {
+			opt.Driver = v.Driver
+		}
+		if _, err := cli.Create(ctx, opt); err != nil {
+			return fmt.Errorf("create volume %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// imageTag 返回服务使用的镜像，需要构建且未指定 image 时使用 <project>_<service>
+func (r *projectRunner) imageTag(name string) string {
+	if svc := r.file.Services[name]; svc.Image != "" {
+		return svc.Image
+	}
+	return r.resourceName(name)
+}
+
+func (r *projectRunner) buildImages(ctx context.Context) error {
+	cli := image.NewClient(r.cli)
+	for name, svc := range r.file.Services {
+		if svc.Build == nil {
+			continue
+		}
+		dockerfile := svc.Build.Dockerfile
+		if dockerfile == "" {
+			dockerfile = "Dockerfile"
+		}
+		output, err := cli.Build(ctx, &image.BuildOption{
+			DockerFilePath: dockerfile,
+			CtxPath:        r.resolvePath(svc.Build.Context),
+			Tags:           []string{r.imageTag(name)},
+		})
+		if err != nil {
+			return fmt.Errorf("build service %s: %w\n%s", name, err, output)
+		}
+	}
+	return nil
+}
+
+func (r *projectRunner) resolvePath(path string) string {
+	if strings.HasPrefix(path, "~") {
+		home, _ := os.UserHomeDir()
+		path = filepath.Join(home, path[1:])
+	}
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.opt.WorkDir, path)
+}
+
+func (r *projectRunner) startService(ctx context.Context, name string) error {
+	svc := r.file.Services[name]
+
+	// 等待依赖的服务就绪
+	for dep, condition := range svc.DependsOn {
+		depSvc := r.file.Services[dep]
+		hasHealthCheck := depSvc.HealthCheck != nil && !depSvc.HealthCheck.Disable
+		if condition == ConditionHealthy && !hasHealthCheck {
+			return fmt.Errorf("dependency %s has no healthcheck", dep)
+		}
+		if condition == ConditionHealthy || (condition == "" && hasHealthCheck) {
+			if err := r.waitHealthy(ctx, dep); err != nil {
+				return fmt.Errorf("wait for %s: %w", dep, err)
+			}
+		}
+	}
+
+	labels := map[string]string{LabelService: name}
+	for k, v := range r.labels {
+		labels[k] = v
+	}
+	opt := &container.StartOption{
+		RunOption: container.RunOption{
+			Image:   r.imageTag(name),
+			Cmd:     svc.Command,
+			Envs:    svc.Environment,
+			WorkDir: svc.WorkingDir,
+			Owner:   r.opt.Owner,
+		},
+		Name:     r.resourceName(name) + "_1",
+		Labels:   labels,
+		Networks: make(map[string][]string),
+	}
+
+	networks := svc.Networks
+	if len(networks) <= 0 {
+		networks = ServiceNetworks{defaultNetwork: nil}
+	}
+	for net, aliases := range networks {
+		opt.Networks[r.resourceName(net)] = append([]string{name}, aliases...)
+	}
+
+	for _, p := range svc.Ports {
+		opt.Ports = append(opt.Ports, container.PortBinding{
+			HostIP:        p.HostIP,
+			HostPort:      p.Published,
+			ContainerPort: p.Target,
+			Protocol:      p.Protocol,
+		})
+	}
+
+	for _, v := range svc.Volumes {
+		mount := container.Volume{
+			Target:   v.Target,
+			ReadOnly: v.ReadOnly,
+		}
+		switch {
+		case v.Type == "bind":
+			mount.Type, mount.Source = container.VolumeBind, r.resolvePath(v.Source)
+		case v.Type == "tmpfs":
+			mount.Type = container.VolumeTmpfs
+		case v.Source != "":
+			mount.Type, mount.Source = container.VolumeNamed, r.resourceName(v.Source)
+		default:
+			mount.Type = container.VolumeNamed
+		}
+		opt.Volumes = append(opt.Volumes, mount)
+	}
+
+	if hc := svc.HealthCheck; hc != nil {
+		opt.HealthCheck = &container.HealthCheck{
+			Test:        hc.Test,
+			Interval:    hc.Interval,
+			Timeout:     hc.Timeout,
+			Retries:     hc.Retries,
+			StartPeriod: hc.StartPeriod,
+		}
+		if hc.Disable {
+			opt.HealthCheck.Test = []string{"NONE"}
+		}
+	}
+
+	id, err := container.NewClient(r.cli).Start(ctx, opt)
+	if err != nil {
+		return err
+	}
+	r.project.Containers[name] = id
+	return nil
+}
+
+func (r *projectRunner) waitHealthy(ctx context.Context, service string) error {
+	ctx, cancel := context.WithTimeout(ctx, r.opt.HealthTimeout)
+	defer cancel()
+	return container.NewClient(r.cli).WaitHealthy(ctx, r.project.Containers[service])
+}
+
+// Down 移除项目的所有容器与网络，removeVolumes 为 true 时同时移除数据卷
+func (p *Project) Down(ctx context.Context, removeVolumes bool) error {
+	return down(ctx, p.cli, p.Name, removeVolumes)
+}
+
+// Down 根据 label 清理指定 host 上某个项目遗留的资源，可用于进程崩溃后的清理
+func Down(ctx context.Context, hostURL, projectName string, removeVolumes bool) error {
+	cli, err := docker.GetClient(ctx, &docker.InitOption{
+		Host: hostURL,
+	})
+	if err != nil {
+		return err
+	}
+	return down(ctx, cli, projectName, removeVolumes)
+}
+
+func down(ctx context.Context, cli *docker.Client, projectName string, removeVolumes bool) error {
+	labels := map[string]string{LabelProject: projectName}
+	var errs []string
+
+	containerCli := container.NewClient(cli)
+	containers, err := containerCli.ListByLabels(ctx, labels)
+	if err != nil {
+		return err
+	}
+	for _, c := range containers {
+		if err := containerCli.Remove(ctx, c.ID); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	networkCli := network.NewClient(cli)
+	networks, err := networkCli.ListByLabels(ctx, labels)
+	if err != nil {
+		return err
+	}
+	for _, n := range networks {
+		if err := networkCli.Remove(ctx, n.ID); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if removeVolumes {
+		volumeCli := volume.NewClient(cli)
+		volumes, err := volumeCli.ListByLabels(ctx, labels)
+		if err != nil {
+			return err
+		}
+		for _, v := range volumes {
+			if err := volumeCli.Remove(ctx, v.Name); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("down project %s: %s", projectName, strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -13,6 +13,15 @@ import (
 	"github.com/loheagn/loclo/docker"
 )
 
+// Client 在 docker.Client 上提供容器相关的操作
+type Client struct {
+	cli *docker.Client
+}
+
+func NewClient(cli *docker.Client) *Client {
+	return &Client{cli: cli}
+}
+
 type RunOption struct {
 	HostURL string
 	Image   string
@@ -20,6 +29,8 @@ type RunOption struct {
 	Envs    map[string]string
 	WorkDir string
 	Mounts  map[string]string
+	// Owner 会以 label 的形式记录在容器上
+	Owner docker.Ownership
 	Resources
 }
 
@@ -28,84 +39,130 @@ type Resources struct {
 }
 
 func Run(ctx context.Context, opt *RunOption) (output string, exitCode int, err error) {
-	cli, err := docker.GetClient(&docker.InitOption{
+	result, err := RunWithUsage(ctx, opt)
+	if result == nil {
+		return "", 1, err
+	}
+	return result.Output, result.ExitCode, err
+}
+
+// RunWithUsage 与 Run 相同，但额外返回容器运行期间的资源消耗
+func RunWithUsage(ctx context.Context, opt *RunOption) (*RunResult, error) {
+	cli, err := docker.GetClient(ctx, &docker.InitOption{
 		Host: opt.HostURL,
 	})
 	if err != nil {
-		return "", 1, err
+		return nil, err
 	}
+	return NewClient(cli).RunWithUsage(ctx, opt)
+}
 
-	// 配置基本参数
-	// TODO: 参数核验
-	envs := make([]string, 0, len(opt.Envs))
-	for k, v := range opt.Envs {
-		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
-	}
-	config := &container.Config{
-		Image:      opt.Image,
-		Cmd:        opt.Cmd,
-		WorkingDir: opt.WorkDir,
-		Env:        envs,
-	}
-	// 挂载目录
-	mounts := make([]mount.Mount, 0, len(opt.Mounts))
-	for source, target := range opt.Mounts {
-		mounts = append(mounts, mount.Mount{
-			Type:   mount.TypeBind,
-			Source: source,
-			Target: target,
-		})
+// Run 运行一个容器并等待其退出，忽略 opt.HostURL
+func (c *Client) Run(ctx context.Context, opt *RunOption) (output string, exitCode int, err error) {
+	result, err := c.RunWithUsage(ctx, opt)
+	if result == nil {
+		return "", 1, err
 	}
-	// 处理 Resources
-	memBytes, err := bytefmt.ToBytes(opt.Memory)
+	return result.Output, result.ExitCode, err
+}
+
+// RunWithUsage 与 Run 相同，但额外返回容器运行期间的资源消耗
+func (c *Client) RunWithUsage(ctx context.Context, opt *RunOption) (result *RunResult, err error) {
+	cli := c.cli
+
+	config, hostConfig, err := opt.configs()
 	if err != nil {
-		return
-	}
-	res := container.Resources{
-		Memory: int64(memBytes),
-	}
-	hostConfig := &container.HostConfig{
-		Mounts:    mounts,
-		Resources: res,
+		return nil, err
 	}
 
 	resp, err := cli.ContainerCreate(ctx, config, hostConfig, nil, nil, "")
 	if err != nil {
-		return "", 1, err
+		return nil, err
 	}
 
-	// 保证最后将容器移除
+	// 保证最后将容器移除，ctx 被取消时也要强制移除仍在运行的容器
 	defer func() {
-		_ = cli.ContainerRemove(ctx, resp.ID, types.ContainerRemoveOptions{})
+		_ = cli.ContainerRemove(context.Background(), resp.ID, types.ContainerRemoveOptions{Force: true})
 	}()
 
 	if err := cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
-		return "", 1, err
+		return nil, err
 	}
 
+	// 采集资源消耗，失败时不影响容器本身的运行
+	collector, _ := collectUsage(ctx, cli, resp.ID)
+
 	statusCh, errCh := cli.ContainerWait(ctx, resp.ID, container.WaitConditionNotRunning)
 	select {
 	case err := <-errCh:
 		if err != nil {
-			return "", 1, err
+			return nil, err
 		}
 	case <-statusCh:
 	}
 
+	result = &RunResult{ExitCode: 1}
+	if collector != nil {
+		result.Usage = collector.wait()
+	}
+
 	out, err := cli.ContainerLogs(ctx, resp.ID, types.ContainerLogsOptions{ShowStdout: true})
 	if err != nil {
-		return "", 1, err
+		return nil, err
 	}
 
 	var writer = &bytes.Buffer{}
 	_, err = stdcopy.StdCopy(writer, writer, out)
 	if err != nil {
-		return "", 1, err
+		return nil, err
 	}
+	result.Output = writer.String()
 
 	status, err := cli.ContainerInspect(ctx, resp.ID)
 	if err != nil {
-		return "", 1, err
+		return result, err
+	}
+	result.ExitCode = status.State.ExitCode
+	result.Usage.fillState(status.State)
+	return result, nil
+}
+
+// configs 将 RunOption 转换为创建容器所需的配置
+func (opt *RunOption) configs() (*container.Config, *container.HostConfig, error) {
+	// 配置基本参数
+	// TODO: 参数核验
+	envs := make([]string, 0, len(opt.Envs))
+	for k, v := range opt.Envs {
+		envs = append(envs, fmt.Sprintf("%s=%s", k, v))
+	}
+	config := &container.Config{
+		Image:      opt.Image,
+		Cmd:        opt.Cmd,
+		WorkingDir: opt.WorkDir,
+		Env:        envs,
+		Labels:     opt.Owner.Labels(nil),
+	}
+	// 挂载目录
+	mounts := make([]mount.Mount, 0, len(opt.Mounts))
+	for source, target := range opt.Mounts {
+		mounts = append(mounts, mount.Mount{
+			Type:   mount.TypeBind,
+			Source: source,
+			Target: target,
+		})
+	}
+	// 处理 Resources，未指定内存时不做限制
+	res := container.Resources{}
+	if len(opt.Memory) > 0 {
+		memBytes, err := bytefmt.ToBytes(opt.Memory)
+		if err != nil {
+			return nil, nil, err
+		}
+		res.Memory = int64(memBytes)
+	}
+	hostConfig := &container.HostConfig{
+		Mounts:    mounts,
+		Resources: res,
 	}
-	return writer.String(), status.State.ExitCode, err
+	return config, hostConfig, nil
 }
@@ -0,0 +1,210 @@
+package container
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/go-connections/nat"
+	"github.com/loheagn/loclo/docker"
+)
+
+// StartOption 描述一个在后台持续运行的容器，与 Run 不同，Start 不会等待容器退出
+type StartOption struct {
+	RunOption
+	Name   string
+	Labels map[string]string
+	// Volumes 是 Mounts 之外的挂载，支持命名卷与只读挂载
+	Volumes []Volume
+	Ports   []PortBinding
+	// Networks 是容器需要加入的网络，key 为网络名，value 为容器在该网络中的别名
+	Networks    map[string][]string
+	HealthCheck *HealthCheck
+	// WaitFor 不为空时，Start 会等待容器就绪后再返回；超时或失败时移除容器
+	WaitFor WaitStrategy
+	// WaitTimeout 默认为 DefaultWaitTimeout
+	WaitTimeout time.Duration
+}
+
+type VolumeType string
+
+const (
+	VolumeBind  VolumeType = "bind"
+	VolumeNamed VolumeType = "volume"
+	// VolumeTmpfs 是内存中的临时文件系统，Source 必须为空
+	VolumeTmpfs VolumeType = "tmpfs"
+)
+
+type Volume struct {
+	Type     VolumeType
+	Source   string
+	Target   string
+	ReadOnly bool
+}
+
+type PortBinding struct {
+	HostIP        string
+	HostPort      string
+	ContainerPort string
+	// Protocol 默认为 tcp
+	Protocol string
+}
+
+// HealthCheck 描述容器的健康检查，Test 的格式与 Dockerfile 中的 HEALTHCHECK 相同，
+// 例如 ["CMD", "curl", "-f", "http://localhost"] 或 ["CMD-SHELL", "curl -f http://localhost"]
+type HealthCheck struct {
+	Test        []string
+	Interval    time.Duration
+	Timeout     time.Duration
+	Retries     int
+	StartPeriod time.Duration
+}
+
+// Start 使用 opt.HostURL 对应的缓存 client 启动一个容器，返回容器 ID
+func Start(ctx context.Context, opt *StartOption) (string, error) {
+	cli, err := docker.GetClient(ctx, &docker.InitOption{
+		Host: opt.HostURL,
+	})
+	if err != nil {
+		return "", err
+	}
+	return NewClient(cli).Start(ctx, opt)
+}
+
+// Start 创建并启动一个容器，返回容器 ID。启动失败时会移除已创建的容器
+func (c *Client) Start(ctx context.Context, opt *StartOption) (id string, err error) {
+	config, hostConfig, err := opt.configs()
+	if err != nil {
+		return "", err
+	}
+	config.Labels = opt.Owner.Labels(opt.Labels)
+
+	// 处理端口映射
+	exposed, bindings, err := opt.portBindings()
+	if err != nil {
+		return "", err
+	}
+	config.ExposedPorts = exposed
+	hostConfig.PortBindings = bindings
+
+	for _, v := range opt.Volumes {
+		t := mount.TypeBind
+		switch v.Type {
+		case VolumeNamed:
+			t = mount.TypeVolume
+		case VolumeTmpfs:
+			t = mount.TypeTmpfs
+		}
+		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
+			Type:     t,
+			Source:   v.Source,
+			Target:   v.Target,
+			ReadOnly: v.ReadOnly,
+		})
+	}
+
+	if opt.HealthCheck != nil {
+		config.Healthcheck = &container.HealthConfig{
+			Test:        opt.HealthCheck.Test,
+			Interval:    opt.HealthCheck.Interval,
+			Timeout:     opt.HealthCheck.Timeout,
+			Retries:     opt.HealthCheck.Retries,
+			StartPeriod: opt.HealthCheck.StartPeriod,
+		}
+	}
+
+	// 创建时只能指定一个网络，其余网络在创建后再连接
+	networkNames := make([]string, 0, len(opt.Networks))
+	for name := range opt.Networks {
+		networkNames = append(networkNames, name)
+	}
+	sort.Strings(networkNames)
+	var netConfig *network.NetworkingConfig
+	if len(networkNames) > 0 {
+		hostConfig.NetworkMode = container.NetworkMode(networkNames[0])
+		netConfig = &network.NetworkingConfig{
+			EndpointsConfig: map[string]*network.EndpointSettings{
+				networkNames[0]: {Aliases: opt.Networks[networkNames[0]]},
+			},
+		}
+	}
+
+	resp, err := c.cli.ContainerCreate(ctx, config, hostConfig, netConfig, nil, opt.Name)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			_ = c.Remove(context.Background(), resp.ID)
+		}
+	}()
+
+	for i, name := range networkNames {
+		if i == 0 {
+			continue
+		}
+		err = c.cli.NetworkConnect(ctx, name, resp.ID, &network.EndpointSettings{Aliases: opt.Networks[name]})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err = c.cli.ContainerStart(ctx, resp.ID, types.ContainerStartOptions{}); err != nil {
+		return "", err
+	}
+
+	if opt.WaitFor != nil {
+		timeout := opt.WaitTimeout
+		if timeout <= 0 {
+			timeout = DefaultWaitTimeout
+		}
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		if err = opt.WaitFor.wait(waitCtx, c, resp.ID); err != nil {
+			return "", fmt.Errorf("wait for container %s: %w", resp.ID, err)
+		}
+	}
+	return resp.ID, nil
+}
+
+func (opt *StartOption) portBindings() (nat.PortSet, nat.PortMap, error) {
+	if len(opt.Ports) <= 0 {
+		return nil, nil, nil
+	}
+	exposed := make(nat.PortSet)
+	bindings := make(nat.PortMap)
+	for _, p := range opt.Ports {
+		protocol := strings.ToLower(p.Protocol)
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		port, err := nat.NewPort(protocol, p.ContainerPort)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid port %s/%s: %w", p.ContainerPort, protocol, err)
+		}
+		exposed[port] = struct{}{}
+		bindings[port] = append(bindings[port], nat.PortBinding{HostIP: p.HostIP, HostPort: p.HostPort})
+	}
+	return exposed, bindings, nil
+}
+
+// Remove 强制移除容器及其匿名卷
+func (c *Client) Remove(ctx context.Context, id string) error {
+	return c.cli.ContainerRemove(ctx, id, types.ContainerRemoveOptions{Force: true, RemoveVolumes: true})
+}
+
+// ListByLabels 列出带有全部指定 label 的容器，包括已退出的容器
+func (c *Client) ListByLabels(ctx context.Context, labels map[string]string) ([]types.Container, error) {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", fmt.Sprintf("%s=%s", k, v))
+	}
+	return c.cli.ContainerList(ctx, types.ContainerListOptions{All: true, Filters: args})
+}
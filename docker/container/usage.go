@@ -0,0 +1,146 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/client"
+)
+
+// Usage 记录一次容器运行所消耗的资源
+type Usage struct {
+	// PeakMemory 运行期间内存占用的峰值，单位 byte
+	PeakMemory uint64
+	// CPUTime 容器累计使用的 CPU 时间
+	CPUTime time.Duration
+	// WallTime 容器从启动到退出的时间
+	WallTime time.Duration
+
+	NetworkRx  uint64
+	NetworkTx  uint64
+	BlockRead  uint64
+	BlockWrite uint64
+
+	// OOMKilled 容器是否因为超出内存限制被杀死
+	OOMKilled bool
+}
+
+// RunResult 是 RunWithUsage 的返回结果
+type RunResult struct {
+	Output   string
+	ExitCode int
+	Usage    Usage
+}
+
+// usageCollector 在容器运行期间消费 stats stream，记录各项指标
+type usageCollector struct {
+	usage  Usage
+	done   chan struct{}
+	cancel context.CancelFunc
+}
+
+// statsGracePeriod 是容器退出后等待 stats stream 自行结束的时间
+const statsGracePeriod = 2 * time.Second
+
+func collectUsage(ctx context.Context, cli client.APIClient, containerID string) (*usageCollector, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	stats, err := cli.ContainerStats(ctx, containerID, true)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	c := &usageCollector{done: make(chan struct{}), cancel: cancel}
+	go func() {
+		defer close(c.done)
+		defer func(Body io.ReadCloser) {
+			_ = Body.Close()
+		}(stats.Body)
+
+		decoder := json.NewDecoder(stats.Body)
+		for {
+			var s types.StatsJSON
+			if err := decoder.Decode(&s); err != nil {
+				return
+			}
+			c.sample(&s)
+		}
+	}()
+	return c, nil
+}
+
+func (c *usageCollector) sample(s *types.StatsJSON) {
+	// cgroup v1 的 MaxUsage 记录了两次采样之间的峰值；cgroup v2 不提供 MaxUsage（为 0），
+	// 此时峰值只能取各次采样中 Usage 的最大值
+	if mem := s.MemoryStats.Usage; mem > c.usage.PeakMemory {
+		c.usage.PeakMemory = mem
+	}
+	if mem := s.MemoryStats.MaxUsage; mem > c.usage.PeakMemory {
+		c.usage.PeakMemory = mem
+	}
+
+	// 容器退出后 cgroup 被回收，最后几次采样可能为空，只保留增长的值
+	if cpu := time.Duration(s.CPUStats.CPUUsage.TotalUsage); cpu > c.usage.CPUTime {
+		c.usage.CPUTime = cpu
+	}
+
+	var rx, tx uint64
+	for _, n := range s.Networks {
+		rx += n.RxBytes
+		tx += n.TxBytes
+	}
+	if rx > c.usage.NetworkRx {
+		c.usage.NetworkRx = rx
+	}
+	if tx > c.usage.NetworkTx {
+		c.usage.NetworkTx = tx
+	}
+
+	var read, write uint64
+	for _, entry := range s.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			read += entry.Value
+		case "write":
+			write += entry.Value
+		}
+	}
+	if read > c.usage.BlockRead {
+		c.usage.BlockRead = read
+	}
+	if write > c.usage.BlockWrite {
+		c.usage.BlockWrite = write
+	}
+}
+
+// wait 应在容器退出后调用，等待 stats stream 结束并返回采集到的数据
+func (c *usageCollector) wait() Usage {
+	select {
+	case <-c.done:
+	case <-time.After(statsGracePeriod):
+	}
+	// 关闭 stream 以保证采集 goroutine 退出
+	c.cancel()
+	<-c.done
+	return c.usage
+}
+
+// fillState 用 ContainerInspect 得到的状态补全 Usage
+func (u *Usage) fillState(state *types.ContainerState) {
+	if state == nil {
+		return
+	}
+	u.OOMKilled = state.OOMKilled
+	started, err := time.Parse(time.RFC3339Nano, state.StartedAt)
+	if err != nil {
+		return
+	}
+	finished, err := time.Parse(time.RFC3339Nano, state.FinishedAt)
+	if err != nil || finished.Before(started) {
+		return
+	}
+	u.WallTime = finished.Sub(started)
+}
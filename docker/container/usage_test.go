@@ -0,0 +1,95 @@
+package container
+
+import (
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+)
+
+func stats(usage, maxUsage, cpu, rx, tx, read, write uint64) *types.StatsJSON {
+	s := &types.StatsJSON{
+		Networks: map[string]types.NetworkStats{
+			"eth0": {RxBytes: rx / 2, TxBytes: tx / 2},
+			"eth1": {RxBytes: rx - rx/2, TxBytes: tx - tx/2},
+		},
+	}
+	s.MemoryStats.Usage = usage
+	s.MemoryStats.MaxUsage = maxUsage
+	s.CPUStats.CPUUsage.TotalUsage = cpu
+	s.BlkioStats.IoServiceBytesRecursive = []types.BlkioStatEntry{
+		{Op: "Read", Value: read},
+		{Op: "Write", Value: write},
+		{Op: "Total", Value: read + write},
+	}
+	return s
+}
+
+func Test_usageCollector_sample(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []*types.StatsJSON
+		want    Usage
+	}{
+		{
+			name:    "cgroup v1 max usage",
+			samples: []*types.StatsJSON{stats(100, 300, 10, 1, 2, 3, 4), stats(200, 250, 20, 5, 6, 7, 8)},
+			want:    Usage{PeakMemory: 300, CPUTime: 20, NetworkRx: 5, NetworkTx: 6, BlockRead: 7, BlockWrite: 8},
+		},
+		{
+			name:    "cgroup v2 without max usage",
+			samples: []*types.StatsJSON{stats(100, 0, 10, 0, 0, 0, 0), stats(400, 0, 20, 0, 0, 0, 0), stats(150, 0, 30, 0, 0, 0, 0)},
+			want:    Usage{PeakMemory: 400, CPUTime: 30},
+		},
+		{
+			name:    "empty samples after exit",
+			samples: []*types.StatsJSON{stats(100, 0, 50, 10, 20, 30, 40), {}},
+			want:    Usage{PeakMemory: 100, CPUTime: 50, NetworkRx: 10, NetworkTx: 20, BlockRead: 30, BlockWrite: 40},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &usageCollector{}
+			for _, s := range tt.samples {
+				c.sample(s)
+			}
+			if c.usage != tt.want {
+				t.Errorf("usage = %+v, want %+v", c.usage, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsage_fillState(t *testing.T) {
+	tests := []struct {
+		name  string
+		state *types.ContainerState
+		want  Usage
+	}{
+		{name: "nil"},
+		{
+			name:  "finished",
+			state: &types.ContainerState{StartedAt: "2021-08-11T00:00:00.5Z", FinishedAt: "2021-08-11T00:00:02Z", OOMKilled: true},
+			want:  Usage{WallTime: 1500 * time.Millisecond, OOMKilled: true},
+		},
+		{
+			// 仍在运行的容器 FinishedAt 为零值
+			name:  "not finished",
+			state: &types.ContainerState{StartedAt: "2021-08-11T00:00:00Z", FinishedAt: "0001-01-01T00:00:00Z"},
+		},
+		{
+			name:  "invalid time",
+			state: &types.ContainerState{StartedAt: "", FinishedAt: "2021-08-11T00:00:02Z", OOMKilled: true},
+			want:  Usage{OOMKilled: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var u Usage
+			u.fillState(tt.state)
+			if u != tt.want {
+				t.Errorf("fillState() = %+v, want %+v", u, tt.want)
+			}
+		})
+	}
+}
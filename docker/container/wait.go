@@ -0,0 +1,203 @@
+package container
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"regexp"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+)
+
+const DefaultWaitTimeout = time.Minute
+
+// waitPollInterval 是轮询容器状态的间隔，测试时可以调小
+var waitPollInterval = 500 * time.Millisecond
+
+var (
+	ErrUnhealthy     = errors.New("container is unhealthy")
+	ErrNoHealthCheck = errors.New("container has no healthcheck")
+)
+
+// WaitStrategy 决定一个启动后的容器何时可以被认为已经就绪
+type WaitStrategy interface {
+	wait(ctx context.Context, c *Client, id string) error
+}
+
+type healthyStrategy struct{}
+
+// ForHealthy 等待容器的健康检查通过
+func ForHealthy() WaitStrategy {
+	return healthyStrategy{}
+}
+
+func (healthyStrategy) wait(ctx context.Context, c *Client, id string) error {
+	return c.WaitHealthy(ctx, id)
+}
+
+type logStrategy struct {
+	pattern *regexp.Regexp
+}
+
+// ForLog 等待容器输出匹配 pattern 的一行日志
+func ForLog(pattern *regexp.Regexp) WaitStrategy {
+	return logStrategy{pattern: pattern}
+}
+
+func (s logStrategy) wait(ctx context.Context, c *Client, id string) error {
+	return c.WaitForLog(ctx, id, s.pattern)
+}
+
+type portStrategy struct {
+	port string
+}
+
+// ForPort 等待容器映射到宿主机的端口可以建立 TCP 连接，port 的格式为 "5432" 或 "5432/tcp"
+func ForPort(port string) WaitStrategy {
+	return portStrategy{port: port}
+}
+
+func (s portStrategy) wait(ctx context.Context, c *Client, id string) error {
+	return c.WaitForPort(ctx, id, s.port)
+}
+
+// WaitHealthy 轮询容器状态，直到健康检查通过、失败或容器退出。超时由 ctx 控制
+func (c *Client) WaitHealthy(ctx context.Context, id string) error {
+	return c.poll(ctx, id, func(status types.ContainerJSON) (bool, error) {
+		health := status.State.Health
+		if health == nil {
+			return false, ErrNoHealthCheck
+		}
+		switch health.Status {
+		case types.Healthy:
+			return true, nil
+		case types.Unhealthy:
+			return false, ErrUnhealthy
+		default:
+			return false, nil
+		}
+	})
+}
+
+// WaitForPort 轮询直到容器映射到宿主机的 port 可以建立 TCP 连接
+func (c *Client) WaitForPort(ctx context.Context, id string, port string) error {
+	proto, p := nat.SplitProtoPort(port)
+	if proto != "tcp" {
+		return fmt.Errorf("only tcp port can be waited for, got %s", port)
+	}
+	natPort, err := nat.NewPort(proto, p)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	return c.poll(ctx, id, func(status types.ContainerJSON) (bool, error) {
+		addr, ok, err := publishedAddress(status, natPort, c.cli.HostName())
+		if err != nil || !ok {
+			return false, err
+		}
+		dialCtx, cancel := context.WithTimeout(ctx, waitPollInterval)
+		defer cancel()
+		conn, err := dialer.DialContext(dialCtx, "tcp", addr)
+		if err != nil {
+			return false, nil
+		}
+		_ = conn.Close()
+		return true, nil
+	})
+}
+
+// publishedAddress 返回容器的 port 映射到宿主机上的地址，网络信息尚未就绪时 ok 为 false。
+// 绑定在所有地址上时使用 daemon 所在主机的地址 hostName
+func publishedAddress(status types.ContainerJSON, port nat.Port, hostName string) (addr string, ok bool, err error) {
+	if status.NetworkSettings == nil {
+		return "", false, nil
+	}
+	bindings := status.NetworkSettings.Ports[port]
+	if len(bindings) <= 0 {
+		return "", false, fmt.Errorf("port %s is not published", port)
+	}
+	host := bindings[0].HostIP
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = hostName
+	}
+	return net.JoinHostPort(host, bindings[0].HostPort), true, nil
+}
+
+// poll 周期性地 inspect 容器并调用 ready，容器退出时返回错误
+func (c *Client) poll(ctx context.Context, id string, ready func(status types.ContainerJSON) (bool, error)) error {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+	for {
+		status, err := c.cli.ContainerInspect(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !status.State.Running {
+			return fmt.Errorf("container exited with code %d", status.State.ExitCode)
+		}
+		if ok, err := ready(status); err != nil || ok {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// WaitForLog 跟踪容器的 stdout 与 stderr，直到出现匹配 pattern 的一行
+func (c *Client) WaitForLog(ctx context.Context, id string, pattern *regexp.Regexp) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	status, err := c.cli.ContainerInspect(ctx, id)
+	if err != nil {
+		return err
+	}
+	out, err := c.cli.ContainerLogs(ctx, id, types.ContainerLogsOptions{
+		ShowStdout: true,
+		ShowStderr: true,
+		Follow:     true,
+	})
+	if err != nil {
+		return err
+	}
+	defer func(out io.ReadCloser) {
+		_ = out.Close()
+	}(out)
+
+	// 使用 tty 的容器日志是原始的字节流，否则 stdout 与 stderr 被复用在同一个流中
+	var reader io.Reader = out
+	if status.Config == nil || !status.Config.Tty {
+		pr, pw := io.Pipe()
+		defer func(pr io.ReadCloser) {
+			_ = pr.Close()
+		}(pr)
+		go func() {
+			_, err := stdcopy.StdCopy(pw, pw, out)
+			_ = pw.CloseWithError(err)
+		}()
+		reader = pr
+	}
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		if pattern.Match(scanner.Bytes()) {
+			return nil
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("log stream ended before %q appeared", pattern)
+}
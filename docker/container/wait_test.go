@@ -0,0 +1,201 @@
+package container
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/loheagn/loclo/docker"
+)
+
+// fakeDaemon 只实现了创建、启动、inspect、日志与删除容器的接口，所有请求都作用于同一个容器
+type fakeDaemon struct {
+	mu      sync.Mutex
+	status  types.ContainerJSON
+	logs    string
+	removed bool
+}
+
+func (d *fakeDaemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch {
+	case r.URL.Path == "/_ping":
+		w.Header().Set("API-Version", "1.41")
+	case strings.HasSuffix(r.URL.Path, "/containers/create"):
+		_ = json.NewEncoder(w).Encode(container.ContainerCreateCreatedBody{ID: "abc"})
+	case strings.HasSuffix(r.URL.Path, "/start"):
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(r.URL.Path, "/json"):
+		_ = json.NewEncoder(w).Encode(d.status)
+	case strings.HasSuffix(r.URL.Path, "/logs"):
+		if d.status.Config != nil && d.status.Config.Tty {
+			_, _ = w.Write([]byte(d.logs))
+			return
+		}
+		_, _ = stdcopy.NewStdWriter(w, stdcopy.Stderr).Write([]byte(d.logs))
+	case r.Method == http.MethodDelete:
+		d.removed = true
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeDaemon(t *testing.T, state *types.ContainerState) (*fakeDaemon, *Client) {
+	t.Helper()
+	interval := waitPollInterval
+	waitPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { waitPollInterval = interval })
+
+	d := &fakeDaemon{status: types.ContainerJSON{
+		ContainerJSONBase: &types.ContainerJSONBase{ID: "abc", State: state},
+		Config:            &container.Config{},
+		NetworkSettings:   &types.NetworkSettings{},
+	}}
+	server := httptest.NewServer(d)
+	t.Cleanup(server.Close)
+	cli, err := docker.NewClient(context.Background(), &docker.InitOption{Host: "tcp://" + server.Listener.Addr().String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = cli.Close() })
+	return d, NewClient(cli)
+}
+
+func running(health string) *types.ContainerState {
+	state := &types.ContainerState{Running: true}
+	if health != "" {
+		state.Health = &types.Health{Status: health}
+	}
+	return state
+}
+
+func TestWaitStrategy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	_, listenPort, _ := net.SplitHostPort(listener.Addr().String())
+
+	tests := []struct {
+		name     string
+		state    *types.ContainerState
+		tty      bool
+		logs     string
+		ports    nat.PortMap
+		strategy WaitStrategy
+		// wantErr 不为空时要求返回该错误，fail 为 true 时只要求返回错误
+		wantErr error
+		fail    bool
+	}{
+		{name: "healthy", state: running(types.Healthy), strategy: ForHealthy()},
+		{name: "unhealthy", state: running(types.Unhealthy), strategy: ForHealthy(), wantErr: ErrUnhealthy},
+		{name: "no healthcheck", state: running(""), strategy: ForHealthy(), wantErr: ErrNoHealthCheck},
+		{name: "health starting", state: running(types.Starting), strategy: ForHealthy(), wantErr: context.DeadlineExceeded},
+		{name: "log", state: running(""), logs: "booting\nready to accept connections\n", strategy: ForLog(regexp.MustCompile("ready to accept"))},
+		{name: "log with tty", state: running(""), tty: true, logs: "ready to accept connections\n", strategy: ForLog(regexp.MustCompile("^ready to accept"))},
+		{name: "log not found", state: running(""), logs: "booting\n", strategy: ForLog(regexp.MustCompile("ready")), fail: true},
+		{
+			name:     "port",
+			state:    running(""),
+			ports:    nat.PortMap{"5432/tcp": {{HostIP: "0.0.0.0", HostPort: listenPort}}},
+			strategy: ForPort("5432"),
+		},
+		{
+			name:     "port not listening",
+			state:    running(""),
+			ports:    nat.PortMap{"5432/tcp": {{HostIP: "127.0.0.1", HostPort: "1"}}},
+			strategy: ForPort("5432/tcp"),
+			wantErr:  context.DeadlineExceeded,
+		},
+		{name: "port not published", state: running(""), strategy: ForPort("5432"), fail: true},
+		{name: "udp port", state: running(""), strategy: ForPort("53/udp"), fail: true},
+		{name: "exited", state: &types.ContainerState{ExitCode: 1}, strategy: ForHealthy(), fail: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, c := newFakeDaemon(t, tt.state)
+			d.status.Config.Tty = tt.tty
+			d.logs = tt.logs
+			d.status.NetworkSettings.Ports = tt.ports
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+			err := tt.strategy.wait(ctx, c, "abc")
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("wait() error = %v, want %v", err, tt.wantErr)
+				}
+			case (err != nil) != tt.fail:
+				t.Errorf("wait() error = %v, want error %v", err, tt.fail)
+			}
+		})
+	}
+}
+
+func TestStart_waitTimeout(t *testing.T) {
+	d, c := newFakeDaemon(t, running(types.Starting))
+	start := time.Now()
+	_, err := c.Start(context.Background(), &StartOption{
+		RunOption:   RunOption{Image: "postgres:13"},
+		WaitFor:     ForHealthy(),
+		WaitTimeout: 50 * time.Millisecond,
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Start() error = %v, want deadline exceeded", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Start() took %v, want about the wait timeout", elapsed)
+	}
+	// 等待失败后移除容器
+	if !d.removed {
+		t.Errorf("container not removed after wait timeout")
+	}
+}
+
+func Test_publishedAddress(t *testing.T) {
+	port := nat.Port("8080/tcp")
+	tests := []struct {
+		name     string
+		settings *types.NetworkSettings
+		want     string
+		wantOK   bool
+		wantErr  bool
+	}{
+		{name: "network not ready"},
+		{name: "not published", settings: &types.NetworkSettings{}, wantErr: true},
+		{name: "all interfaces", settings: portSettings(port, "0.0.0.0", "32768"), want: "10.251.0.45:32768", wantOK: true},
+		{name: "all ipv6 interfaces", settings: portSettings(port, "::", "32768"), want: "10.251.0.45:32768", wantOK: true},
+		{name: "empty host ip", settings: portSettings(port, "", "32768"), want: "10.251.0.45:32768", wantOK: true},
+		{name: "specific host ip", settings: portSettings(port, "127.0.0.1", "8080"), want: "127.0.0.1:8080", wantOK: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok, err := publishedAddress(types.ContainerJSON{NetworkSettings: tt.settings}, port, "10.251.0.45")
+			if (err != nil) != tt.wantErr || ok != tt.wantOK || got != tt.want {
+				t.Errorf("publishedAddress() = %q, %v, %v, want %q, %v, wantErr %v", got, ok, err, tt.want, tt.wantOK, tt.wantErr)
+			}
+		})
+	}
+}
+
+func portSettings(port nat.Port, hostIP, hostPort string) *types.NetworkSettings {
+	return &types.NetworkSettings{NetworkSettingsBase: types.NetworkSettingsBase{
+		Ports: nat.PortMap{port: {{HostIP: hostIP, HostPort: hostPort}}},
+	}}
+}
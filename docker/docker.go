@@ -2,27 +2,225 @@ package docker
 
 import (
 	"bufio"
+	"context"
+	"fmt"
 	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/docker/docker/client"
+	"github.com/docker/go-connections/tlsconfig"
 )
 
 type InitOption struct {
+	// Host 支持 unix://、tcp:// 以及 ssh://[user@]host[:port]
 	Host string
+	// TLS 不为空时使用 TLS 连接 tcp:// host
+	TLS *TLSOption
 }
 
-func GetClient(opt *InitOption) (cli *client.Client, err error) {
-	if len(opt.Host) > 0 {
-		cli, err = client.NewClientWithOpts(client.WithHost(opt.Host))
-		if err == nil {
-			return cli, err
+// TLSOption 配置连接 Docker daemon 时使用的客户端证书
+type TLSOption struct {
+	CACertPath string
+	CertPath   string
+	KeyPath    string
+	// Verify 为 false 时不校验 daemon 的证书
+	Verify bool
+}
+
+// Client 是一个长期存活的 Docker client，创建时会 ping daemon 并协商 API 版本
+type Client struct {
+	*client.Client
+	key  string
+	host string
+}
+
+var (
+	clientsMu sync.Mutex
+	clients   = make(map[string]*cachedClient)
+)
+
+// cachedClient 是缓存中的一项，done 关闭后 cli 与 err 才可读。
+// 同一个 host 只由第一个调用者创建 Client，其余调用者等待其结果
+type cachedClient struct {
+	done chan struct{}
+	cli  *Client
+	err  error
+}
+
+func (opt *InitOption) cacheKey() string {
+	if opt.TLS == nil {
+		return opt.Host
+	}
+	return fmt.Sprintf("%s|%s|%s|%s|%t", opt.Host, opt.TLS.CACertPath, opt.TLS.CertPath, opt.TLS.KeyPath, opt.TLS.Verify)
+}
+
+func (opt *InitOption) clientOpts() ([]client.Opt, error) {
+	ops := []client.Opt{client.WithAPIVersionNegotiation()}
+	switch {
+	case strings.HasPrefix(opt.Host, "ssh://"):
+		if opt.TLS != nil {
+			return nil, fmt.Errorf("tls is not supported for ssh host %q", opt.Host)
+		}
+		spec, err := parseSSHHost(opt.Host)
+		if err != nil {
+			return nil, err
 		}
+		return append(ops, client.WithHost(sshDummyHost), client.WithDialContext(spec.dial)), nil
+	case len(opt.Host) > 0:
+		ops = append(ops, client.WithHost(opt.Host))
+	default:
+		ops = append(ops, client.FromEnv)
+	}
+	if opt.TLS != nil {
+		ops = append(ops, withTLS(opt.TLS))
 	}
-	return client.NewClientWithOpts(client.FromEnv)
+	return ops, nil
 }
 
-func GetDefaultClient() (cli *client.Client, err error) {
-	return client.NewClientWithOpts(client.FromEnv)
+func withTLS(opt *TLSOption) client.Opt {
+	return func(c *client.Client) error {
+		config, err := tlsconfig.Client(tlsconfig.Options{
+			CAFile:             opt.CACertPath,
+			CertFile:           opt.CertPath,
+			KeyFile:            opt.KeyPath,
+			InsecureSkipVerify: !opt.Verify,
+			ExclusiveRootPools: true,
+		})
+		if err != nil {
+			return fmt.Errorf("load tls config: %w", err)
+		}
+		transport, ok := c.HTTPClient().Transport.(*http.Transport)
+		if !ok {
+			return fmt.Errorf("cannot apply tls config to transport: %T", c.HTTPClient().Transport)
+		}
+		transport.TLSClientConfig = config
+		return client.WithScheme("https")(c)
+	}
+}
+
+// NewClient 创建一个新的 Client，调用者负责 Close。
+// Host 为空时从环境变量中读取配置；Host 不合法或无法连接时返回错误，不会回退到环境变量
+func NewClient(ctx context.Context, opt *InitOption) (*Client, error) {
+	ops, err := opt.clientOpts()
+	if err != nil {
+		return nil, fmt.Errorf("create docker client for %q: %w", opt.Host, err)
+	}
+	cli, err := client.NewClientWithOpts(ops...)
+	if err != nil {
+		return nil, fmt.Errorf("create docker client for %q: %w", opt.Host, err)
+	}
+
+	ping, err := cli.Ping(ctx)
+	if err != nil {
+		_ = cli.Close()
+		return nil, fmt.Errorf("docker host %s is unreachable: %w", cli.DaemonHost(), err)
+	}
+	cli.NegotiateAPIVersionPing(ping)
+	return &Client{Client: cli, host: opt.Host}, nil
+}
+
+// HostName 返回 daemon 所在主机的地址，用于访问容器映射到宿主机的端口。
+// 通过 unix socket 或环境变量连接时返回 localhost
+func (cli *Client) HostName() string {
+	host := cli.host
+	if host == "" {
+		host = cli.DaemonHost()
+	}
+	u, err := url.Parse(host)
+	if err != nil || u.Hostname() == "" || u.Scheme == "unix" || u.Scheme == "npipe" {
+		return "localhost"
+	}
+	return u.Hostname()
+}
+
+// GetClient 返回按 host 缓存的 Client，不存在时创建一个新的。
+// 缓存的 Client 由所有调用者共享，其 Close 为空操作，需要关闭时使用 Evict 或 CloseAll
+func GetClient(ctx context.Context, opt *InitOption) (*Client, error) {
+	key := opt.cacheKey()
+	clientsMu.Lock()
+	if e, ok := clients[key]; ok {
+		clientsMu.Unlock()
+		select {
+		case <-e.done:
+			return e.cli, e.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	e := &cachedClient{done: make(chan struct{})}
+	clients[key] = e
+	clientsMu.Unlock()
+
+	// 创建 Client 时会 ping daemon，不能持有 clientsMu，否则一个无法连接的 host 会阻塞所有 host
+	cli, err := NewClient(ctx, opt)
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	switch {
+	case err != nil:
+		// 创建失败的结果不缓存，之后的调用会重新尝试
+		if clients[key] == e {
+			delete(clients, key)
+		}
+	case clients[key] == e:
+		cli.key = key
+	}
+	// 创建期间被 Evict 或 CloseAll 移除时，cli.key 为空，由调用者自己 Close
+	e.cli, e.err = cli, err
+	close(e.done)
+	return cli, err
+}
+
+func GetDefaultClient(ctx context.Context) (*Client, error) {
+	return GetClient(ctx, &InitOption{})
+}
+
+// Close 关闭由 NewClient 创建的 Client。由 GetClient 得到的 Client 是共享的，Close 不做任何操作
+func (cli *Client) Close() error {
+	if cli.key != "" {
+		return nil
+	}
+	return cli.Client.Close()
+}
+
+// Evict 从缓存中移除 opt 对应的 Client 并关闭它，其他仍持有该 Client 的调用者之后的请求会失败
+func Evict(opt *InitOption) error {
+	key := opt.cacheKey()
+	clientsMu.Lock()
+	e, ok := clients[key]
+	delete(clients, key)
+	clientsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return e.close()
+}
+
+// CloseAll 关闭并移除所有缓存的 Client
+func CloseAll() {
+	clientsMu.Lock()
+	cached := clients
+	clients = make(map[string]*cachedClient)
+	clientsMu.Unlock()
+
+	for _, e := range cached {
+		_ = e.close()
+	}
+}
+
+// close 关闭已经移出缓存的 e，e 还在创建时由创建者负责，不做任何操作
+func (e *cachedClient) close() error {
+	select {
+	case <-e.done:
+	default:
+		return nil
+	}
+	if e.err != nil {
+		return nil
+	}
+	return e.cli.Client.Close()
 }
 
 func ReadOutput(rd io.Reader, output io.Writer) (err error) {
@@ -0,0 +1,213 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+)
+
+// EventType 是产生事件的对象类型
+type EventType string
+
+const (
+	ContainerEvent EventType = events.ContainerEventType
+	ImageEvent     EventType = events.ImageEventType
+	NetworkEvent   EventType = events.NetworkEventType
+	VolumeEvent    EventType = events.VolumeEventType
+)
+
+// 常用的事件 Action，完整列表见 Docker Engine API 的 /events 文档
+const (
+	ActionCreate     = "create"
+	ActionStart      = "start"
+	ActionDie        = "die"
+	ActionOOM        = "oom"
+	ActionKill       = "kill"
+	ActionStop       = "stop"
+	ActionDestroy    = "destroy"
+	ActionPull       = "pull"
+	ActionPush       = "push"
+	ActionTag        = "tag"
+	ActionDelete     = "delete"
+	ActionConnect    = "connect"
+	ActionDisconnect = "disconnect"
+	ActionRemove     = "remove"
+	ActionMount      = "mount"
+	ActionUnmount    = "unmount"
+)
+
+const DefaultReconnectInterval = 3 * time.Second
+
+// Event 是 daemon 事件的类型化表示
+type Event struct {
+	Type   EventType
+	Action string
+	// ID 是事件对象的 ID，镜像事件中为镜像名称
+	ID string
+	// Attributes 对于容器事件包含容器的 label 以及 name、image 等信息
+	Attributes map[string]string
+	Time       time.Time
+}
+
+// Name 返回事件对象的名称
+func (e *Event) Name() string {
+	return e.Attributes["name"]
+}
+
+// ExitCode 返回 die 事件中容器的退出码，其他事件返回 false
+func (e *Event) ExitCode() (int, bool) {
+	code, ok := e.Attributes["exitCode"]
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.Atoi(code)
+	return i, err == nil
+}
+
+type SubscribeOption struct {
+	Types   []EventType
+	Actions []string
+	// Labels 只对带有 label 的对象（容器、网络、数据卷等）生效
+	Labels map[string]string
+	// Since 不为零时，从该时间开始回放历史事件，为零时从调用 Subscribe 时 daemon 的当前时间开始
+	Since time.Time
+	// ReconnectInterval 连接断开后重连前等待的时间，默认为 DefaultReconnectInterval
+	ReconnectInterval time.Duration
+}
+
+func (opt *SubscribeOption) filters() filters.Args {
+	args := filters.NewArgs()
+	for _, t := range opt.Types {
+		args.Add("type", string(t))
+	}
+	for _, action := range opt.Actions {
+		args.Add("event", action)
+	}
+	for k, v := range opt.Labels {
+		args.Add("label", fmt.Sprintf("%s=%s", k, v))
+	}
+	return args
+}
+
+// Subscription 是一个事件订阅，连接断开时会自动从最后收到的事件处重连
+type Subscription struct {
+	events chan Event
+	errs   chan error
+}
+
+// Events 返回事件 channel，ctx 结束后被关闭
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Errors 返回连接错误，订阅会在出错后自动重连。未及时读取的错误会被丢弃
+func (s *Subscription) Errors() <-chan error {
+	return s.errs
+}
+
+// Subscribe 订阅 daemon 的事件，直到 ctx 结束
+func (cli *Client) Subscribe(ctx context.Context, opt *SubscribeOption) *Subscription {
+	interval := opt.ReconnectInterval
+	if interval <= 0 {
+		interval = DefaultReconnectInterval
+	}
+	s := &Subscription{
+		events: make(chan Event),
+		errs:   make(chan error, 1),
+	}
+
+	// 在订阅时确定起始时间，收到第一个事件前重连也不会丢失断开期间的事件。
+	// 获取失败时在连接前重试，since 为零值表示还没有确定起始时间
+	since := opt.Since
+	if since.IsZero() {
+		since, _ = cli.daemonTime(ctx)
+	}
+	go func() {
+		defer close(s.events)
+		for {
+			var err error
+			if since.IsZero() {
+				since, err = cli.daemonTime(ctx)
+			}
+			if err == nil {
+				since, err = s.stream(ctx, cli, opt, since)
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			select {
+			case s.errs <- err:
+			default:
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+	}()
+	return s
+}
+
+// daemonTime 返回 daemon 的当前时间。daemon 按自己的时钟比较 since，
+// 使用本地时间时，如果本地时钟快于 daemon，之后一段时间内的事件都会被丢弃
+func (cli *Client) daemonTime(ctx context.Context) (time.Time, error) {
+	info, err := cli.Info(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339Nano, info.SystemTime)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parse daemon system time %q: %w", info.SystemTime, err)
+	}
+	return t, nil
+}
+
+// stream 转发事件直到连接出错，返回最后一个事件的时间供重连使用
+func (s *Subscription) stream(ctx context.Context, cli *Client, opt *SubscribeOption, since time.Time) (time.Time, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	msgs, errs := cli.Events(ctx, types.EventsOptions{Filters: opt.filters(), Since: formatSince(since)})
+	for {
+		select {
+		case msg := <-msgs:
+			event := convertEvent(msg)
+			// 重连时 since 对应的事件会被再次发送，跳过已经转发过的事件
+			if !event.Time.After(since) {
+				continue
+			}
+			select {
+			case s.events <- event:
+			case <-ctx.Done():
+				return since, ctx.Err()
+			}
+			since = event.Time
+		case err := <-errs:
+			return since, err
+		}
+	}
+}
+
+func formatSince(t time.Time) string {
+	return fmt.Sprintf("%d.%09d", t.Unix(), t.Nanosecond())
+}
+
+func convertEvent(msg events.Message) Event {
+	t := time.Unix(0, msg.TimeNano)
+	if msg.TimeNano == 0 {
+		t = time.Unix(msg.Time, 0)
+	}
+	return Event{
+		Type:       EventType(msg.Type),
+		Action:     msg.Action,
+		ID:         msg.Actor.ID,
+		Attributes: msg.Actor.Attributes,
+		Time:       t,
+	}
+}
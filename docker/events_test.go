@@ -0,0 +1,122 @@
+package docker
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+)
+
+func TestSubscribeOption_filters(t *testing.T) {
+	opt := &SubscribeOption{
+		Types:   []EventType{ContainerEvent},
+		Actions: []string{ActionOOM, ActionDie},
+		Labels:  map[string]string{"loclo.job": "42"},
+	}
+	args := opt.filters()
+
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{key: "type", want: []string{"container"}},
+		{key: "event", want: []string{"die", "oom"}},
+		{key: "label", want: []string{"loclo.job=42"}},
+	}
+	for _, tt := range tests {
+		got := args.Get(tt.key)
+		sort.Strings(got)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("filters %s = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func Test_convertEvent(t *testing.T) {
+	msg := events.Message{
+		Type:   events.ContainerEventType,
+		Action: ActionDie,
+		Actor: events.Actor{
+			ID:         "abc",
+			Attributes: map[string]string{"name": "job-1", "exitCode": "137"},
+		},
+		Time:     1628640000,
+		TimeNano: 1628640000123456789,
+	}
+	event := convertEvent(msg)
+	if event.Type != ContainerEvent || event.Action != ActionDie || event.ID != "abc" {
+		t.Errorf("convertEvent() = %+v", event)
+	}
+	if event.Name() != "job-1" {
+		t.Errorf("Name() = %s, want job-1", event.Name())
+	}
+	if code, ok := event.ExitCode(); !ok || code != 137 {
+		t.Errorf("ExitCode() = %d, %v, want 137, true", code, ok)
+	}
+	if !event.Time.Equal(time.Unix(0, 1628640000123456789)) {
+		t.Errorf("Time = %v", event.Time)
+	}
+	if since := formatSince(event.Time); since != "1628640000.123456789" {
+		t.Errorf("formatSince() = %s", since)
+	}
+}
+
+func TestClient_Subscribe_reconnectBeforeFirstEvent(t *testing.T) {
+	// daemon 的时钟比本地慢，起始时间必须使用 daemon 的时间
+	daemonNow := time.Now().Add(-time.Hour).UTC()
+	sinces := make(chan string, 2)
+	cli := newTestDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/info") {
+			_, _ = w.Write([]byte(`{"SystemTime":"` + daemonNow.Format(time.RFC3339Nano) + `"}`))
+			return
+		}
+		// 没有任何事件就断开连接
+		select {
+		case sinces <- r.URL.Query().Get("since"):
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli.Subscribe(ctx, &SubscribeOption{ReconnectInterval: 10 * time.Millisecond})
+
+	first, second := <-sinces, <-sinces
+	if first != formatSince(daemonNow) || first != second {
+		t.Errorf("since = %q then %q, want the daemon time %s at subscribe", first, second, formatSince(daemonNow))
+	}
+}
+
+func TestClient_Subscribe_infoUnavailable(t *testing.T) {
+	var infos int32
+	daemonNow := time.Date(2021, 8, 11, 0, 0, 0, 0, time.UTC)
+	sinces := make(chan string, 1)
+	cli := newTestDaemon(t, func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/info") {
+			// 订阅时获取失败，重试时成功
+			if atomic.AddInt32(&infos, 1) == 1 {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			_, _ = w.Write([]byte(`{"SystemTime":"` + daemonNow.Format(time.RFC3339Nano) + `"}`))
+			return
+		}
+		select {
+		case sinces <- r.URL.Query().Get("since"):
+		default:
+		}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cli.Subscribe(ctx, &SubscribeOption{ReconnectInterval: 10 * time.Millisecond})
+	if since := <-sinces; since != formatSince(daemonNow) {
+		t.Errorf("since = %q, want %s", since, formatSince(daemonNow))
+	}
+}
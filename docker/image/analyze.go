@@ -0,0 +1,368 @@
+package image
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// Analysis 是对镜像各层内容的离线分析结果
+type Analysis struct {
+	Image  string
+	ID     string
+	Layers []LayerInfo
+	// WastedBytes 是被后续层覆盖或删除、但仍保存在镜像中的文件大小之和
+	WastedBytes int64
+	Wasted      []WastedFile
+	OS          *OSRelease
+	Packages    []Package
+	// Warnings 记录无法分析的内容，例如不支持的包数据库格式
+	Warnings []string
+}
+
+type LayerInfo struct {
+	// DiffID 是未压缩的层内容的 digest
+	DiffID    string
+	CreatedBy string
+	Size      int64
+	Files     []FileEntry
+}
+
+type FileEntry struct {
+	Path string
+	Size int64
+	Mode os.FileMode
+	// Deleted 表示该条目是删除下层文件的 whiteout
+	Deleted bool
+}
+
+type WastedFile struct {
+	Path string
+	Size int64
+	// Layer 是写入该文件的层的下标
+	Layer int
+	// Reason 为 overwritten 或 deleted
+	Reason string
+}
+
+// savedManifest 是 docker save 生成的 manifest.json 中的一项
+type savedManifest struct {
+	Config   string
+	RepoTags []string
+	Layers   []string
+}
+
+// AnalyzeOption 中的 Image 可以是镜像 ID 或 tag
+type AnalyzeOption struct {
+	HostURL string
+	Image   string
+}
+
+// Analyze 使用 opt.HostURL 对应的缓存 client 分析镜像
+func Analyze(ctx context.Context, opt *AnalyzeOption) (*Analysis, error) {
+	c, err := getClient(ctx, opt.HostURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.Analyze(ctx, opt.Image)
+}
+
+// Analyze 导出镜像并离线分析其各层的文件、浪费的空间、操作系统与已安装的软件包
+func (c *Client) Analyze(ctx context.Context, ref string) (*Analysis, error) {
+	resp, err := c.cli.ImageSave(ctx, []string{ref})
+	if err != nil {
+		return nil, err
+	}
+	defer func(resp io.ReadCloser) {
+		_ = resp.Close()
+	}(resp)
+
+	// 导出的 tar 中 manifest.json 通常位于最后，需要先落盘再读取两遍
+	f, err := ioutil.TempFile("", "loclo-image-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}(f)
+	if _, err := io.Copy(f, resp); err != nil {
+		return nil, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	analysis, err := AnalyzeArchive(f)
+	if err != nil {
+		return nil, err
+	}
+	analysis.Image = ref
+	return analysis, nil
+}
+
+// AnalyzeArchive 分析 docker save 格式的镜像归档，归档中只能包含一个镜像
+func AnalyzeArchive(r io.ReadSeeker) (*Analysis, error) {
+	manifest, config, err := readSavedMetadata(r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// 同一个层可能在 manifest 中出现多次，例如两条指令产生了内容相同的层，归档中只保存一份
+	layerIndex := make(map[string][]int, len(manifest.Layers))
+	for i, l := range manifest.Layers {
+		layerIndex[l] = append(layerIndex[l], i)
+	}
+	layers := make([]*layerContent, len(manifest.Layers))
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		indexes, ok := layerIndex[hdr.Name]
+		if !ok {
+			continue
+		}
+		layer, err := readLayer(tr)
+		if err != nil {
+			return nil, fmt.Errorf("read layer %s: %w", hdr.Name, err)
+		}
+		layer.info.Size = hdr.Size
+		for _, i := range indexes {
+			// 每个位置的 DiffID 与 CreatedBy 不同，复制一份 info，文件列表与内容只读，可以共享
+			layers[i] = &layerContent{info: layer.info, contents: layer.contents}
+		}
+	}
+
+	analysis := &Analysis{}
+	if len(manifest.RepoTags) > 0 {
+		analysis.Image = manifest.RepoTags[0]
+	}
+	if config != nil {
+		analysis.ID = config.id
+	}
+	var history []ocispec.History
+	if config != nil {
+		for _, h := range config.History {
+			if !h.EmptyLayer {
+				history = append(history, h)
+			}
+		}
+	}
+	for i, l := range layers {
+		if l == nil {
+			return nil, fmt.Errorf("layer %s not found in archive", manifest.Layers[i])
+		}
+		if config != nil && i < len(config.RootFS.DiffIDs) {
+			l.info.DiffID = config.RootFS.DiffIDs[i].String()
+		}
+		if i < len(history) {
+			l.info.CreatedBy = history[i].CreatedBy
+		}
+		analysis.Layers = append(analysis.Layers, l.info)
+	}
+
+	files := mergeLayers(layers, analysis)
+	analysis.detectPackages(files)
+	return analysis, nil
+}
+
+type savedConfig struct {
+	ocispec.Image
+	id string
+}
+
+func readSavedMetadata(r io.Reader) (*savedManifest, *savedConfig, error) {
+	var (
+		manifests []savedManifest
+		configs   = make(map[string][]byte)
+	)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case hdr.Name == "manifest.json":
+			if err := json.NewDecoder(tr).Decode(&manifests); err != nil {
+				return nil, nil, fmt.Errorf("decode manifest.json: %w", err)
+			}
+		case strings.HasSuffix(hdr.Name, ".json") && !strings.Contains(hdr.Name, "/") && hdr.Name != "repositories":
+			// 旧格式的 config 位于根目录，形如 <id>.json
+			if configs[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+				return nil, nil, err
+			}
+		case strings.HasPrefix(hdr.Name, "blobs/") && hdr.Size < 1<<20:
+			// OCI 布局的 config 位于 blobs 中，只读取较小的 blob
+			if configs[hdr.Name], err = ioutil.ReadAll(tr); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	if len(manifests) != 1 {
+		return nil, nil, fmt.Errorf("archive contains %d images, want 1", len(manifests))
+	}
+	manifest := &manifests[0]
+	raw, ok := configs[manifest.Config]
+	if !ok {
+		return manifest, nil, nil
+	}
+	config := &savedConfig{
+		id: "sha256:" + strings.TrimSuffix(path.Base(manifest.Config), ".json"),
+	}
+	if err := json.Unmarshal(raw, &config.Image); err != nil {
+		return nil, nil, fmt.Errorf("decode image config: %w", err)
+	}
+	return manifest, config, nil
+}
+
+// layerContent 是一个层中的文件列表，以及需要解析的元数据文件的内容
+type layerContent struct {
+	info     LayerInfo
+	contents map[string][]byte
+}
+
+func readLayer(r io.Reader) (*layerContent, error) {
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer func(gz *gzip.Reader) {
+			_ = gz.Close()
+		}(gz)
+		r = gz
+	} else {
+		r = br
+	}
+
+	layer := &layerContent{contents: make(map[string][]byte)}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		name := cleanPath(hdr.Name)
+		if name == "" {
+			continue
+		}
+		dir, base := path.Split(name)
+		switch {
+		case base == whiteoutOpaque:
+			layer.info.Files = append(layer.info.Files, FileEntry{Path: path.Join(dir, whiteoutOpaque), Deleted: true})
+		case strings.HasPrefix(base, whiteoutPrefix):
+			layer.info.Files = append(layer.info.Files, FileEntry{Path: path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)), Deleted: true})
+		default:
+			layer.info.Files = append(layer.info.Files, FileEntry{Path: name, Size: hdr.Size, Mode: hdr.FileInfo().Mode()})
+			if hdr.Typeflag == tar.TypeReg && isMetadataFile(name) {
+				if layer.contents[name], err = ioutil.ReadAll(tr); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return layer, nil
+}
+
+func cleanPath(name string) string {
+	name = path.Clean("/" + name)
+	return strings.TrimPrefix(name, "/")
+}
+
+type visibleFile struct {
+	size    int64
+	layer   int
+	regular bool
+	content []byte
+}
+
+// mergeLayers 按顺序叠加各层，统计被覆盖或删除的文件，返回最终可见的文件
+func mergeLayers(layers []*layerContent, analysis *Analysis) map[string]*visibleFile {
+	files := make(map[string]*visibleFile)
+	waste := func(p string, f *visibleFile, reason string) {
+		if !f.regular || f.size == 0 {
+			return
+		}
+		analysis.WastedBytes += f.size
+		analysis.Wasted = append(analysis.Wasted, WastedFile{Path: p, Size: f.size, Layer: f.layer, Reason: reason})
+	}
+	removeTree := func(dir string, keepDir bool) {
+		prefix := dir + "/"
+		if dir == "." {
+			// 根目录的 opaque whiteout 删除下层的所有文件，文件路径都不带 ./ 前缀
+			prefix = ""
+		}
+		for p, f := range files {
+			if (p == dir && !keepDir) || strings.HasPrefix(p, prefix) {
+				waste(p, f, "deleted")
+				delete(files, p)
+			}
+		}
+	}
+
+	for i, layer := range layers {
+		// whiteout 只作用于下层，先于本层新增的文件处理
+		for _, entry := range layer.info.Files {
+			if !entry.Deleted {
+				continue
+			}
+			if path.Base(entry.Path) == whiteoutOpaque {
+				removeTree(path.Dir(entry.Path), true)
+			} else {
+				removeTree(entry.Path, false)
+			}
+		}
+		for _, entry := range layer.info.Files {
+			if entry.Deleted {
+				continue
+			}
+			if old, ok := files[entry.Path]; ok {
+				waste(entry.Path, old, "overwritten")
+			}
+			files[entry.Path] = &visibleFile{
+				size:    entry.Size,
+				layer:   i,
+				regular: entry.Mode.IsRegular(),
+				content: layer.contents[entry.Path],
+			}
+		}
+	}
+	sort.Slice(analysis.Wasted, func(i, j int) bool {
+		if analysis.Wasted[i].Size != analysis.Wasted[j].Size {
+			return analysis.Wasted[i].Size > analysis.Wasted[j].Size
+		}
+		return analysis.Wasted[i].Path < analysis.Wasted[j].Path
+	})
+	return files
+}
@@ -0,0 +1,181 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type tarFile struct {
+	name    string
+	content string
+	dir     bool
+}
+
+func buildTar(t *testing.T, files []tarFile) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, f := range files {
+		hdr := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), Typeflag: tar.TypeReg}
+		if f.dir {
+			hdr = &tar.Header{Name: f.name + "/", Mode: 0755, Typeflag: tar.TypeDir}
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// buildSavedImage 按 docker save 的格式组装一个镜像归档，manifest.json 位于最后
+func buildSavedImage(t *testing.T, layers ...[]tarFile) []byte {
+	var (
+		files   []tarFile
+		config  ocispec.Image
+		layerFs []string
+	)
+	for i, layer := range layers {
+		content := buildTar(t, layer)
+		name := string(rune('a'+i)) + "/layer.tar"
+		files = append(files, tarFile{name: name, content: string(content)})
+		layerFs = append(layerFs, name)
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, digest.FromBytes(content))
+		config.History = append(config.History, ocispec.History{CreatedBy: "layer " + name}, ocispec.History{CreatedBy: "ENV A=1", EmptyLayer: true})
+	}
+	rawConfig, _ := json.Marshal(config)
+	configName := digest.FromBytes(rawConfig).Encoded() + ".json"
+	manifest, _ := json.Marshal([]savedManifest{{Config: configName, RepoTags: []string{"app:1.0"}, Layers: layerFs}})
+	files = append(files, tarFile{name: configName, content: string(rawConfig)}, tarFile{name: "manifest.json", content: string(manifest)})
+	return buildTar(t, files)
+}
+
+func TestAnalyzeArchive(t *testing.T) {
+	archive := buildSavedImage(t,
+		[]tarFile{
+			{name: "etc", dir: true},
+			{name: "etc/os-release", content: "ID=debian\nVERSION_ID=\"11\"\nPRETTY_NAME=\"Debian GNU/Linux 11 (bullseye)\"\n"},
+			{name: "var/lib/dpkg/status", content: "Package: bash\nStatus: install ok installed\nArchitecture: amd64\nVersion: 5.1-2\nDescription: GNU shell\n more text\n\nPackage: removed\nStatus: deinstall ok config-files\nVersion: 1.0\n"},
+			{name: "opt/big", content: string(make([]byte, 100))},
+			{name: "opt/dir/a", content: string(make([]byte, 50))},
+			{name: "opt/dir/b", content: string(make([]byte, 30))},
+		},
+		[]tarFile{
+			{name: "./opt/big", content: string(make([]byte, 10))},
+			{name: "opt/.wh.dir"},
+		},
+		[]tarFile{
+			{name: "opt/dir", dir: true},
+			{name: "opt/dir/.wh..wh..opq"},
+			{name: "opt/dir/c", content: "c"},
+		},
+	)
+
+	analysis, err := AnalyzeArchive(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("AnalyzeArchive() error = %v", err)
+	}
+	if analysis.Image != "app:1.0" || len(analysis.Layers) != 3 {
+		t.Fatalf("analysis = %+v", analysis)
+	}
+	if l := analysis.Layers[1]; l.CreatedBy != "layer b/layer.tar" || l.DiffID == "" || len(l.Files) != 2 || !l.Files[1].Deleted || l.Files[1].Path != "opt/dir" {
+		t.Errorf("layer = %+v", l)
+	}
+	wantWasted := []WastedFile{
+		{Path: "opt/big", Size: 100, Layer: 0, Reason: "overwritten"},
+		{Path: "opt/dir/a", Size: 50, Layer: 0, Reason: "deleted"},
+		{Path: "opt/dir/b", Size: 30, Layer: 0, Reason: "deleted"},
+	}
+	if !reflect.DeepEqual(analysis.Wasted, wantWasted) || analysis.WastedBytes != 180 {
+		t.Errorf("wasted = %d %+v", analysis.WastedBytes, analysis.Wasted)
+	}
+	if analysis.OS == nil || analysis.OS.ID != "debian" || analysis.OS.VersionID != "11" {
+		t.Errorf("os = %+v", analysis.OS)
+	}
+	wantPackages := []Package{{Name: "bash", Version: "5.1-2", Arch: "amd64", Source: PackageDpkg, PURL: "pkg:deb/debian/bash@5.1-2?arch=amd64"}}
+	if !reflect.DeepEqual(analysis.Packages, wantPackages) {
+		t.Errorf("packages = %+v", analysis.Packages)
+	}
+}
+
+func TestAnalyzeArchive_sharedLayerAndRootOpaque(t *testing.T) {
+	base := buildTar(t, []tarFile{{name: "etc/data", content: string(make([]byte, 20))}})
+	reset := buildTar(t, []tarFile{{name: ".wh..wh..opq"}, {name: "app", content: "a"}})
+
+	// 第一层与第三层内容相同，归档中只有一份，manifest 引用两次
+	var config ocispec.Image
+	for i, layer := range [][]byte{base, reset, base} {
+		config.RootFS.DiffIDs = append(config.RootFS.DiffIDs, digest.FromBytes(layer))
+		config.History = append(config.History, ocispec.History{CreatedBy: fmt.Sprintf("step %d", i)})
+	}
+	rawConfig, _ := json.Marshal(config)
+	configName := digest.FromBytes(rawConfig).Encoded() + ".json"
+	manifest, _ := json.Marshal([]savedManifest{{Config: configName, Layers: []string{"a/layer.tar", "b/layer.tar", "a/layer.tar"}}})
+	archive := buildTar(t, []tarFile{
+		{name: "a/layer.tar", content: string(base)},
+		{name: "b/layer.tar", content: string(reset)},
+		{name: configName, content: string(rawConfig)},
+		{name: "manifest.json", content: string(manifest)},
+	})
+
+	analysis, err := AnalyzeArchive(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatalf("AnalyzeArchive() error = %v", err)
+	}
+	if len(analysis.Layers) != 3 || analysis.Layers[0].CreatedBy != "step 0" || analysis.Layers[2].CreatedBy != "step 2" {
+		t.Fatalf("layers = %+v", analysis.Layers)
+	}
+	// 根目录的 opaque whiteout 删除第一层的所有文件
+	wantWasted := []WastedFile{{Path: "etc/data", Size: 20, Layer: 0, Reason: "deleted"}}
+	if !reflect.DeepEqual(analysis.Wasted, wantWasted) {
+		t.Errorf("wasted = %+v, want %+v", analysis.Wasted, wantWasted)
+	}
+}
+
+func TestAnalysis_SBOM(t *testing.T) {
+	analysis := &Analysis{
+		Image:    "app:1.0",
+		ID:       "sha256:abc",
+		OS:       &OSRelease{ID: "alpine", VersionID: "3.14.2"},
+		Packages: []Package{{Name: "musl", Version: "1.2.2-r3", License: "MIT", Source: PackageApk, PURL: "pkg:apk/alpine/musl@1.2.2-r3"}},
+	}
+
+	spdx, err := analysis.SBOM(SBOMFormatSPDX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc spdxDocument
+	if err := json.Unmarshal(spdx, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Packages) != 2 || doc.Packages[1].ExternalRefs[0].ReferenceLocator != "pkg:apk/alpine/musl@1.2.2-r3" || len(doc.Relationships) != 2 {
+		t.Errorf("spdx = %s", spdx)
+	}
+
+	cdx, err := analysis.SBOM(SBOMFormatCycloneDX)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var bom cycloneDXDocument
+	if err := json.Unmarshal(cdx, &bom); err != nil {
+		t.Fatal(err)
+	}
+	if bom.BOMFormat != "CycloneDX" || len(bom.Components) != 2 || bom.Components[1].Licenses[0].License.Name != "MIT" {
+		t.Errorf("cyclonedx = %s", cdx)
+	}
+
+	if _, err := analysis.SBOM("xml"); err == nil {
+		t.Errorf("SBOM() should reject unknown formats")
+	}
+}
@@ -0,0 +1,233 @@
+package image
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// LabelBuildHash 记录镜像构建上下文的哈希，用于跳过相同内容的重复构建
+const LabelBuildHash = "loclo.build-hash"
+
+// CacheOption 控制 Build 在构建前查找相同构建上下文的已有镜像
+type CacheOption struct {
+	// CheckRegistry 为 true 时，本地没有命中会再检查 registry 中的 Tags，命中时直接拉取
+	CheckRegistry    bool
+	Username         string
+	Password         string
+	InsecureRegistry bool
+}
+
+// ContextHash 计算构建上下文、Dockerfile 与构建参数的哈希。
+// 文件按路径排序后依次计入路径、权限与内容，因此与文件的修改时间无关
+func ContextHash(opt *BuildOption) (string, error) {
+	h := sha256.New()
+	writeField := func(k, v string) {
+		_, _ = fmt.Fprintf(h, "%s=%q\n", k, v)
+	}
+	writeField("version", "1")
+	writeField("dockerfile", filepath.ToSlash(opt.DockerFilePath))
+	writeField("platform", opt.Platform)
+	if len(opt.Dockerfile) > 0 {
+		writeField("generated-dockerfile", string(opt.Dockerfile))
+	}
+	for _, s := range opt.Secrets {
+		writeField("secret", s.ID)
+	}
+	for _, s := range opt.SSH {
+		writeField("ssh", s.ID)
+	}
+
+	root := opt.CtxPath
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		info, err := os.Lstat(path)
+		if err != nil {
+			return "", err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return "", err
+		}
+		writeField("path", filepath.ToSlash(rel))
+		writeField("mode", info.Mode().String())
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return "", err
+			}
+			writeField("link", target)
+		case info.Mode().IsRegular():
+			if err := hashFile(h, path); err != nil {
+				return "", err
+			}
+			_, _ = h.Write([]byte{'\n'})
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func hashFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// findCached 查找带有相同构建哈希的镜像，找到时为其打上 opt.Tags 并返回镜像 ID
+func (c *Client) findCached(ctx context.Context, opt *BuildOption, hash string) (string, bool, error) {
+	images, err := c.cli.ImageList(ctx, types.ImageListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", fmt.Sprintf("%s=%s", LabelBuildHash, hash))),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	if len(images) > 0 {
+		id := images[0].ID
+		return id, true, c.tag(ctx, id, opt.Tags)
+	}
+
+	if !opt.Cache.CheckRegistry {
+		return "", false, nil
+	}
+	registry := newRegistryClient(opt.Cache.Username, opt.Cache.Password, opt.Cache.InsecureRegistry)
+	for _, tag := range opt.Tags {
+		named, err := parseTag(tag)
+		if err != nil {
+			return "", false, err
+		}
+		id, labels, err := registry.imageConfig(ctx, named, named.Tag())
+		if err != nil || labels[LabelBuildHash] != hash {
+			// registry 中没有该 tag 或内容不同时继续构建
+			continue
+		}
+		if err := c.pull(ctx, tag, opt.Cache); err != nil {
+			return "", false, err
+		}
+		return id, true, c.tag(ctx, tag, opt.Tags)
+	}
+	return "", false, nil
+}
+
+func (c *Client) tag(ctx context.Context, source string, tags []string) error {
+	for _, tag := range tags {
+		if err := c.cli.ImageTag(ctx, source, tag); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Client) pull(ctx context.Context, ref string, opt *CacheOption) error {
+	authConfig := types.AuthConfig{
+		Username: opt.Username,
+		Password: opt.Password,
+	}
+	encodedJSON, _ := json.Marshal(authConfig)
+	resp, err := c.cli.ImagePull(ctx, ref, types.ImagePullOptions{
+		RegistryAuth: base64.URLEncoding.EncodeToString(encodedJSON),
+	})
+	if err != nil {
+		return err
+	}
+	defer func(resp io.ReadCloser) {
+		_ = resp.Close()
+	}(resp)
+	_, err = handleOutput(resp)
+	return err
+}
+
+// cachedOutput 生成与 daemon 构建输出格式相同的内容，便于 ParseImageID 解析
+func cachedOutput(id, hash string) string {
+	stream, _ := json.Marshal(map[string]string{
+		"stream": fmt.Sprintf("Using cached image %s with build hash %s\n", id, hash),
+	})
+	aux, _ := json.Marshal(map[string]interface{}{
+		"aux": cachedResult{BuildResult: types.BuildResult{ID: id}, Cached: true},
+	})
+	return string(stream) + "\n" + string(aux) + "\n"
+}
+
+// cachedResult 在 BuildResult 的基础上标记镜像来自缓存，ParseImageID 仍然可以读取其中的 ID
+type cachedResult struct {
+	types.BuildResult
+	Cached bool
+}
+
+// IsCachedOutput 判断 Build 的输出是否表示命中了缓存，此时镜像在构建前就已经存在
+func IsCachedOutput(output string) bool {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		var line struct {
+			Aux *cachedResult `json:"aux"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err == nil && line.Aux != nil && line.Aux.Cached {
+			return true
+		}
+	}
+	return false
+}
+
+// imageConfig 读取 registry 中镜像的 config，返回镜像 ID 与 label
+func (r *registryClient) imageConfig(ctx context.Context, named reference.Named, tag string) (string, map[string]string, error) {
+	accept := strings.Join([]string{MediaTypeDockerManifest, ocispec.MediaTypeImageManifest}, ", ")
+	body, err := r.get(ctx, named, r.manifestURL(named, tag), accept)
+	if err != nil {
+		return "", nil, err
+	}
+	var manifest struct {
+		Config ocispec.Descriptor `json:"config"`
+	}
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return "", nil, err
+	}
+	if err := manifest.Config.Digest.Validate(); err != nil {
+		return "", nil, fmt.Errorf("invalid config digest for %s:%s: %w", named.Name(), tag, err)
+	}
+
+	body, err = r.get(ctx, named, r.blobURL(named, manifest.Config.Digest), "")
+	if err != nil {
+		return "", nil, err
+	}
+	if digest.FromBytes(body) != manifest.Config.Digest {
+		return "", nil, fmt.Errorf("config digest mismatch")
+	}
+	var config ocispec.Image
+	if err := json.Unmarshal(body, &config); err != nil {
+		return "", nil, err
+	}
+	return manifest.Config.Digest.String(), config.Config.Labels, nil
+}
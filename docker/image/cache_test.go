@@ -0,0 +1,163 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func writeContext(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestContextHash(t *testing.T) {
+	files := map[string]string{
+		"Dockerfile":  "FROM alpine\nCOPY . /app\n",
+		"src/main.go": "package main\n",
+	}
+	base := writeContext(t, files)
+	baseHash, err := ContextHash(&BuildOption{CtxPath: base, DockerFilePath: "Dockerfile", Tags: []string{"app:1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		files    map[string]string
+		opt      BuildOption
+		touch    bool
+		wantSame bool
+	}{
+		{name: "other tags", files: files, opt: BuildOption{DockerFilePath: "Dockerfile", Tags: []string{"app:2"}}, wantSame: true},
+		{name: "mtime changed", files: files, opt: BuildOption{DockerFilePath: "Dockerfile"}, touch: true, wantSame: true},
+		{name: "content changed", files: map[string]string{"Dockerfile": files["Dockerfile"], "src/main.go": "package foo\n"}, opt: BuildOption{DockerFilePath: "Dockerfile"}},
+		{name: "file renamed", files: map[string]string{"Dockerfile": files["Dockerfile"], "src/app.go": files["src/main.go"]}, opt: BuildOption{DockerFilePath: "Dockerfile"}},
+		{name: "platform", files: files, opt: BuildOption{DockerFilePath: "Dockerfile", Platform: "linux/arm64"}},
+		{name: "secret ids", files: files, opt: BuildOption{DockerFilePath: "Dockerfile", Secrets: []BuildSecret{{ID: "token"}}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opt.CtxPath = writeContext(t, tt.files)
+			if tt.touch {
+				later := time.Now().Add(time.Hour)
+				_ = os.Chtimes(filepath.Join(tt.opt.CtxPath, "Dockerfile"), later, later)
+			}
+			hash, err := ContextHash(&tt.opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (hash == baseHash) != tt.wantSame {
+				t.Errorf("ContextHash() same = %v, want %v", hash == baseHash, tt.wantSame)
+			}
+		})
+	}
+}
+
+func Test_cachedOutput(t *testing.T) {
+	output := cachedOutput("sha256:abc", "123")
+	if id, ok := ParseImageID(output); !ok || id != "sha256:abc" {
+		t.Errorf("ParseImageID() = %s, %v", id, ok)
+	}
+	if !IsCachedOutput(output) {
+		t.Errorf("IsCachedOutput() should be true for a cache hit")
+	}
+	built := `{"stream":"Step 1/1 : FROM alpine"}` + "\n" + `{"aux":{"ID":"sha256:abc"}}` + "\n"
+	if IsCachedOutput(built) {
+		t.Errorf("IsCachedOutput() should be false for a build")
+	}
+}
+
+func Test_imageConfig(t *testing.T) {
+	config, _ := json.Marshal(ocispec.Image{
+		Config: ocispec.ImageConfig{Labels: map[string]string{LabelBuildHash: "h1"}},
+	})
+	configDigest := digest.FromBytes(config)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v2/library/app/manifests/1.0", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"schemaVersion": 2,
+			"config":        ocispec.Descriptor{MediaType: ocispec.MediaTypeImageConfig, Digest: configDigest, Size: int64(len(config))},
+		})
+	})
+	mux.HandleFunc("/v2/library/app/blobs/"+configDigest.String(), func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(config)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tag, err := parseTag(strings.TrimPrefix(server.URL, "http://") + "/library/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, labels, err := newRegistryClient("", "", true).imageConfig(context.Background(), tag, tag.Tag())
+	if err != nil {
+		t.Fatalf("imageConfig() error = %v", err)
+	}
+	if id != configDigest.String() || labels[LabelBuildHash] != "h1" {
+		t.Errorf("imageConfig() = %s, %v", id, labels)
+	}
+}
+
+func TestClient_Build_cacheHit(t *testing.T) {
+	var infoCalls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.41")
+		switch {
+		case r.URL.Path == "/_ping":
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			atomic.AddInt32(&infoCalls, 1)
+			_, _ = w.Write([]byte(`{}`))
+		case strings.HasSuffix(r.URL.Path, "/images/json"):
+			_, _ = w.Write([]byte(`[{"Id":"sha256:cached"}]`))
+		case strings.HasSuffix(r.URL.Path, "/tag"):
+			w.WriteHeader(http.StatusCreated)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	cli, err := docker.NewClient(context.Background(), &docker.InitOption{Host: "tcp://" + strings.TrimPrefix(server.URL, "http://")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	opt := &BuildOption{
+		CtxPath: writeContext(t, map[string]string{"Dockerfile": "FROM alpine\n"}),
+		Tags:    []string{"app:1.0"},
+		Cache:   &CacheOption{},
+	}
+	output, err := NewClient(cli).Build(context.Background(), opt)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	if id, ok := ParseImageID(output); !ok || id != "sha256:cached" {
+		t.Errorf("ParseImageID() = %s, %v, want the cached image", id, ok)
+	}
+	if n := atomic.LoadInt32(&infoCalls); n != 0 {
+		t.Errorf("Build() queried the daemon info %d times on a cache hit", n)
+	}
+}
@@ -1,6 +1,7 @@
 package image
 
 import (
+	"archive/tar"
 	"bufio"
 	"bytes"
 	"context"
@@ -8,33 +9,104 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/loheagn/loclo/docker"
 )
 
+// Client 在 docker.Client 上提供镜像相关的操作
+type Client struct {
+	cli *docker.Client
+}
+
+func NewClient(cli *docker.Client) *Client {
+	return &Client{cli: cli}
+}
+
+func getClient(ctx context.Context, hostURL string) (*Client, error) {
+	cli, err := docker.GetClient(ctx, &docker.InitOption{
+		Host: hostURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(cli), nil
+}
+
 type BuildOption struct {
 	HostURL        string
 	DockerFilePath string
 	CtxPath        string
 	Tags           []string
+	// Platform 为空时构建 daemon 本身的平台，例如 linux/arm64
+	Platform string
+	// Secrets 与 SSH 不为空时使用 BuildKit 构建
+	Secrets []BuildSecret
+	SSH     []BuildSSH
+	// Source 与 Revision 记录在镜像的 label 中，为空时从 CtxPath 所在的 git 仓库读取
+	Source   string
+	Revision string
+	// Cache 不为空时为镜像记录构建上下文的哈希，并在已有相同哈希的镜像时跳过构建
+	Cache *CacheOption
+	// Dockerfile 不为空时作为 DockerFilePath 加入构建上下文，不会写入 CtxPath，由 EnsureDockerfile 设置
+	Dockerfile []byte
 }
 
+// Build 使用 opt.HostURL 对应的缓存 client 构建镜像
 func Build(ctx context.Context, opt *BuildOption) (string, error) {
-	cli, err := docker.GetClient(&docker.InitOption{
-		Host: opt.HostURL,
-	})
+	c, err := getClient(ctx, opt.HostURL)
 	if err != nil {
 		return "", err
 	}
+	return c.Build(ctx, opt)
+}
+
+// Build 构建镜像，忽略 opt.HostURL
+func (c *Client) Build(ctx context.Context, opt *BuildOption) (string, error) {
+	var hash string
+	if opt.Cache != nil {
+		var err error
+		if hash, err = ContextHash(opt); err != nil {
+			return "", err
+		}
+		id, ok, err := c.findCached(ctx, opt, hash)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return cachedOutput(id, hash), nil
+		}
+	}
+
+	// 生成 labels 需要查询 daemon 与 git，命中缓存时不需要
 	buildOpts := types.ImageBuildOptions{
 		Dockerfile: opt.DockerFilePath,
 		Tags:       opt.Tags,
+		Platform:   opt.Platform,
+		Labels:     c.buildLabels(ctx, opt),
+	}
+	if hash != "" {
+		buildOpts.Labels[LabelBuildHash] = hash
+	}
+	buildCtx, err := buildContext(opt)
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		_ = buildCtx.Close()
+	}()
+
+	closeSession, err := c.withSession(ctx, opt, &buildOpts)
+	if err != nil {
+		return "", err
 	}
-	buildCtx, _ := archive.TarWithOptions(opt.CtxPath, &archive.TarOptions{})
+	defer closeSession()
 
-	resp, err := cli.ImageBuild(ctx, buildCtx, buildOpts)
+	resp, err := c.cli.ImageBuild(ctx, buildCtx, buildOpts)
 	if err != nil {
 		return "", err
 	}
@@ -45,6 +117,36 @@ func Build(ctx context.Context, opt *BuildOption) (string, error) {
 	return handleOutput(resp.Body)
 }
 
+// buildContext 打包 opt.CtxPath 作为构建上下文，opt.Dockerfile 不为空时替换其中的 Dockerfile
+func buildContext(opt *BuildOption) (io.ReadCloser, error) {
+	buildCtx, err := archive.TarWithOptions(opt.CtxPath, &archive.TarOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if len(opt.Dockerfile) == 0 {
+		return buildCtx, nil
+	}
+	return archive.ReplaceFileTarWrapper(buildCtx, map[string]archive.TarModifierFunc{
+		dockerfileName(opt): func(string, *tar.Header, io.Reader) (*tar.Header, []byte, error) {
+			// 返回的 header 的名称与大小由 ReplaceFileTarWrapper 设置
+			return &tar.Header{Mode: 0644, Typeflag: tar.TypeReg}, opt.Dockerfile, nil
+		},
+	}), nil
+}
+
+// dockerfileName 返回 Dockerfile 在构建上下文中的路径
+func dockerfileName(opt *BuildOption) string {
+	if opt.DockerFilePath == "" {
+		return "Dockerfile"
+	}
+	return path.Clean(filepath.ToSlash(opt.DockerFilePath))
+}
+
+// dockerfilePath 返回 Dockerfile 在本地文件系统中的路径
+func dockerfilePath(opt *BuildOption) string {
+	return filepath.Join(opt.CtxPath, filepath.FromSlash(dockerfileName(opt)))
+}
+
 type PushOption struct {
 	HostURL  string
 	Tag      string
@@ -52,21 +154,24 @@ type PushOption struct {
 	Password string
 }
 
+// Push 使用 opt.HostURL 对应的缓存 client 推送镜像
 func Push(ctx context.Context, opt *PushOption) (string, error) {
-	cli, err := docker.GetClient(&docker.InitOption{
-		Host: opt.HostURL,
-	})
+	c, err := getClient(ctx, opt.HostURL)
 	if err != nil {
 		return "", err
 	}
+	return c.Push(ctx, opt)
+}
 
+// Push 推送镜像，忽略 opt.HostURL
+func (c *Client) Push(ctx context.Context, opt *PushOption) (string, error) {
 	authConfig := types.AuthConfig{
 		Username: opt.Username,
 		Password: opt.Password,
 	}
 	encodedJSON, _ := json.Marshal(authConfig)
 	authStr := base64.URLEncoding.EncodeToString(encodedJSON)
-	resp, err := cli.ImagePush(ctx, opt.Tag, types.ImagePushOptions{
+	resp, err := c.cli.ImagePush(ctx, opt.Tag, types.ImagePushOptions{
 		All:           false,
 		RegistryAuth:  authStr,
 		PrivilegeFunc: nil,
@@ -80,6 +185,30 @@ func Push(ctx context.Context, opt *PushOption) (string, error) {
 	return handleOutput(resp)
 }
 
+// Remove 删除镜像或 tag，同时清理不再被引用的父镜像
+func (c *Client) Remove(ctx context.Context, ref string) error {
+	_, err := c.cli.ImageRemove(ctx, ref, types.ImageRemoveOptions{PruneChildren: true})
+	return err
+}
+
+// ParseImageID 从 Build 的输出中解析构建得到的镜像 ID
+func ParseImageID(output string) (string, bool) {
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	var id string
+	for scanner.Scan() {
+		var line struct {
+			Aux *types.BuildResult `json:"aux"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil || line.Aux == nil {
+			continue
+		}
+		if line.Aux.ID != "" {
+			id = line.Aux.ID
+		}
+	}
+	return id, id != ""
+}
+
 type ErrorLine struct {
 	Error       string      `json:"error"`
 	ErrorDetail ErrorDetail `json:"errorDetail"`
@@ -93,3 +93,34 @@ func TestBuild(t *testing.T) {
 		})
 	}
 }
+
+func TestParseImageID(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   string
+		wantOk bool
+	}{
+		{
+			name: "built",
+			output: `{"stream":"Step 1/2 : FROM ubuntu:20.04"}
+{"aux":{"ID":"sha256:0123456789abcdef"}}
+{"stream":"Successfully built 0123456789ab\n"}
+`,
+			want:   "sha256:0123456789abcdef",
+			wantOk: true,
+		},
+		{
+			name:   "no-aux",
+			output: `{"stream":"Step 1/2 : FROM ubuntu:20.04"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseImageID(tt.output)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ParseImageID() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	"github.com/moby/buildkit/frontend/dockerfile/parser"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Lint 规则
+const (
+	RuleMissingFrom = "missing-from"
+	RuleLatestTag   = "latest-tag"
+	RuleRemoteAdd   = "remote-add"
+	RuleRootUser    = "root-user"
+	RuleSecretInEnv = "secret-in-env"
+)
+
+// Finding 是 Dockerfile 检查发现的一个问题，Line 从 1 开始
+type Finding struct {
+	Line     int
+	Rule     string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("line %d: [%s] %s: %s", f.Line, f.Severity, f.Rule, f.Message)
+}
+
+var secretNamePattern = regexp.MustCompile(`(?i)(passw(or)?d|secret|token|api_?key|private_?key|access_?key|credential)`)
+
+// LintBuild 检查 opt 对应的 Dockerfile，opt.Dockerfile 不为空时检查生成的内容
+func LintBuild(opt *BuildOption) ([]Finding, error) {
+	if len(opt.Dockerfile) > 0 {
+		return LintDockerfile(bytes.NewReader(opt.Dockerfile))
+	}
+	f, err := os.Open(dockerfilePath(opt))
+	if err != nil {
+		return nil, err
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(f)
+	return LintDockerfile(f)
+}
+
+// LintDockerfile 解析 Dockerfile 并返回按行号排列的问题，
+// 只有 Dockerfile 无法解析时才返回 error
+func LintDockerfile(r io.Reader) ([]Finding, error) {
+	result, err := parser.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		findings []Finding
+		stages   = make(map[string]bool)
+		seenFrom bool
+		// lastFrom 与 lastUser 记录最后一个构建阶段的 FROM 与 USER
+		lastFrom *parser.Node
+		lastUser *parser.Node
+	)
+	add := func(node *parser.Node, rule string, severity Severity, format string, args ...interface{}) {
+		findings = append(findings, Finding{
+			Line:     node.StartLine,
+			Rule:     rule,
+			Severity: severity,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, node := range result.AST.Children {
+		args := nodeArgs(node)
+		switch node.Value {
+		case "from":
+			seenFrom = true
+			lastFrom, lastUser = node, nil
+			if len(args) == 0 {
+				continue
+			}
+			if msg, ok := checkBaseImage(args[0], stages); !ok {
+				add(node, RuleLatestTag, SeverityWarning, "%s", msg)
+			}
+			if len(args) == 3 && strings.EqualFold(args[1], "as") {
+				stages[strings.ToLower(args[2])] = true
+			}
+		case "arg":
+			// FROM 之前的 ARG 是合法的
+			for _, arg := range args {
+				name := strings.SplitN(arg, "=", 2)[0]
+				if secretNamePattern.MatchString(name) {
+					add(node, RuleSecretInEnv, SeverityError, "ARG %s looks like a secret and is recorded in the image history, use a build secret instead", name)
+				}
+			}
+		default:
+			if !seenFrom {
+				add(node, RuleMissingFrom, SeverityError, "%s before the first FROM", strings.ToUpper(node.Value))
+				seenFrom = true
+			}
+		}
+
+		switch node.Value {
+		case "env":
+			for i := 0; i+1 < len(args); i += 2 {
+				if secretNamePattern.MatchString(args[i]) {
+					add(node, RuleSecretInEnv, SeverityError, "ENV %s looks like a secret and is stored in the image, pass it at runtime instead", args[i])
+				}
+			}
+		case "add":
+			if len(args) < 2 {
+				continue
+			}
+			for _, src := range args[:len(args)-1] {
+				if strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") {
+					add(node, RuleRemoteAdd, SeverityWarning, "ADD of remote URL %s, use RUN curl/wget with checksum verification instead", src)
+				}
+			}
+		case "user":
+			lastUser = node
+		}
+	}
+
+	if !seenFrom {
+		findings = append(findings, Finding{
+			Line:     1,
+			Rule:     RuleMissingFrom,
+			Severity: SeverityError,
+			Message:  "no FROM instruction",
+		})
+	}
+	if lastFrom != nil {
+		if lastUser == nil {
+			add(lastFrom, RuleRootUser, SeverityWarning, "final stage runs as root, add a USER instruction")
+		} else if args := nodeArgs(lastUser); len(args) > 0 && isRootUser(args[0]) {
+			add(lastUser, RuleRootUser, SeverityWarning, "final stage runs as %s", args[0])
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+func nodeArgs(node *parser.Node) []string {
+	var args []string
+	for n := node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+	return args
+}
+
+// checkBaseImage 检查基础镜像是否固定了版本，stages 中的构建阶段、scratch 与包含变量的镜像不检查
+func checkBaseImage(image string, stages map[string]bool) (string, bool) {
+	if stages[strings.ToLower(image)] || image == "scratch" || strings.Contains(image, "$") {
+		return "", true
+	}
+	named, err := reference.ParseNormalizedNamed(image)
+	if err != nil {
+		return fmt.Sprintf("invalid base image %s: %v", image, err), false
+	}
+	if _, ok := named.(reference.Digested); ok {
+		return "", true
+	}
+	tagged, ok := named.(reference.Tagged)
+	if !ok {
+		return fmt.Sprintf("base image %s has no tag and resolves to latest, pin a version", image), false
+	}
+	if tagged.Tag() == "latest" {
+		return fmt.Sprintf("base image %s uses the latest tag, pin a version", image), false
+	}
+	return "", true
+}
+
+func isRootUser(user string) bool {
+	name := strings.SplitN(user, ":", 2)[0]
+	return name == "root" || name == "0"
+}
@@ -0,0 +1,73 @@
+package image
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLintDockerfile(t *testing.T) {
+	type hit struct {
+		line int
+		rule string
+	}
+	tests := []struct {
+		name       string
+		dockerfile string
+		want       []hit
+	}{
+		{
+			name:       "clean",
+			dockerfile: "ARG VERSION=20.04\nFROM ubuntu:${VERSION}\nUSER app\n",
+		},
+		{
+			name:       "missing from",
+			dockerfile: "RUN echo hi\n",
+			want:       []hit{{1, RuleMissingFrom}},
+		},
+		{
+			name:       "latest and untagged",
+			dockerfile: "FROM golang AS build\nFROM alpine:latest\nCOPY --from=build /app /app\nUSER nobody\n",
+			want:       []hit{{1, RuleLatestTag}, {2, RuleLatestTag}},
+		},
+		{
+			name:       "stage reference and digest",
+			dockerfile: "FROM golang:1.17 AS build\nFROM build\nFROM alpine@sha256:" + strings.Repeat("a", 64) + "\nUSER 1000\n",
+		},
+		{
+			name:       "remote add",
+			dockerfile: "FROM alpine:3.14\nADD --chown=app https://example.com/a.tgz ./local /opt/\nUSER app\n",
+			want:       []hit{{2, RuleRemoteAdd}},
+		},
+		{
+			name:       "root user",
+			dockerfile: "FROM alpine:3.14 AS build\nUSER app\nFROM alpine:3.14\n\nUSER root:root\n",
+			want:       []hit{{5, RuleRootUser}},
+		},
+		{
+			name:       "no user",
+			dockerfile: "FROM alpine:3.14\nRUN true\n",
+			want:       []hit{{1, RuleRootUser}},
+		},
+		{
+			name:       "secrets",
+			dockerfile: "FROM alpine:3.14\nARG GITHUB_TOKEN\nENV DB_PASSWORD=123 \\\n    MODE=dev\nENV API_KEY abc\nUSER app\n",
+			want:       []hit{{2, RuleSecretInEnv}, {3, RuleSecretInEnv}, {5, RuleSecretInEnv}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings, err := LintDockerfile(strings.NewReader(tt.dockerfile))
+			if err != nil {
+				t.Fatalf("LintDockerfile() error = %v", err)
+			}
+			var got []hit
+			for _, f := range findings {
+				got = append(got, hit{f.Line, f.Rule})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("LintDockerfile() = %v, want %v", findings, tt.want)
+			}
+		})
+	}
+}
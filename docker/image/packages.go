@@ -0,0 +1,214 @@
+package image
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+)
+
+// 包管理器
+const (
+	PackageDpkg = "dpkg"
+	PackageApk  = "apk"
+	PackageRpm  = "rpm"
+)
+
+type OSRelease struct {
+	ID         string
+	VersionID  string
+	PrettyName string
+}
+
+type Package struct {
+	Name    string
+	Version string
+	Arch    string
+	License string
+	// Source 是记录该软件包的包管理器，如 dpkg、apk、rpm
+	Source string
+	// PURL 是 package URL，例如 pkg:deb/debian/bash@5.1-2?arch=amd64
+	PURL string
+}
+
+const (
+	dpkgStatus     = "var/lib/dpkg/status"
+	dpkgStatusDir  = "var/lib/dpkg/status.d/"
+	apkInstalled   = "lib/apk/db/installed"
+	rpmPackages    = "var/lib/rpm/Packages"
+	rpmSysPackages = "usr/lib/sysimage/rpm/Packages"
+)
+
+var osReleaseFiles = []string{"etc/os-release", "usr/lib/os-release"}
+
+// isMetadataFile 判断分析时是否需要读取该文件的内容
+func isMetadataFile(name string) bool {
+	switch name {
+	case osReleaseFiles[0], osReleaseFiles[1], dpkgStatus, apkInstalled, rpmPackages, rpmSysPackages:
+		return true
+	}
+	return strings.HasPrefix(name, dpkgStatusDir)
+}
+
+func (a *Analysis) detectPackages(files map[string]*visibleFile) {
+	for _, name := range osReleaseFiles {
+		if f, ok := files[name]; ok && f.content != nil {
+			a.OS = parseOSRelease(f.content)
+			break
+		}
+	}
+
+	var names []string
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		f := files[name]
+		var (
+			pkgs []Package
+			err  error
+		)
+		switch {
+		case name == dpkgStatus || strings.HasPrefix(name, dpkgStatusDir):
+			pkgs = parseDpkgStatus(f.content)
+		case name == apkInstalled:
+			pkgs = parseApkInstalled(f.content)
+		case name == rpmPackages || name == rpmSysPackages:
+			pkgs, err = parseRpmDB(f.content)
+		case path.Base(name) == "rpmdb.sqlite" || path.Base(name) == "Packages.db":
+			err = fmt.Errorf("unsupported rpm database format")
+		default:
+			continue
+		}
+		if err != nil {
+			a.Warnings = append(a.Warnings, fmt.Sprintf("%s: %v", name, err))
+		}
+		a.Packages = append(a.Packages, pkgs...)
+	}
+
+	for i := range a.Packages {
+		a.Packages[i].PURL = a.purl(a.Packages[i])
+	}
+	sort.SliceStable(a.Packages, func(i, j int) bool {
+		return a.Packages[i].Name < a.Packages[j].Name
+	})
+}
+
+func (a *Analysis) purl(p Package) string {
+	var typ, namespace string
+	switch p.Source {
+	case PackageDpkg:
+		typ, namespace = "deb", "debian"
+	case PackageApk:
+		typ, namespace = "apk", "alpine"
+	case PackageRpm:
+		typ, namespace = "rpm", "redhat"
+	}
+	if a.OS != nil && a.OS.ID != "" {
+		namespace = a.OS.ID
+	}
+	s := fmt.Sprintf("pkg:%s/%s/%s@%s", typ, namespace, url.PathEscape(p.Name), url.PathEscape(p.Version))
+	if p.Arch != "" {
+		s += "?arch=" + url.QueryEscape(p.Arch)
+	}
+	return s
+}
+
+func parseOSRelease(content []byte) *OSRelease {
+	release := &OSRelease{}
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(kv[1], `"'`)
+		switch kv[0] {
+		case "ID":
+			release.ID = value
+		case "VERSION_ID":
+			release.VersionID = value
+		case "PRETTY_NAME":
+			release.PrettyName = value
+		}
+	}
+	return release
+}
+
+// parseDpkgStatus 解析 dpkg 的 status 文件，只返回已安装的软件包
+func parseDpkgStatus(content []byte) []Package {
+	var pkgs []Package
+	for _, paragraph := range splitParagraphs(content) {
+		fields := make(map[string]string)
+		var last string
+		for _, line := range strings.Split(paragraph, "\n") {
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				// 续行，只在多行字段（如 Description）中出现
+				fields[last] += "\n" + strings.TrimSpace(line)
+				continue
+			}
+			kv := strings.SplitN(line, ":", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			last = kv[0]
+			fields[last] = strings.TrimSpace(kv[1])
+		}
+		// distroless 的 status.d 中没有 Status 字段
+		if status, ok := fields["Status"]; ok && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+		if fields["Package"] == "" {
+			continue
+		}
+		pkgs = append(pkgs, Package{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			Arch:    fields["Architecture"],
+			Source:  PackageDpkg,
+		})
+	}
+	return pkgs
+}
+
+// parseApkInstalled 解析 apk 的 installed 数据库
+func parseApkInstalled(content []byte) []Package {
+	var pkgs []Package
+	for _, paragraph := range splitParagraphs(content) {
+		p := Package{Source: PackageApk}
+		for _, line := range strings.Split(paragraph, "\n") {
+			if len(line) < 2 || line[1] != ':' {
+				continue
+			}
+			switch value := line[2:]; line[0] {
+			case 'P':
+				p.Name = value
+			case 'V':
+				p.Version = value
+			case 'A':
+				p.Arch = value
+			case 'L':
+				p.License = value
+			}
+		}
+		if p.Name != "" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+func splitParagraphs(content []byte) []string {
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	var paragraphs []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.Trim(p, "\n"); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	return paragraphs
+}
@@ -0,0 +1,110 @@
+package image
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+func Test_parseApkInstalled(t *testing.T) {
+	db := "C:Q1abc=\nP:musl\nV:1.2.2-r3\nA:x86_64\nL:MIT\n\nP:busybox\nV:1.33.1-r3\nA:x86_64\nL:GPL-2.0-only\n"
+	want := []Package{
+		{Name: "musl", Version: "1.2.2-r3", Arch: "x86_64", License: "MIT", Source: PackageApk},
+		{Name: "busybox", Version: "1.33.1-r3", Arch: "x86_64", License: "GPL-2.0-only", Source: PackageApk},
+	}
+	if got := parseApkInstalled([]byte(db)); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseApkInstalled() = %+v", got)
+	}
+}
+
+// rpmHeader 组装一个只包含字符串与 int32 标签的 header blob
+func rpmHeader(strs map[uint32]string, epoch int) []byte {
+	var index, data []byte
+	entry := func(tag, typ uint32, value []byte) {
+		e := make([]byte, 16)
+		binary.BigEndian.PutUint32(e, tag)
+		binary.BigEndian.PutUint32(e[4:], typ)
+		binary.BigEndian.PutUint32(e[8:], uint32(len(data)))
+		binary.BigEndian.PutUint32(e[12:], 1)
+		index = append(index, e...)
+		data = append(data, value...)
+	}
+	for _, tag := range []uint32{rpmTagName, rpmTagVersion, rpmTagRelease, rpmTagArch, rpmTagLicense} {
+		if s, ok := strs[tag]; ok {
+			entry(tag, rpmTypeString, append([]byte(s), 0))
+		}
+	}
+	if epoch >= 0 {
+		v := make([]byte, 4)
+		binary.BigEndian.PutUint32(v, uint32(epoch))
+		entry(rpmTagEpoch, rpmTypeInt32, v)
+	}
+	blob := make([]byte, 8)
+	binary.BigEndian.PutUint32(blob, uint32(len(index)/16))
+	binary.BigEndian.PutUint32(blob[4:], uint32(len(data)))
+	return append(append(blob, index...), data...)
+}
+
+// bdbHash 组装一个页大小为 512 的 Berkeley DB hash 数据库，
+// 第一个 value 内联存放在 hash 页中，第二个 value 存放在两个 overflow 页中
+func bdbHash(inline, overflow []byte) []byte {
+	const pageSize = 512
+	order := binary.LittleEndian
+	db := make([]byte, pageSize*4)
+	order.PutUint32(db[12:], bdbHashMagic)
+	order.PutUint32(db[20:], pageSize)
+
+	hash := db[pageSize : 2*pageSize]
+	hash[25] = bdbPageHash
+	order.PutUint16(hash[20:], 4)
+	var items [][]byte
+	items = append(items, []byte{bdbItemKeyData, 1, 0, 0, 0}, append([]byte{bdbItemKeyData}, inline...))
+	off := make([]byte, 12)
+	off[0] = bdbItemOffPage
+	order.PutUint32(off[4:], 2)
+	order.PutUint32(off[8:], uint32(len(overflow)))
+	items = append(items, []byte{bdbItemKeyData, 2, 0, 0, 0}, off)
+	end := pageSize
+	for i, item := range items {
+		end -= len(item)
+		copy(hash[end:], item)
+		order.PutUint16(hash[bdbPageHeaderSize+i*2:], uint16(end))
+	}
+
+	first, second := overflow[:200], overflow[200:]
+	for i, chunk := range [][]byte{first, second} {
+		p := db[(2+i)*pageSize : (3+i)*pageSize]
+		p[25] = bdbPageOverflow
+		order.PutUint16(p[22:], uint16(len(chunk)))
+		if i == 0 {
+			order.PutUint32(p[16:], 3)
+		}
+		copy(p[bdbPageHeaderSize:], chunk)
+	}
+	return db
+}
+
+func Test_parseRpmDB(t *testing.T) {
+	bash := rpmHeader(map[uint32]string{rpmTagName: "bash", rpmTagVersion: "4.4.20", rpmTagRelease: "1.el8_4", rpmTagArch: "x86_64", rpmTagLicense: "GPLv3+"}, -1)
+	long := make([]byte, 300)
+	for i := range long {
+		long[i] = 'x'
+	}
+	openssl := rpmHeader(map[uint32]string{rpmTagName: "openssl", rpmTagVersion: "1.1.1g", rpmTagRelease: "15.el8", rpmTagArch: "x86_64", rpmTagLicense: string(long)}, 1)
+
+	got, err := parseRpmDB(bdbHash(bash, openssl))
+	if err != nil {
+		t.Fatalf("parseRpmDB() error = %v", err)
+	}
+	want := []Package{
+		{Name: "bash", Version: "4.4.20-1.el8_4", Arch: "x86_64", License: "GPLv3+", Source: PackageRpm},
+		{Name: "openssl", Version: "1:1.1.1g-15.el8", Arch: "x86_64", License: string(long), Source: PackageRpm},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseRpmDB() = %+v", got)
+	}
+
+	if _, err := parseRpmDB(make([]byte, 1024)); err == nil {
+		t.Errorf("parseRpmDB() should reject non bdb data")
+	}
+}
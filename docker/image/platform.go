@@ -0,0 +1,180 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+type MultiPlatformOption struct {
+	// BuildOption.Tags 是最终 manifest list 的 tag，BuildOption.Platform 会被忽略
+	BuildOption
+	// Platforms 例如 ["linux/amd64", "linux/arm64", "linux/arm/v7"]
+	Platforms []string
+	Username  string
+	Password  string
+	// InsecureRegistry 为 true 时使用 http 访问 registry
+	InsecureRegistry bool
+}
+
+type PlatformResult struct {
+	Platform string
+	// Tags 是该平台的镜像被推送时使用的 tag，即在最终 tag 后追加平台后缀
+	Tags   []string
+	Output string
+}
+
+type MultiPlatformResult struct {
+	Platforms []PlatformResult
+	// Digests 记录每个最终 tag 对应的 manifest list 的 digest
+	Digests map[string]string
+}
+
+// BuildMultiPlatform 使用 opt.HostURL 对应的缓存 client 构建多平台镜像
+func BuildMultiPlatform(ctx context.Context, opt *MultiPlatformOption) (*MultiPlatformResult, error) {
+	c, err := getClient(ctx, opt.HostURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.BuildMultiPlatform(ctx, opt)
+}
+
+// BuildMultiPlatform 为每个平台分别构建并推送镜像（tag 形如 repo:1.0-linux-arm64），
+// 然后为每个最终 tag 组装并推送一个引用所有平台镜像的 manifest list。
+// daemon 需要能够构建目标平台的镜像，例如启用了 BuildKit 与 binfmt/QEMU
+func (c *Client) BuildMultiPlatform(ctx context.Context, opt *MultiPlatformOption) (*MultiPlatformResult, error) {
+	if len(opt.Platforms) <= 0 {
+		return nil, fmt.Errorf("no platform specified")
+	}
+	if len(opt.Tags) <= 0 {
+		return nil, fmt.Errorf("no tag specified")
+	}
+	platforms := make([]ocispec.Platform, 0, len(opt.Platforms))
+	for _, p := range opt.Platforms {
+		platform, err := parsePlatform(p)
+		if err != nil {
+			return nil, err
+		}
+		platforms = append(platforms, platform)
+	}
+	tags := make([]reference.NamedTagged, 0, len(opt.Tags))
+	for _, tag := range opt.Tags {
+		named, err := parseTag(tag)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, named)
+	}
+
+	result := &MultiPlatformResult{Digests: make(map[string]string)}
+	for i, platform := range platforms {
+		platformTags := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			platformTags = append(platformTags, fmt.Sprintf("%s:%s-%s", tag.Name(), tag.Tag(), platformSuffix(platform)))
+		}
+		buildOpt := opt.BuildOption
+		buildOpt.Platform = opt.Platforms[i]
+		buildOpt.Tags = platformTags
+		output, err := c.Build(ctx, &buildOpt)
+		if err != nil {
+			return result, fmt.Errorf("build %s: %w", opt.Platforms[i], err)
+		}
+		for _, tag := range platformTags {
+			pushOutput, err := c.Push(ctx, &PushOption{
+				Tag:      tag,
+				Username: opt.Username,
+				Password: opt.Password,
+			})
+			output += pushOutput
+			if err != nil {
+				return result, fmt.Errorf("push %s: %w", tag, err)
+			}
+		}
+		result.Platforms = append(result.Platforms, PlatformResult{
+			Platform: opt.Platforms[i],
+			Tags:     platformTags,
+			Output:   output,
+		})
+	}
+
+	registry := newRegistryClient(opt.Username, opt.Password, opt.InsecureRegistry)
+	for _, tag := range tags {
+		dgst, err := pushManifestList(ctx, registry, tag, platforms)
+		if err != nil {
+			return result, fmt.Errorf("push manifest list %s: %w", reference.FamiliarString(tag), err)
+		}
+		result.Digests[reference.FamiliarString(tag)] = dgst
+	}
+	return result, nil
+}
+
+// manifestList 同时兼容 Docker manifest list 与 OCI image index
+type manifestList struct {
+	SchemaVersion int                  `json:"schemaVersion"`
+	MediaType     string               `json:"mediaType,omitempty"`
+	Manifests     []ocispec.Descriptor `json:"manifests"`
+}
+
+func pushManifestList(ctx context.Context, registry *registryClient, tag reference.NamedTagged, platforms []ocispec.Platform) (string, error) {
+	list := manifestList{SchemaVersion: 2}
+	for i := range platforms {
+		desc, err := registry.headManifest(ctx, tag, fmt.Sprintf("%s-%s", tag.Tag(), platformSuffix(platforms[i])))
+		if err != nil {
+			return "", err
+		}
+		desc.Platform = &platforms[i]
+		list.Manifests = append(list.Manifests, desc)
+	}
+
+	// 子 manifest 是 OCI 格式时使用 OCI image index，否则使用 Docker manifest list
+	list.MediaType = MediaTypeDockerManifestList
+	for _, m := range list.Manifests {
+		if m.MediaType == ocispec.MediaTypeImageManifest {
+			list.MediaType = ocispec.MediaTypeImageIndex
+			break
+		}
+	}
+	body, err := json.Marshal(list)
+	if err != nil {
+		return "", err
+	}
+	dgst, err := registry.putManifest(ctx, tag, tag.Tag(), list.MediaType, body)
+	return dgst.String(), err
+}
+
+func parseTag(tag string) (reference.NamedTagged, error) {
+	named, err := reference.ParseNormalizedNamed(tag)
+	if err != nil {
+		return nil, err
+	}
+	tagged, ok := reference.TagNameOnly(named).(reference.NamedTagged)
+	if !ok {
+		return nil, fmt.Errorf("invalid tag %q", tag)
+	}
+	return tagged, nil
+}
+
+// parsePlatform 解析 os/arch[/variant] 格式的平台
+func parsePlatform(platform string) (ocispec.Platform, error) {
+	parts := strings.Split(platform, "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return ocispec.Platform{}, fmt.Errorf("invalid platform %q, want os/arch[/variant]", platform)
+	}
+	p := ocispec.Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+	return p, nil
+}
+
+func platformSuffix(p ocispec.Platform) string {
+	suffix := p.OS + "-" + p.Architecture
+	if p.Variant != "" {
+		suffix += "-" + p.Variant
+	}
+	return suffix
+}
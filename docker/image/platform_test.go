@@ -0,0 +1,102 @@
+package image
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/docker/distribution/reference"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+func Test_parsePlatform(t *testing.T) {
+	tests := []struct {
+		platform   string
+		wantSuffix string
+		wantErr    bool
+	}{
+		{platform: "linux/amd64", wantSuffix: "linux-amd64"},
+		{platform: "linux/arm/v7", wantSuffix: "linux-arm-v7"},
+		{platform: "linux", wantErr: true},
+		{platform: "linux//v7", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			p, err := parsePlatform(tt.platform)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePlatform() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && platformSuffix(p) != tt.wantSuffix {
+				t.Errorf("platformSuffix() = %s, want %s", platformSuffix(p), tt.wantSuffix)
+			}
+		})
+	}
+}
+
+// Test_pushManifestList 使用一个需要 Bearer token 的假 registry 验证 manifest list 的组装与推送
+func Test_pushManifestList(t *testing.T) {
+	var (
+		pushed     manifestList
+		pushedType string
+	)
+	mux := http.NewServeMux()
+	var server *httptest.Server
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, _ := r.BasicAuth(); user != "admin" || pass != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"token":"t0ken"}`))
+	})
+	mux.HandleFunc("/v2/library/app/manifests/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+server.URL+`/token",service="test"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		ref := strings.TrimPrefix(r.URL.Path, "/v2/library/app/manifests/")
+		switch r.Method {
+		case http.MethodHead:
+			w.Header().Set("Content-Type", MediaTypeDockerManifest)
+			w.Header().Set("Content-Length", "100")
+			w.Header().Set("Docker-Content-Digest", "sha256:"+strings.Repeat(ref[len(ref)-1:], 64))
+		case http.MethodPut:
+			pushedType = r.Header.Get("Content-Type")
+			body, _ := ioutil.ReadAll(r.Body)
+			_ = json.Unmarshal(body, &pushed)
+			w.WriteHeader(http.StatusCreated)
+		}
+	})
+	server = httptest.NewServer(mux)
+	defer server.Close()
+
+	tag, err := parseTag(strings.TrimPrefix(server.URL, "http://") + "/library/app:1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	platforms := []ocispec.Platform{
+		{OS: "linux", Architecture: "amd64"},
+		{OS: "linux", Architecture: "arm", Variant: "v7"},
+	}
+	registry := newRegistryClient("admin", "secret", true)
+	if _, err := pushManifestList(context.Background(), registry, tag, platforms); err != nil {
+		t.Fatalf("pushManifestList() error = %v", err)
+	}
+
+	if pushedType != MediaTypeDockerManifestList {
+		t.Errorf("Content-Type = %s, want %s", pushedType, MediaTypeDockerManifestList)
+	}
+	if len(pushed.Manifests) != 2 {
+		t.Fatalf("manifests = %+v", pushed.Manifests)
+	}
+	if m := pushed.Manifests[1]; m.Platform.Variant != "v7" || m.Digest.String() != "sha256:"+strings.Repeat("7", 64) || m.Size != 100 {
+		t.Errorf("manifest = %+v", m)
+	}
+	if reference.Domain(tag) == "docker.io" {
+		t.Errorf("tag should keep the test registry domain")
+	}
+}
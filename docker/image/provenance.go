@@ -0,0 +1,304 @@
+package image
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// Build 为镜像自动添加以下 label，源码地址与版本使用 OCI 定义的 annotation
+const (
+	LabelBuildHost    = "loclo.build.host"
+	LabelOptionsHash  = "loclo.build.options-hash"
+	LabelSource       = ocispec.AnnotationSource
+	LabelRevision     = ocispec.AnnotationRevision
+	LabelBuildCreated = ocispec.AnnotationCreated
+)
+
+// Provenance 记录镜像的来源，用于从运行中的容器或 Pod 追溯到构建它的源码
+type Provenance struct {
+	ImageID     string
+	RepoTags    []string
+	RepoDigests []string
+	Source      string
+	Revision    string
+	// Created 是构建时 SOURCE_DATE_EPOCH 指定的时间，没有指定时为镜像的创建时间
+	Created     time.Time
+	BuilderHost string
+	OptionsHash string
+	// ContextHash 只有在构建时启用了 BuildOption.Cache 才会记录
+	ContextHash string
+	Labels      map[string]string
+	History     []HistoryEntry
+}
+
+// HistoryEntry 是镜像历史中的一步，从最新的一步开始排列
+type HistoryEntry struct {
+	ID        string
+	Created   time.Time
+	CreatedBy string
+	Size      int64
+	Comment   string
+}
+
+// buildLabels 返回 Build 需要添加到镜像上的来源 label，
+// opt.Source 与 opt.Revision 为空时从构建上下文所在的 git 仓库中读取
+func (c *Client) buildLabels(ctx context.Context, opt *BuildOption) map[string]string {
+	labels := map[string]string{
+		LabelOptionsHash: optionsHash(opt),
+	}
+	// 每次构建都不同的时间戳会使相同的输入产生不同的镜像 ID，因此只使用 SOURCE_DATE_EPOCH 指定的时间
+	if created, ok := sourceDateEpoch(); ok {
+		labels[LabelBuildCreated] = created.Format(time.RFC3339)
+	}
+	if info, err := c.cli.Info(ctx); err == nil && info.Name != "" {
+		labels[LabelBuildHost] = info.Name
+	} else {
+		labels[LabelBuildHost] = c.cli.HostName()
+	}
+
+	source, revision := opt.Source, opt.Revision
+	if source == "" || revision == "" {
+		gitSource, gitRevision := gitInfo(opt.CtxPath)
+		if source == "" {
+			source = gitSource
+		}
+		if revision == "" {
+			revision = gitRevision
+		}
+	}
+	if source != "" {
+		labels[LabelSource] = source
+	}
+	if revision != "" {
+		labels[LabelRevision] = revision
+	}
+	return labels
+}
+
+// sourceDateEpoch 读取 SOURCE_DATE_EPOCH 环境变量，见 https://reproducible-builds.org/specs/source-date-epoch/
+func sourceDateEpoch() (time.Time, bool) {
+	epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(epoch, 0).UTC(), true
+}
+
+// optionsHash 计算影响构建结果的参数的哈希，不包含 secret 的内容。
+// tag 不影响构建结果，因此不参与计算，否则同样的输入换一个 tag 构建会得到不同的镜像 ID
+func optionsHash(opt *BuildOption) string {
+	var secrets, ssh []string
+	for _, s := range opt.Secrets {
+		secrets = append(secrets, s.ID)
+	}
+	for _, s := range opt.SSH {
+		ssh = append(ssh, s.ID)
+	}
+	raw, _ := json.Marshal(struct {
+		Dockerfile string
+		Platform   string
+		Secrets    []string
+		SSH        []string
+	}{dockerfileName(opt), opt.Platform, secrets, ssh})
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// gitInfo 从 dir 或其上级目录中的 .git 读取 origin 的地址与 HEAD 指向的 commit
+func gitInfo(dir string) (source, revision string) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+	for {
+		if gitDir, ok := resolveGitDir(filepath.Join(dir, ".git")); ok {
+			commonDir := gitCommonDir(gitDir)
+			return gitRemote(commonDir), gitHead(gitDir, commonDir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", ""
+		}
+		dir = parent
+	}
+}
+
+// resolveGitDir 返回 .git 对应的 git 目录。worktree 与 submodule 中的 .git 是一个文件，
+// 内容为 gitdir: <path>，相对路径相对于 .git 文件所在的目录
+func resolveGitDir(dotGit string) (string, bool) {
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return "", false
+	}
+	if info.IsDir() {
+		return dotGit, true
+	}
+	content, err := ioutil.ReadFile(dotGit)
+	if err != nil {
+		return "", false
+	}
+	gitDir := strings.TrimSpace(string(content))
+	if !strings.HasPrefix(gitDir, "gitdir:") {
+		return "", false
+	}
+	gitDir = filepath.FromSlash(strings.TrimSpace(strings.TrimPrefix(gitDir, "gitdir:")))
+	if !filepath.IsAbs(gitDir) {
+		gitDir = filepath.Join(filepath.Dir(dotGit), gitDir)
+	}
+	if info, err := os.Stat(gitDir); err != nil || !info.IsDir() {
+		return "", false
+	}
+	return gitDir, true
+}
+
+// gitCommonDir 返回保存 config 与分支的目录，worktree 的 git 目录中的 commondir 文件指向主仓库的 git 目录
+func gitCommonDir(gitDir string) string {
+	content, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir"))
+	if err != nil {
+		return gitDir
+	}
+	commonDir := filepath.FromSlash(strings.TrimSpace(string(content)))
+	if !filepath.IsAbs(commonDir) {
+		commonDir = filepath.Join(gitDir, commonDir)
+	}
+	return commonDir
+}
+
+// gitHead 读取 gitDir 中的 HEAD，分支与 packed-refs 位于 commonDir 中
+func gitHead(gitDir, commonDir string) string {
+	head, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+	ref := strings.TrimSpace(string(head))
+	if !strings.HasPrefix(ref, "ref: ") {
+		// detached HEAD
+		return ref
+	}
+	ref = strings.TrimPrefix(ref, "ref: ")
+	for _, dir := range []string{gitDir, commonDir} {
+		if commit, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+			return strings.TrimSpace(string(commit))
+		}
+	}
+	packed, err := os.Open(filepath.Join(commonDir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(packed)
+	scanner := bufio.NewScanner(packed)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// gitRemote 返回 origin 的地址，地址中的用户名与密码会被去掉
+func gitRemote(gitDir string) string {
+	config, err := os.Open(filepath.Join(gitDir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer func(f *os.File) {
+		_ = f.Close()
+	}(config)
+	var inOrigin bool
+	scanner := bufio.NewScanner(config)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inOrigin = line == `[remote "origin"]`
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if inOrigin && len(kv) == 2 && strings.TrimSpace(kv[0]) == "url" {
+			return redactURL(strings.TrimSpace(kv[1]))
+		}
+	}
+	return ""
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+// GetProvenance 使用 hostURL 对应的缓存 client 读取镜像的来源
+func GetProvenance(ctx context.Context, hostURL, ref string) (*Provenance, error) {
+	c, err := getClient(ctx, hostURL)
+	if err != nil {
+		return nil, err
+	}
+	return c.Provenance(ctx, ref)
+}
+
+// Provenance 读取镜像的 label 与历史，ref 可以是镜像 ID、tag 或 repo@digest
+func (c *Client) Provenance(ctx context.Context, ref string) (*Provenance, error) {
+	inspect, _, err := c.cli.ImageInspectWithRaw(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	var labels map[string]string
+	if inspect.Config != nil {
+		labels = inspect.Config.Labels
+	}
+	p := provenanceFromLabels(labels)
+	if p.Created.IsZero() {
+		// 没有设置 SOURCE_DATE_EPOCH 时使用镜像的创建时间
+		p.Created, _ = time.Parse(time.RFC3339Nano, inspect.Created)
+	}
+	p.ImageID = inspect.ID
+	p.RepoTags = inspect.RepoTags
+	p.RepoDigests = inspect.RepoDigests
+
+	history, err := c.cli.ImageHistory(ctx, inspect.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, h := range history {
+		p.History = append(p.History, HistoryEntry{
+			ID:        h.ID,
+			Created:   time.Unix(h.Created, 0).UTC(),
+			CreatedBy: h.CreatedBy,
+			Size:      h.Size,
+			Comment:   h.Comment,
+		})
+	}
+	return p, nil
+}
+
+func provenanceFromLabels(labels map[string]string) *Provenance {
+	p := &Provenance{
+		Source:      labels[LabelSource],
+		Revision:    labels[LabelRevision],
+		BuilderHost: labels[LabelBuildHost],
+		OptionsHash: labels[LabelOptionsHash],
+		ContextHash: labels[LabelBuildHash],
+		Labels:      labels,
+	}
+	if created, err := time.Parse(time.RFC3339, labels[LabelBuildCreated]); err == nil {
+		p.Created = created
+	}
+	return p
+}
@@ -0,0 +1,267 @@
+package image
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/docker/distribution/reference"
+	digest "github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+const (
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+)
+
+// registryClient 是 Registry HTTP API V2 的一个最小实现，只支持读写 manifest
+type registryClient struct {
+	http     *http.Client
+	username string
+	password string
+	// insecure 为 true 时使用 http 访问 registry
+	insecure bool
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func newRegistryClient(username, password string, insecure bool) *registryClient {
+	return &registryClient{
+		http:     http.DefaultClient,
+		username: username,
+		password: password,
+		insecure: insecure,
+		tokens:   make(map[string]string),
+	}
+}
+
+func (r *registryClient) manifestURL(named reference.Named, ref string) string {
+	return r.repoURL(named, "manifests", ref)
+}
+
+func (r *registryClient) blobURL(named reference.Named, dgst digest.Digest) string {
+	return r.repoURL(named, "blobs", dgst.String())
+}
+
+func (r *registryClient) repoURL(named reference.Named, kind, ref string) string {
+	scheme := "https"
+	if r.insecure {
+		scheme = "http"
+	}
+	domain := reference.Domain(named)
+	if domain == "docker.io" {
+		domain = "registry-1.docker.io"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s/%s/%s", scheme, domain, reference.Path(named), kind, ref)
+}
+
+// get 读取 manifest 或 blob 的内容，accept 为空时不设置 Accept 头
+func (r *registryClient) get(ctx context.Context, named reference.Named, url, accept string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := r.do(req, reference.Path(named), "pull")
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+	return ioutil.ReadAll(resp.Body)
+}
+
+// headManifest 返回 tag 对应 manifest 的描述，不包含 platform
+func (r *registryClient) headManifest(ctx context.Context, named reference.Named, tag string) (ocispec.Descriptor, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, r.manifestURL(named, tag), nil)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	req.Header.Set("Accept", strings.Join([]string{MediaTypeDockerManifest, ocispec.MediaTypeImageManifest}, ", "))
+	resp, err := r.do(req, reference.Path(named), "pull")
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	_ = resp.Body.Close()
+
+	dgst, err := digest.Parse(resp.Header.Get("Docker-Content-Digest"))
+	if err != nil {
+		return ocispec.Descriptor{}, fmt.Errorf("invalid manifest digest for %s:%s: %w", named.Name(), tag, err)
+	}
+	size := resp.ContentLength
+	if size < 0 {
+		return ocispec.Descriptor{}, fmt.Errorf("unknown manifest size for %s:%s", named.Name(), tag)
+	}
+	return ocispec.Descriptor{
+		MediaType: resp.Header.Get("Content-Type"),
+		Digest:    dgst,
+		Size:      size,
+	}, nil
+}
+
+// putManifest 上传 manifest 并返回 registry 计算得到的 digest
+func (r *registryClient) putManifest(ctx context.Context, named reference.Named, tag, mediaType string, body []byte) (digest.Digest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, r.manifestURL(named, tag), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", mediaType)
+	req.GetBody = func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(body)), nil
+	}
+	resp, err := r.do(req, reference.Path(named), "pull,push")
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+	if dgst, err := digest.Parse(resp.Header.Get("Docker-Content-Digest")); err == nil {
+		return dgst, nil
+	}
+	return digest.FromBytes(body), nil
+}
+
+// do 发送请求，收到 401 时根据 WWW-Authenticate 完成 Basic 或 Bearer 认证后重试一次
+func (r *registryClient) do(req *http.Request, repo, actions string) (*http.Response, error) {
+	scope := fmt.Sprintf("repository:%s:%s", repo, actions)
+	r.mu.Lock()
+	token := r.tokens[scope]
+	r.mu.Unlock()
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		_ = resp.Body.Close()
+		retry := req.Clone(req.Context())
+		if req.GetBody != nil {
+			if retry.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.authorize(retry, resp.Header.Get("WWW-Authenticate"), scope); err != nil {
+			return nil, err
+		}
+		if resp, err = r.http.Do(retry); err != nil {
+			return nil, err
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		_ = resp.Body.Close()
+		return nil, fmt.Errorf("registry responded %s %s: %s: %s", req.Method, req.URL, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+func (r *registryClient) authorize(req *http.Request, challenge, scope string) error {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		req.SetBasicAuth(r.username, r.password)
+		return nil
+	case "bearer":
+		token, err := r.fetchToken(req.Context(), params, scope)
+		if err != nil {
+			return err
+		}
+		r.mu.Lock()
+		r.tokens[scope] = token
+		r.mu.Unlock()
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	default:
+		return fmt.Errorf("unsupported registry auth challenge %q", challenge)
+	}
+}
+
+func (r *registryClient) fetchToken(ctx context.Context, params map[string]string, scope string) (string, error) {
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Host == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if r.username != "" {
+		req.SetBasicAuth(r.username, r.password)
+	}
+	resp, err := r.http.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch registry token: %s", resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// parseChallenge 解析形如 `Bearer realm="...",service="..."` 的 WWW-Authenticate 头
+func parseChallenge(header string) (string, map[string]string) {
+	params := make(map[string]string)
+	parts := strings.SplitN(strings.TrimSpace(header), " ", 2)
+	if len(parts) < 2 {
+		return parts[0], params
+	}
+	rest := parts[1]
+	for len(rest) > 0 {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimLeft(rest, ", ")
+	}
+	return parts[0], params
+}
@@ -0,0 +1,208 @@
+package image
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// 以下常量来自 Berkeley DB 的 db_page.h
+const (
+	bdbHashMagic      = 0x061561
+	bdbPageHeaderSize = 26
+	bdbPageOverflow   = 7
+	bdbPageHashOld    = 2
+	bdbPageHash       = 13
+	bdbItemKeyData    = 1
+	bdbItemOffPage    = 3
+)
+
+// 以下常量来自 rpm 的 rpmtag.h
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagLicense = 1014
+	rpmTagArch    = 1022
+
+	rpmTypeInt32       = 4
+	rpmTypeString      = 6
+	rpmTypeI18NString  = 9
+	rpmIndexEntrySize  = 16
+	rpmMaxIndexEntries = 0xffff
+)
+
+// parseRpmDB 解析 Berkeley DB hash 格式的 rpm Packages 数据库
+func parseRpmDB(db []byte) ([]Package, error) {
+	blobs, err := bdbHashValues(db)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		pkgs    []Package
+		invalid int
+	)
+	for _, blob := range blobs {
+		// 第 0 条记录保存的是下一个记录号，不是 header
+		if len(blob) < 8 {
+			continue
+		}
+		p, err := parseRpmHeader(blob)
+		if err != nil {
+			invalid++
+			continue
+		}
+		if p.Name != "" && p.Name != "gpg-pubkey" {
+			pkgs = append(pkgs, p)
+		}
+	}
+	if invalid > 0 {
+		return pkgs, fmt.Errorf("skipped %d invalid rpm headers", invalid)
+	}
+	return pkgs, nil
+}
+
+// bdbHashValues 返回 hash 数据库中所有 value，value 过大时会被存放在 overflow 页链中
+func bdbHashValues(db []byte) ([][]byte, error) {
+	if len(db) < 512 {
+		return nil, fmt.Errorf("rpm database is too small")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(db[12:]) != bdbHashMagic {
+		order = binary.BigEndian
+		if order.Uint32(db[12:]) != bdbHashMagic {
+			return nil, fmt.Errorf("not a berkeley db hash database")
+		}
+	}
+	pageSize := int(order.Uint32(db[20:]))
+	if pageSize < 512 || pageSize > 64*1024 || len(db)%pageSize != 0 {
+		return nil, fmt.Errorf("invalid berkeley db page size %d", pageSize)
+	}
+	page := func(n uint32) ([]byte, error) {
+		start := int(n) * pageSize
+		if n == 0 || start+pageSize > len(db) {
+			return nil, fmt.Errorf("invalid berkeley db page %d", n)
+		}
+		return db[start : start+pageSize], nil
+	}
+
+	var values [][]byte
+	for n := uint32(1); int(n)*pageSize < len(db); n++ {
+		p, _ := page(n)
+		if typ := p[25]; typ != bdbPageHash && typ != bdbPageHashOld {
+			continue
+		}
+		entries := int(order.Uint16(p[20:]))
+		if bdbPageHeaderSize+entries*2 > pageSize {
+			return nil, fmt.Errorf("invalid berkeley db page %d", n)
+		}
+		offsets := make([]int, entries)
+		for i := range offsets {
+			offsets[i] = int(order.Uint16(p[bdbPageHeaderSize+i*2:]))
+		}
+		// 条目两两成对，依次为 key 与 value，从页尾向前存放
+		for i := 1; i < entries; i += 2 {
+			start, end := offsets[i], offsets[i-1]
+			if start >= end || end > pageSize {
+				return nil, fmt.Errorf("invalid berkeley db item on page %d", n)
+			}
+			item := p[start:end]
+			switch item[0] {
+			case bdbItemKeyData:
+				values = append(values, item[1:])
+			case bdbItemOffPage:
+				if len(item) < 12 {
+					return nil, fmt.Errorf("invalid berkeley db overflow item on page %d", n)
+				}
+				value, err := bdbOverflow(page, order, order.Uint32(item[4:]), int(order.Uint32(item[8:])))
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, value)
+			}
+		}
+	}
+	return values, nil
+}
+
+func bdbOverflow(page func(uint32) ([]byte, error), order binary.ByteOrder, n uint32, length int) ([]byte, error) {
+	value := make([]byte, 0, length)
+	for n != 0 && len(value) < length {
+		p, err := page(n)
+		if err != nil {
+			return nil, err
+		}
+		if p[25] != bdbPageOverflow {
+			return nil, fmt.Errorf("berkeley db page %d is not an overflow page", n)
+		}
+		used := int(order.Uint16(p[22:]))
+		if bdbPageHeaderSize+used > len(p) {
+			return nil, fmt.Errorf("invalid berkeley db overflow page %d", n)
+		}
+		value = append(value, p[bdbPageHeaderSize:bdbPageHeaderSize+used]...)
+		n = order.Uint32(p[16:])
+	}
+	if len(value) != length {
+		return nil, fmt.Errorf("truncated berkeley db overflow value")
+	}
+	return value, nil
+}
+
+// parseRpmHeader 解析 rpmdb 中保存的 header blob（不含 header magic）
+func parseRpmHeader(blob []byte) (Package, error) {
+	il := int(binary.BigEndian.Uint32(blob))
+	dl := int(binary.BigEndian.Uint32(blob[4:]))
+	dataStart := 8 + il*rpmIndexEntrySize
+	if il <= 0 || il > rpmMaxIndexEntries || dl < 0 || dataStart+dl > len(blob) {
+		return Package{}, fmt.Errorf("invalid rpm header")
+	}
+	data := blob[dataStart : dataStart+dl]
+
+	p := Package{Source: PackageRpm}
+	var version, release string
+	epoch := -1
+	for i := 0; i < il; i++ {
+		entry := blob[8+i*rpmIndexEntrySize:]
+		tag := binary.BigEndian.Uint32(entry)
+		typ := binary.BigEndian.Uint32(entry[4:])
+		offset := int(binary.BigEndian.Uint32(entry[8:]))
+		if offset < 0 || offset >= len(data) {
+			continue
+		}
+		str := func() string {
+			if typ != rpmTypeString && typ != rpmTypeI18NString {
+				return ""
+			}
+			s := data[offset:]
+			if end := bytes.IndexByte(s, 0); end >= 0 {
+				s = s[:end]
+			}
+			return string(s)
+		}
+		switch tag {
+		case rpmTagName:
+			p.Name = str()
+		case rpmTagVersion:
+			version = str()
+		case rpmTagRelease:
+			release = str()
+		case rpmTagLicense:
+			p.License = str()
+		case rpmTagArch:
+			p.Arch = str()
+		case rpmTagEpoch:
+			if typ == rpmTypeInt32 && offset+4 <= len(data) {
+				epoch = int(binary.BigEndian.Uint32(data[offset:]))
+			}
+		}
+	}
+	p.Version = version
+	if release != "" {
+		p.Version += "-" + release
+	}
+	if epoch > 0 {
+		p.Version = fmt.Sprintf("%d:%s", epoch, p.Version)
+	}
+	return p, nil
+}
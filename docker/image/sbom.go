@@ -0,0 +1,209 @@
+package image
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+)
+
+type SBOMFormat string
+
+const (
+	SBOMFormatSPDX      SBOMFormat = "spdx"
+	SBOMFormatCycloneDX SBOMFormat = "cyclonedx"
+)
+
+// SBOM 将分析结果中的软件包导出为 SPDX 2.2 或 CycloneDX 1.3 格式的 JSON
+func (a *Analysis) SBOM(format SBOMFormat) ([]byte, error) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	switch format {
+	case SBOMFormatSPDX:
+		return json.MarshalIndent(a.spdx(now), "", "  ")
+	case SBOMFormatCycloneDX:
+		return json.MarshalIndent(a.cycloneDX(now), "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported sbom format %q", format)
+	}
+}
+
+func (a *Analysis) name() string {
+	if a.Image != "" {
+		return a.Image
+	}
+	return a.ID
+}
+
+type spdxDocument struct {
+	SPDXVersion       string             `json:"spdxVersion"`
+	DataLicense       string             `json:"dataLicense"`
+	SPDXID            string             `json:"SPDXID"`
+	Name              string             `json:"name"`
+	DocumentNamespace string             `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo   `json:"creationInfo"`
+	Packages          []spdxPackage      `json:"packages"`
+	Relationships     []spdxRelationship `json:"relationships"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+type spdxRelationship struct {
+	SPDXElementID      string `json:"spdxElementId"`
+	RelationshipType   string `json:"relationshipType"`
+	RelatedSPDXElement string `json:"relatedSpdxElement"`
+}
+
+const spdxNoAssertion = "NOASSERTION"
+
+func (a *Analysis) spdx(created string) *spdxDocument {
+	doc := &spdxDocument{
+		SPDXVersion:       "SPDX-2.2",
+		DataLicense:       "CC0-1.0",
+		SPDXID:            "SPDXRef-DOCUMENT",
+		Name:              a.name(),
+		DocumentNamespace: fmt.Sprintf("https://%s/spdx/%s-%s", docker.ToolName, strings.NewReplacer("/", "-", ":", "-").Replace(a.name()), created),
+		CreationInfo: spdxCreationInfo{
+			Created:  created,
+			Creators: []string{"Tool: " + docker.ToolName},
+		},
+	}
+	image := spdxPackage{
+		SPDXID:           "SPDXRef-Image",
+		Name:             a.name(),
+		VersionInfo:      a.ID,
+		DownloadLocation: spdxNoAssertion,
+		LicenseConcluded: spdxNoAssertion,
+		LicenseDeclared:  spdxNoAssertion,
+		CopyrightText:    spdxNoAssertion,
+	}
+	doc.Packages = append(doc.Packages, image)
+	doc.Relationships = append(doc.Relationships, spdxRelationship{
+		SPDXElementID:      doc.SPDXID,
+		RelationshipType:   "DESCRIBES",
+		RelatedSPDXElement: image.SPDXID,
+	})
+	for i, p := range a.Packages {
+		license := p.License
+		if license == "" {
+			license = spdxNoAssertion
+		}
+		pkg := spdxPackage{
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i),
+			Name:             p.Name,
+			VersionInfo:      p.Version,
+			DownloadLocation: spdxNoAssertion,
+			LicenseConcluded: spdxNoAssertion,
+			LicenseDeclared:  license,
+			CopyrightText:    spdxNoAssertion,
+			ExternalRefs: []spdxExternalRef{{
+				ReferenceCategory: "PACKAGE-MANAGER",
+				ReferenceType:     "purl",
+				ReferenceLocator:  p.PURL,
+			}},
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.Relationships = append(doc.Relationships, spdxRelationship{
+			SPDXElementID:      image.SPDXID,
+			RelationshipType:   "CONTAINS",
+			RelatedSPDXElement: pkg.SPDXID,
+		})
+	}
+	return doc
+}
+
+type cycloneDXDocument struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Timestamp string             `json:"timestamp"`
+	Tools     []cycloneDXTool    `json:"tools"`
+	Component cycloneDXComponent `json:"component"`
+}
+
+type cycloneDXTool struct {
+	Name string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	Type       string             `json:"type"`
+	Name       string             `json:"name"`
+	Version    string             `json:"version,omitempty"`
+	PURL       string             `json:"purl,omitempty"`
+	Licenses   []cycloneDXLicense `json:"licenses,omitempty"`
+	Properties []cycloneDXProp    `json:"properties,omitempty"`
+}
+
+type cycloneDXLicense struct {
+	License struct {
+		Name string `json:"name"`
+	} `json:"license"`
+}
+
+type cycloneDXProp struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func (a *Analysis) cycloneDX(timestamp string) *cycloneDXDocument {
+	doc := &cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.3",
+		Version:     1,
+		Metadata: cycloneDXMetadata{
+			Timestamp: timestamp,
+			Tools:     []cycloneDXTool{{Name: docker.ToolName}},
+			Component: cycloneDXComponent{Type: "container", Name: a.name(), Version: a.ID},
+		},
+		Components: []cycloneDXComponent{},
+	}
+	if a.OS != nil {
+		doc.Components = append(doc.Components, cycloneDXComponent{
+			Type:    "operating-system",
+			Name:    a.OS.ID,
+			Version: a.OS.VersionID,
+		})
+	}
+	for _, p := range a.Packages {
+		c := cycloneDXComponent{
+			Type:       "library",
+			Name:       p.Name,
+			Version:    p.Version,
+			PURL:       p.PURL,
+			Properties: []cycloneDXProp{{Name: docker.ToolName + ":package:source", Value: p.Source}},
+		}
+		if p.License != "" {
+			var l cycloneDXLicense
+			l.License.Name = p.License
+			c.Licenses = []cycloneDXLicense{l}
+		}
+		doc.Components = append(doc.Components, c)
+	}
+	return doc
+}
@@ -0,0 +1,124 @@
+package image
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+
+	"github.com/docker/docker/api/types"
+	"github.com/moby/buildkit/session"
+	"github.com/moby/buildkit/session/secrets/secretsprovider"
+	"github.com/moby/buildkit/session/sshforward/sshprovider"
+)
+
+// BuildSecret 是构建时通过 `RUN --mount=type=secret,id=<ID>` 使用的 secret，
+// 它只在对应的 RUN 步骤中可见，不会写入镜像层或镜像历史。
+// Value、FilePath、Env 三者必须指定一个，同时指定时优先级依次降低
+type BuildSecret struct {
+	ID       string
+	Value    []byte
+	FilePath string
+	Env      string
+}
+
+// BuildSSH 将 ssh agent 转发给 `RUN --mount=type=ssh,id=<ID>` 使用
+type BuildSSH struct {
+	// ID 默认为 default
+	ID string
+	// Paths 是 agent socket 或私钥文件的路径，为空时使用 $SSH_AUTH_SOCK
+	Paths []string
+}
+
+func (opt *BuildOption) needSession() bool {
+	return len(opt.Secrets) > 0 || len(opt.SSH) > 0
+}
+
+func (s BuildSecret) read() ([]byte, error) {
+	switch {
+	case s.Value != nil:
+		return s.Value, nil
+	case s.FilePath != "":
+		return ioutil.ReadFile(s.FilePath)
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", s.Env)
+		}
+		return []byte(v), nil
+	default:
+		return nil, fmt.Errorf("no source for build secret %s", s.ID)
+	}
+}
+
+// startSession 创建一个提供 secret 与 ssh agent 的 BuildKit session，
+// 并通过 daemon 的 /session 接口与之建立连接
+func (c *Client) startSession(ctx context.Context, opt *BuildOption) (*session.Session, error) {
+	s, err := session.NewSession(ctx, "loclo", "")
+	if err != nil {
+		return nil, err
+	}
+	if err := allowProviders(s, opt); err != nil {
+		_ = s.Close()
+		return nil, err
+	}
+
+	dialer := func(ctx context.Context, proto string, meta map[string][]string) (net.Conn, error) {
+		return c.cli.DialHijack(ctx, "/session", proto, meta)
+	}
+	go func() {
+		_ = s.Run(ctx, dialer)
+	}()
+	return s, nil
+}
+
+func allowProviders(s *session.Session, opt *BuildOption) error {
+	if len(opt.Secrets) > 0 {
+		values := make(map[string][]byte, len(opt.Secrets))
+		for _, secret := range opt.Secrets {
+			if secret.ID == "" {
+				return fmt.Errorf("build secret id is required")
+			}
+			v, err := secret.read()
+			if err != nil {
+				return fmt.Errorf("read build secret %s: %w", secret.ID, err)
+			}
+			values[secret.ID] = v
+		}
+		s.Allow(secretsprovider.FromMap(values))
+	}
+
+	if len(opt.SSH) > 0 {
+		configs := make([]sshprovider.AgentConfig, 0, len(opt.SSH))
+		for _, ssh := range opt.SSH {
+			id := ssh.ID
+			if id == "" {
+				id = "default"
+			}
+			configs = append(configs, sshprovider.AgentConfig{ID: id, Paths: ssh.Paths})
+		}
+		provider, err := sshprovider.NewSSHAgentProvider(configs)
+		if err != nil {
+			return fmt.Errorf("forward ssh agent: %w", err)
+		}
+		s.Allow(provider)
+	}
+	return nil
+}
+
+// withSession 在需要时为构建启用 BuildKit 并附加 session，返回的函数用于关闭 session
+func (c *Client) withSession(ctx context.Context, opt *BuildOption, buildOpts *types.ImageBuildOptions) (func(), error) {
+	if !opt.needSession() {
+		return func() {}, nil
+	}
+	s, err := c.startSession(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	buildOpts.Version = types.BuilderBuildKit
+	buildOpts.SessionID = s.ID()
+	return func() {
+		_ = s.Close()
+	}, nil
+}
@@ -0,0 +1,75 @@
+package image
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/moby/buildkit/session"
+)
+
+func TestBuildSecret_read(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "token")
+	if err := ioutil.WriteFile(path, []byte("from-file"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	const env = "LOCLO_TEST_BUILD_SECRET"
+	_ = os.Setenv(env, "from-env")
+	defer os.Unsetenv(env)
+
+	tests := []struct {
+		name    string
+		secret  BuildSecret
+		want    string
+		wantErr bool
+	}{
+		{name: "value", secret: BuildSecret{ID: "token", Value: []byte("from-value"), FilePath: path, Env: env}, want: "from-value"},
+		{name: "file", secret: BuildSecret{ID: "token", FilePath: path, Env: env}, want: "from-file"},
+		{name: "env", secret: BuildSecret{ID: "token", Env: env}, want: "from-env"},
+		{name: "empty value", secret: BuildSecret{ID: "token", Value: []byte{}}, want: ""},
+		{name: "missing file", secret: BuildSecret{ID: "token", FilePath: filepath.Join(dir, "missing")}, wantErr: true},
+		{name: "unset env", secret: BuildSecret{ID: "token", Env: "LOCLO_TEST_UNSET"}, wantErr: true},
+		// 没有指定来源时不会读取与 ID 同名的环境变量
+		{name: "no source", secret: BuildSecret{ID: env}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.secret.read()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("read() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("read() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_allowProviders(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     BuildOption
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "secrets", opt: BuildOption{Secrets: []BuildSecret{{ID: "a", Value: []byte("1")}, {ID: "b", Value: []byte("2")}}}},
+		{name: "secret without id", opt: BuildOption{Secrets: []BuildSecret{{Value: []byte("1")}}}, wantErr: true},
+		{name: "secret without source", opt: BuildOption{Secrets: []BuildSecret{{ID: "a"}}}, wantErr: true},
+		{name: "missing ssh key", opt: BuildOption{SSH: []BuildSSH{{Paths: []string{filepath.Join(t.TempDir(), "id_rsa")}}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := session.NewSession(context.Background(), "loclo", "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+			if err := allowProviders(s, &tt.opt); (err != nil) != tt.wantErr {
+				t.Errorf("allowProviders() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
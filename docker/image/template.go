@@ -0,0 +1,210 @@
+package image
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/template"
+)
+
+type Language string
+
+const (
+	LanguageGo     Language = "go"
+	LanguagePython Language = "python"
+	LanguageNode   Language = "node"
+	LanguageJava   Language = "java"
+)
+
+// GenerateOption 描述生成 Dockerfile 所需的信息，零值字段使用对应语言的默认值
+type GenerateOption struct {
+	Language Language
+	// Version 是运行时版本，例如 Go 的 1.17、Node 的 16
+	Version string
+	// Main 是入口：Go 的 main 包路径、Python 的脚本、Node 的脚本（为空时执行 npm start），Java 忽略
+	Main string
+	// Port 大于 0 时添加 EXPOSE
+	Port int
+	// Gradle 为 true 时 Java 项目使用 gradle 构建，否则使用 maven
+	Gradle bool
+	// Manifest 是 Python 项目的依赖描述文件：requirements.txt、pyproject.toml 或 setup.py，
+	// 为空时不安装依赖，EnsureDockerfile 会根据项目中实际存在的文件设置
+	Manifest string
+}
+
+var defaultVersions = map[Language]string{
+	LanguageGo:     "1.17",
+	LanguagePython: "3.10",
+	LanguageNode:   "16",
+	LanguageJava:   "17",
+}
+
+var dockerfileTemplates = map[Language]*template.Template{
+	LanguageGo: template.Must(template.New("go").Parse(`FROM golang:{{.Version}} AS build
+WORKDIR /src
+COPY go.mod go.sum* ./
+RUN go mod download
+COPY . .
+RUN CGO_ENABLED=0 go build -o /out/app {{.Main}}
+
+FROM gcr.io/distroless/static-debian11:nonroot
+COPY --from=build /out/app /app
+{{- if gt .Port 0}}
+EXPOSE {{.Port}}
+{{- end}}
+USER nonroot:nonroot
+ENTRYPOINT ["/app"]
+`)),
+	LanguagePython: template.Must(template.New("python").Parse(`FROM python:{{.Version}}-slim
+WORKDIR /app
+{{- if eq .Manifest "requirements.txt"}}
+COPY requirements.txt ./
+RUN pip install --no-cache-dir -r requirements.txt
+COPY . .
+{{- else if .Manifest}}
+COPY . .
+RUN pip install --no-cache-dir .
+{{- else}}
+COPY . .
+{{- end}}
+RUN useradd --create-home --uid 10001 app
+{{- if gt .Port 0}}
+EXPOSE {{.Port}}
+{{- end}}
+USER app
+CMD ["python", "{{.Main}}"]
+`)),
+	LanguageNode: template.Must(template.New("node").Parse(`FROM node:{{.Version}}-slim
+ENV NODE_ENV=production
+WORKDIR /app
+COPY package*.json ./
+RUN if [ -f package-lock.json ]; then npm ci --only=production; else npm install --only=production; fi
+COPY --chown=node:node . .
+{{- if gt .Port 0}}
+EXPOSE {{.Port}}
+{{- end}}
+USER node
+{{- if .Main}}
+CMD ["node", "{{.Main}}"]
+{{- else}}
+CMD ["npm", "start"]
+{{- end}}
+`)),
+	LanguageJava: template.Must(template.New("java").Parse(`{{if .Gradle -}}
+FROM gradle:jdk{{.Version}} AS build
+WORKDIR /src
+COPY . .
+RUN gradle build -x test --no-daemon && find build/libs -name '*.jar' ! -name '*-plain.jar' -exec cp {} /app.jar \;
+{{- else -}}
+FROM maven:3-eclipse-temurin-{{.Version}} AS build
+WORKDIR /src
+COPY pom.xml .
+RUN mvn -B dependency:go-offline
+COPY src ./src
+RUN mvn -B package -DskipTests && cp target/*.jar /app.jar
+{{- end}}
+
+FROM eclipse-temurin:{{.Version}}-jre
+RUN useradd --create-home --uid 10001 app
+COPY --from=build /app.jar /app/app.jar
+{{- if gt .Port 0}}
+EXPOSE {{.Port}}
+{{- end}}
+USER app
+ENTRYPOINT ["java", "-jar", "/app/app.jar"]
+`)),
+}
+
+// GenerateDockerfile 根据语言模板生成 Dockerfile，生成的 Dockerfile 使用固定版本的基础镜像并以非 root 用户运行
+func GenerateDockerfile(opt *GenerateOption) ([]byte, error) {
+	tmpl, ok := dockerfileTemplates[opt.Language]
+	if !ok {
+		return nil, fmt.Errorf("unsupported language %q", opt.Language)
+	}
+	data := *opt
+	if data.Version == "" {
+		data.Version = defaultVersions[opt.Language]
+	}
+	if data.Main == "" {
+		switch opt.Language {
+		case LanguageGo:
+			data.Main = "."
+		case LanguagePython:
+			data.Main = "main.py"
+		}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// DetectLanguage 根据项目目录中的依赖描述文件判断项目使用的语言
+func DetectLanguage(dir string) (Language, bool) {
+	markers := []struct {
+		file     string
+		language Language
+	}{
+		{"go.mod", LanguageGo},
+		{"package.json", LanguageNode},
+		{"pom.xml", LanguageJava},
+		{"build.gradle", LanguageJava},
+		{"build.gradle.kts", LanguageJava},
+		{"requirements.txt", LanguagePython},
+		{"pyproject.toml", LanguagePython},
+		{"setup.py", LanguagePython},
+	}
+	for _, m := range markers {
+		if _, err := os.Stat(filepath.Join(dir, m.file)); err == nil {
+			return m.language, true
+		}
+	}
+	return "", false
+}
+
+// pythonManifest 返回 Python 项目中存在的依赖描述文件，都不存在时返回空字符串
+func pythonManifest(dir string) string {
+	for _, file := range []string{"requirements.txt", "pyproject.toml", "setup.py"} {
+		if _, err := os.Stat(filepath.Join(dir, file)); err == nil {
+			return file
+		}
+	}
+	return ""
+}
+
+// EnsureDockerfile 在 opt.CtxPath 中没有 opt.DockerFilePath 对应的文件时，
+// 使用 genOpt 生成一个（genOpt.Language 为空时自动检测）并保存在 opt.Dockerfile 中，
+// 构建时加入构建上下文而不会写入 opt.CtxPath，返回是否生成了 Dockerfile
+func EnsureDockerfile(opt *BuildOption, genOpt *GenerateOption) (bool, error) {
+	path := dockerfilePath(opt)
+	if _, err := os.Stat(path); err == nil {
+		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+
+	gen := *genOpt
+	if gen.Language == "" {
+		language, ok := DetectLanguage(opt.CtxPath)
+		if !ok {
+			return false, fmt.Errorf("no Dockerfile in %s and the project language is unknown", opt.CtxPath)
+		}
+		gen.Language = language
+	}
+	if gen.Language == LanguagePython && gen.Manifest == "" {
+		gen.Manifest = pythonManifest(opt.CtxPath)
+	}
+	if gen.Language == LanguageJava && !gen.Gradle {
+		if _, err := os.Stat(filepath.Join(opt.CtxPath, "pom.xml")); os.IsNotExist(err) {
+			gen.Gradle = true
+		}
+	}
+	content, err := GenerateDockerfile(&gen)
+	if err != nil {
+		return false, err
+	}
+	opt.Dockerfile = content
+	return true, nil
+}
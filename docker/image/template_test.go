@@ -0,0 +1,158 @@
+package image
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateDockerfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		opt      GenerateOption
+		contains []string
+		wantErr  bool
+	}{
+		{name: "go", opt: GenerateOption{Language: LanguageGo, Port: 8080}, contains: []string{"FROM golang:1.17 AS build", "go build -o /out/app .", "EXPOSE 8080"}},
+		{name: "python", opt: GenerateOption{Language: LanguagePython, Version: "3.9", Main: "app.py"}, contains: []string{"FROM python:3.9-slim", `CMD ["python", "app.py"]`}},
+		{name: "node", opt: GenerateOption{Language: LanguageNode}, contains: []string{"FROM node:16-slim", `CMD ["npm", "start"]`}},
+		{name: "java maven", opt: GenerateOption{Language: LanguageJava}, contains: []string{"FROM maven:3-eclipse-temurin-17 AS build", "FROM eclipse-temurin:17-jre"}},
+		{name: "java gradle", opt: GenerateOption{Language: LanguageJava, Gradle: true}, contains: []string{"FROM gradle:jdk17 AS build"}},
+		{name: "unknown", opt: GenerateOption{Language: "rust"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GenerateDockerfile(&tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateDockerfile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for _, s := range tt.contains {
+				if !strings.Contains(string(got), s) {
+					t.Errorf("GenerateDockerfile() missing %q in\n%s", s, got)
+				}
+			}
+			// 生成的 Dockerfile 本身应当通过检查
+			findings, err := LintDockerfile(bytes.NewReader(got))
+			if err != nil || len(findings) > 0 {
+				t.Errorf("LintDockerfile() = %v, %v\n%s", findings, err, got)
+			}
+		})
+	}
+}
+
+func TestEnsureDockerfile(t *testing.T) {
+	dir := writeContext(t, map[string]string{"build.gradle": "", "src/Main.java": ""})
+	opt := &BuildOption{CtxPath: dir, DockerFilePath: "Dockerfile"}
+	generated, err := EnsureDockerfile(opt, &GenerateOption{})
+	if err != nil || !generated {
+		t.Fatalf("EnsureDockerfile() = %v, %v", generated, err)
+	}
+	if !strings.Contains(string(opt.Dockerfile), "FROM gradle:") {
+		t.Errorf("Dockerfile = %s", opt.Dockerfile)
+	}
+	// 生成的 Dockerfile 不写入源码目录，只加入构建上下文
+	if _, err := os.Stat(filepath.Join(dir, "Dockerfile")); !os.IsNotExist(err) {
+		t.Errorf("Dockerfile written into the context dir: %v", err)
+	}
+	files := tarFiles(t, opt)
+	if files["Dockerfile"] != string(opt.Dockerfile) || files["src/Main.java"] != "" {
+		t.Errorf("build context = %v", files)
+	}
+
+	existing := writeContext(t, map[string]string{"go.mod": "", "Dockerfile": "FROM scratch"})
+	opt = &BuildOption{CtxPath: existing, DockerFilePath: "Dockerfile"}
+	generated, err = EnsureDockerfile(opt, &GenerateOption{Language: LanguageGo})
+	if err != nil || generated || opt.Dockerfile != nil {
+		t.Errorf("EnsureDockerfile() should keep the existing Dockerfile, got %v, %v", generated, err)
+	}
+
+	// DockerFilePath 为空时与 Build 一样使用 Dockerfile
+	opt = &BuildOption{CtxPath: writeContext(t, map[string]string{"go.mod": ""})}
+	generated, err = EnsureDockerfile(opt, &GenerateOption{})
+	if err != nil || !generated {
+		t.Fatalf("EnsureDockerfile() with an empty DockerFilePath = %v, %v", generated, err)
+	}
+	if files := tarFiles(t, opt); files["Dockerfile"] != string(opt.Dockerfile) {
+		t.Errorf("build context = %v", files)
+	}
+	if _, err := LintBuild(&BuildOption{CtxPath: existing}); err != nil {
+		t.Errorf("LintBuild() with an empty DockerFilePath error = %v", err)
+	}
+
+	empty := t.TempDir()
+	if _, err := EnsureDockerfile(&BuildOption{CtxPath: empty, DockerFilePath: "Dockerfile"}, &GenerateOption{}); err == nil {
+		t.Errorf("EnsureDockerfile() should fail for unknown projects")
+	}
+}
+
+func TestEnsureDockerfile_python(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    map[string]string
+		contains []string
+		excludes []string
+	}{
+		{name: "requirements", files: map[string]string{"requirements.txt": "flask"}, contains: []string{"COPY requirements.txt ./", "pip install --no-cache-dir -r requirements.txt"}},
+		{name: "pyproject", files: map[string]string{"pyproject.toml": ""}, contains: []string{"pip install --no-cache-dir ."}, excludes: []string{"requirements.txt"}},
+		{name: "setup.py", files: map[string]string{"setup.py": ""}, contains: []string{"pip install --no-cache-dir ."}, excludes: []string{"requirements.txt"}},
+		{name: "no manifest", files: map[string]string{"main.py": ""}, excludes: []string{"pip install"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := &BuildOption{CtxPath: writeContext(t, tt.files), DockerFilePath: "Dockerfile"}
+			if _, err := EnsureDockerfile(opt, &GenerateOption{Language: LanguagePython}); err != nil {
+				t.Fatalf("EnsureDockerfile() error = %v", err)
+			}
+			for _, c := range tt.contains {
+				if !strings.Contains(string(opt.Dockerfile), c) {
+					t.Errorf("Dockerfile does not contain %q:\n%s", c, opt.Dockerfile)
+				}
+			}
+			for _, c := range tt.excludes {
+				if strings.Contains(string(opt.Dockerfile), c) {
+					t.Errorf("Dockerfile should not contain %q:\n%s", c, opt.Dockerfile)
+				}
+			}
+			findings, err := LintBuild(opt)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, f := range findings {
+				if f.Severity == SeverityError {
+					t.Errorf("generated Dockerfile has lint error: %s", f)
+				}
+			}
+		})
+	}
+}
+
+// tarFiles 返回 opt 对应的构建上下文中的文件内容
+func tarFiles(t *testing.T, opt *BuildOption) map[string]string {
+	t.Helper()
+	buildCtx, err := buildContext(opt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer buildCtx.Close()
+	files := make(map[string]string)
+	tr := tar.NewReader(buildCtx)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return files
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := ioutil.ReadAll(tr)
+		files[hdr.Name] = string(content)
+	}
+}
@@ -0,0 +1,38 @@
+package docker
+
+import (
+	"time"
+)
+
+// 本库创建的容器、网络与数据卷都会带上以下 label，用于在进程崩溃后识别并清理遗留资源
+const (
+	LabelTool      = "loclo.tool"
+	LabelJobID     = "loclo.job-id"
+	LabelOwner     = "loclo.owner"
+	LabelCreatedAt = "loclo.created-at"
+
+	ToolName = "loclo"
+)
+
+// Ownership 描述资源的归属
+type Ownership struct {
+	JobID string
+	Owner string
+}
+
+// Labels 返回带有归属信息的 label，extra 中的 label 会被一并复制，但不能覆盖归属信息
+func (o Ownership) Labels(extra map[string]string) map[string]string {
+	labels := make(map[string]string, len(extra)+4)
+	for k, v := range extra {
+		labels[k] = v
+	}
+	labels[LabelTool] = ToolName
+	labels[LabelCreatedAt] = time.Now().UTC().Format(time.RFC3339)
+	if o.JobID != "" {
+		labels[LabelJobID] = o.JobID
+	}
+	if o.Owner != "" {
+		labels[LabelOwner] = o.Owner
+	}
+	return labels
+}
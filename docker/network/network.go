@@ -0,0 +1,53 @@
+package network
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/loheagn/loclo/docker"
+)
+
+// Client 在 docker.Client 上提供网络相关的操作
+type Client struct {
+	cli *docker.Client
+}
+
+func NewClient(cli *docker.Client) *Client {
+	return &Client{cli: cli}
+}
+
+type CreateOption struct {
+	Name string
+	// Driver 默认为 bridge
+	Driver string
+	Labels map[string]string
+	Owner  docker.Ownership
+}
+
+// Create 创建网络并返回网络 ID
+func (c *Client) Create(ctx context.Context, opt *CreateOption) (string, error) {
+	resp, err := c.cli.NetworkCreate(ctx, opt.Name, types.NetworkCreate{
+		CheckDuplicate: true,
+		Driver:         opt.Driver,
+		Labels:         opt.Owner.Labels(opt.Labels),
+	})
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (c *Client) Remove(ctx context.Context, id string) error {
+	return c.cli.NetworkRemove(ctx, id)
+}
+
+// ListByLabels 列出带有全部指定 label 的网络
+func (c *Client) ListByLabels(ctx context.Context, labels map[string]string) ([]types.NetworkResource, error) {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", fmt.Sprintf("%s=%s", k, v))
+	}
+	return c.cli.NetworkList(ctx, types.NetworkListOptions{Filters: args})
+}
@@ -0,0 +1,254 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/pkg/stringid"
+	"github.com/loheagn/loclo/docker"
+	"github.com/loheagn/loclo/docker/container"
+	"github.com/loheagn/loclo/docker/image"
+)
+
+var ErrNoImage = errors.New("no image has been built before this step")
+
+// Pipeline 按顺序执行一组步骤，例如构建镜像、用构建出的镜像运行测试、测试通过后推送。
+// 任意一步失败时停止执行，并删除本次构建产生的 tag 与镜像
+type Pipeline struct {
+	HostURL string
+	Steps   []Step
+	// KeepOnFailure 为 true 时失败后保留构建产生的 tag，便于排查
+	KeepOnFailure bool
+	// JobID 记录在 TestStep 运行的容器的 label 中，为空时每次 Run 生成一个
+	JobID string
+
+	// engine 为空时使用 HostURL 对应的 Docker client，测试时可以替换
+	engine engine
+}
+
+// engine 是 Pipeline 用到的 Docker 操作
+type engine interface {
+	build(ctx context.Context, opt *image.BuildOption) (string, error)
+	run(ctx context.Context, opt *container.RunOption) (output string, exitCode int, err error)
+	push(ctx context.Context, opt *image.PushOption) (string, error)
+	remove(ctx context.Context, ref string) error
+}
+
+// dockerEngine 使用 Docker client 实现 engine
+type dockerEngine struct {
+	images     *image.Client
+	containers *container.Client
+}
+
+func newDockerEngine(cli *docker.Client) *dockerEngine {
+	return &dockerEngine{images: image.NewClient(cli), containers: container.NewClient(cli)}
+}
+
+func (d *dockerEngine) build(ctx context.Context, opt *image.BuildOption) (string, error) {
+	return d.images.Build(ctx, opt)
+}
+
+func (d *dockerEngine) run(ctx context.Context, opt *container.RunOption) (string, int, error) {
+	return d.containers.Run(ctx, opt)
+}
+
+func (d *dockerEngine) push(ctx context.Context, opt *image.PushOption) (string, error) {
+	return d.images.Push(ctx, opt)
+}
+
+func (d *dockerEngine) remove(ctx context.Context, ref string) error {
+	return d.images.Remove(ctx, ref)
+}
+
+// Step 是 Pipeline 中的一个步骤，可选 BuildStep、TestStep 与 PushStep
+type Step interface {
+	name() string
+	run(ctx context.Context, env *env) (output string, err error)
+}
+
+// env 在步骤之间传递构建结果
+type env struct {
+	engine  engine
+	jobID   string
+	imageID string
+	// image 是后续步骤默认使用的镜像：最近一次构建得到的镜像 ID，无法解析 ID 时为第一个 tag
+	image string
+	// tags 是本次构建产生的所有 tag
+	tags []string
+	// untagged 是本次构建产生的没有 tag 的镜像 ID，不包含命中缓存的镜像
+	untagged []string
+}
+
+type StepResult struct {
+	Name     string
+	Output   string
+	Duration time.Duration
+	Err      error
+}
+
+type Result struct {
+	// JobID 是本次执行记录在容器上的 job ID
+	JobID string
+	// ImageID 是最后一次构建得到的镜像 ID
+	ImageID  string
+	Steps    []StepResult
+	Duration time.Duration
+}
+
+// Run 执行 Pipeline，返回的 Result 中包含已执行步骤的输出与耗时，即使 err 不为空
+func (p *Pipeline) Run(ctx context.Context) (result *Result, err error) {
+	start := time.Now()
+	result = &Result{}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	e := &env{engine: p.engine, jobID: p.JobID}
+	if e.engine == nil {
+		cli, err := docker.GetClient(ctx, &docker.InitOption{
+			Host: p.HostURL,
+		})
+		if err != nil {
+			return result, err
+		}
+		e.engine = newDockerEngine(cli)
+	}
+	if e.jobID == "" {
+		e.jobID = stringid.TruncateID(stringid.GenerateRandomID())
+	}
+	result.JobID = e.jobID
+	defer func() {
+		if err != nil && !p.KeepOnFailure {
+			e.cleanup(context.Background())
+		}
+	}()
+
+	for _, step := range p.Steps {
+		stepStart := time.Now()
+		output, stepErr := step.run(ctx, e)
+		result.Steps = append(result.Steps, StepResult{
+			Name:     step.name(),
+			Output:   output,
+			Duration: time.Since(stepStart),
+			Err:      stepErr,
+		})
+		result.ImageID = e.imageID
+		if stepErr != nil {
+			return result, fmt.Errorf("step %s: %w", step.name(), stepErr)
+		}
+	}
+	return result, nil
+}
+
+// cleanup 删除本次构建产生的 tag 与没有 tag 的镜像
+func (e *env) cleanup(ctx context.Context) {
+	for _, ref := range append(e.tags, e.untagged...) {
+		_ = e.engine.remove(ctx, ref)
+	}
+}
+
+// BuildStep 构建镜像，构建结果供后续步骤使用
+type BuildStep struct {
+	Name   string
+	Option image.BuildOption
+}
+
+func (s *BuildStep) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "build"
+}
+
+func (s *BuildStep) run(ctx context.Context, e *env) (string, error) {
+	output, err := e.engine.build(ctx, &s.Option)
+	if err != nil {
+		return output, err
+	}
+	e.tags = append(e.tags, s.Option.Tags...)
+	id, ok := image.ParseImageID(output)
+	if ok {
+		e.imageID = id
+		// 命中缓存时镜像在本次运行之前就已经存在，例如上一次运行的结果，不能在清理时移除
+		if len(s.Option.Tags) == 0 && !image.IsCachedOutput(output) {
+			e.untagged = append(e.untagged, id)
+		}
+	}
+	// 优先使用镜像 ID，tag 可能在构建后被其他构建覆盖
+	switch {
+	case ok:
+		e.image = id
+	case len(s.Option.Tags) > 0:
+		e.image = s.Option.Tags[0]
+	default:
+		return output, fmt.Errorf("cannot find the built image")
+	}
+	return output, nil
+}
+
+// TestStep 运行一个容器，退出码不为 0 时失败。Option.Image 为空时使用前面构建出的镜像，
+// Option.Owner.JobID 会被设置为 Pipeline 的 JobID
+type TestStep struct {
+	Name   string
+	Option container.RunOption
+}
+
+func (s *TestStep) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "test"
+}
+
+func (s *TestStep) run(ctx context.Context, e *env) (string, error) {
+	opt := s.Option
+	if opt.Image == "" {
+		if opt.Image = e.image; opt.Image == "" {
+			return "", ErrNoImage
+		}
+	}
+	opt.Owner.JobID = e.jobID
+	output, exitCode, err := e.engine.run(ctx, &opt)
+	if err != nil {
+		return output, err
+	}
+	if exitCode != 0 {
+		return output, fmt.Errorf("exit with code %d", exitCode)
+	}
+	return output, nil
+}
+
+// PushStep 推送镜像。Option.Tag 为空时推送前面构建产生的所有 tag
+type PushStep struct {
+	Name   string
+	Option image.PushOption
+}
+
+func (s *PushStep) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return "push"
+}
+
+func (s *PushStep) run(ctx context.Context, e *env) (string, error) {
+	tags := []string{s.Option.Tag}
+	if s.Option.Tag == "" {
+		if tags = e.tags; len(tags) <= 0 {
+			return "", ErrNoImage
+		}
+	}
+	var outputs string
+	for _, tag := range tags {
+		opt := s.Option
+		opt.Tag = tag
+		output, err := e.engine.push(ctx, &opt)
+		outputs += output
+		if err != nil {
+			return outputs, err
+		}
+	}
+	return outputs, nil
+}
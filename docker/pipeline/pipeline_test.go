@@ -0,0 +1,134 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/loheagn/loclo/docker/container"
+	"github.com/loheagn/loclo/docker/image"
+)
+
+// fakeEngine 记录 Pipeline 的 Docker 操作，每次构建得到一个新的镜像 ID
+type fakeEngine struct {
+	builds   int
+	exitCode int
+	runs     []container.RunOption
+	pushed   []string
+	removed  []string
+	delay    time.Duration
+	// cached 为 true 时构建结果与 image.Build 命中缓存时的输出相同
+	cached bool
+}
+
+func (f *fakeEngine) build(_ context.Context, opt *image.BuildOption) (string, error) {
+	f.builds++
+	return fmt.Sprintf(`{"aux":{"ID":"sha256:%d","Cached":%t}}`, f.builds, f.cached), nil
+}
+
+func (f *fakeEngine) run(_ context.Context, opt *container.RunOption) (string, int, error) {
+	time.Sleep(f.delay)
+	f.runs = append(f.runs, *opt)
+	return "ran " + opt.Image, f.exitCode, nil
+}
+
+func (f *fakeEngine) push(_ context.Context, opt *image.PushOption) (string, error) {
+	f.pushed = append(f.pushed, opt.Tag)
+	return "pushed " + opt.Tag, nil
+}
+
+func (f *fakeEngine) remove(_ context.Context, ref string) error {
+	f.removed = append(f.removed, ref)
+	return nil
+}
+
+func steps(tags ...string) []Step {
+	return []Step{
+		&BuildStep{Option: image.BuildOption{Tags: tags}},
+		&TestStep{Option: container.RunOption{Cmd: []string{"go", "test", "./..."}}},
+		&PushStep{},
+	}
+}
+
+func TestPipeline_Run(t *testing.T) {
+	engine := &fakeEngine{delay: 10 * time.Millisecond}
+	p := &Pipeline{Steps: steps("app:v1", "app:latest"), JobID: "job-1", engine: engine}
+	result, err := p.Run(context.Background())
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var names []string
+	var total time.Duration
+	for _, step := range result.Steps {
+		names = append(names, step.Name)
+		total += step.Duration
+		if step.Err != nil {
+			t.Errorf("step %s error = %v", step.Name, step.Err)
+		}
+	}
+	if want := []string{"build", "test", "push"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("steps = %v, want %v", names, want)
+	}
+	if result.Steps[1].Duration < engine.delay || result.Duration < total {
+		t.Errorf("durations = %+v, total %v", result.Steps, result.Duration)
+	}
+	if result.ImageID != "sha256:1" || result.JobID != "job-1" {
+		t.Errorf("result = %+v", result)
+	}
+	// 测试使用构建得到的镜像 ID 而不是 tag
+	if run := engine.runs[0]; run.Image != "sha256:1" || run.Owner.JobID != "job-1" {
+		t.Errorf("run option = %+v", run)
+	}
+	if want := []string{"app:v1", "app:latest"}; !reflect.DeepEqual(engine.pushed, want) {
+		t.Errorf("pushed = %v, want %v", engine.pushed, want)
+	}
+	if len(engine.removed) != 0 {
+		t.Errorf("removed = %v, want nothing", engine.removed)
+	}
+}
+
+func TestPipeline_Run_failure(t *testing.T) {
+	tests := []struct {
+		name          string
+		tags          []string
+		keepOnFailure bool
+		cached        bool
+		wantRemoved   []string
+	}{
+		{name: "remove tags", tags: []string{"app:v1", "app:latest"}, wantRemoved: []string{"app:v1", "app:latest"}},
+		{name: "remove untagged image", wantRemoved: []string{"sha256:1"}},
+		{name: "keep on failure", tags: []string{"app:v1"}, keepOnFailure: true},
+		{name: "keep cached image", cached: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &fakeEngine{exitCode: 1, cached: tt.cached}
+			p := &Pipeline{Steps: steps(tt.tags...), KeepOnFailure: tt.keepOnFailure, engine: engine}
+			result, err := p.Run(context.Background())
+			if err == nil {
+				t.Fatalf("Run() should fail when the test exits with a non-zero code")
+			}
+			// 失败后停止执行，不会推送
+			if len(result.Steps) != 2 || result.Steps[1].Err == nil || len(engine.pushed) != 0 {
+				t.Errorf("steps = %+v, pushed = %v", result.Steps, engine.pushed)
+			}
+			if result.JobID == "" {
+				t.Errorf("JobID should be generated")
+			}
+			if !reflect.DeepEqual(engine.removed, tt.wantRemoved) {
+				t.Errorf("removed = %v, want %v", engine.removed, tt.wantRemoved)
+			}
+		})
+	}
+}
+
+func TestPipeline_Run_noImage(t *testing.T) {
+	p := &Pipeline{Steps: []Step{&TestStep{}}, engine: &fakeEngine{}}
+	if _, err := p.Run(context.Background()); err == nil || !errors.Is(err, ErrNoImage) {
+		t.Errorf("Run() error = %v, want %v", err, ErrNoImage)
+	}
+}
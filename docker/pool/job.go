@@ -0,0 +1,150 @@
+package pool
+
+import (
+	"container/heap"
+	"context"
+	"time"
+
+	"github.com/loheagn/loclo/docker/container"
+)
+
+// Status 表示 job 的执行状态
+type Status string
+
+const (
+	StatusQueued   Status = "queued"
+	StatusRunning  Status = "running"
+	StatusDone     Status = "done"
+	StatusCanceled Status = "canceled"
+)
+
+type Job struct {
+	// ID 是随机生成的，进程重启或存在多个 Pool 时也不会重复，会作为 docker.LabelJobID 记录在容器上
+	ID string
+
+	pool *Pool
+	opt  *JobOption
+	// seq 是提交顺序，只用于相同优先级的 job 按先进先出排序
+	seq      uint64
+	queueIdx int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	// 以下字段由 pool.mu 保护
+	status  Status
+	hostURL string
+	result  *container.RunResult
+	err     error
+}
+
+// Status 返回 job 当前的状态
+func (j *Job) Status() Status {
+	j.pool.mu.Lock()
+	defer j.pool.mu.Unlock()
+	return j.status
+}
+
+// HostURL 返回 job 被分配到的 host，排队中的 job 返回空字符串
+func (j *Job) HostURL() string {
+	j.pool.mu.Lock()
+	defer j.pool.mu.Unlock()
+	return j.hostURL
+}
+
+// Done 返回一个在 job 结束后关闭的 channel
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Result 返回 job 的运行结果，job 尚未结束时返回 nil
+func (j *Job) Result() (*container.RunResult, error) {
+	j.pool.mu.Lock()
+	defer j.pool.mu.Unlock()
+	return j.result, j.err
+}
+
+// Wait 阻塞直到 job 结束或 ctx 被取消
+func (j *Job) Wait(ctx context.Context) (*container.RunResult, error) {
+	select {
+	case <-j.done:
+		return j.Result()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Cancel 取消 job：排队中的 job 直接出队，运行中的 job 会停止并移除其容器
+func (j *Job) Cancel() {
+	j.pool.mu.Lock()
+	if j.status != StatusQueued {
+		j.pool.mu.Unlock()
+		j.cancel()
+		return
+	}
+	heap.Remove(&j.pool.queue, j.queueIdx)
+	j.finish(nil, ErrJobCanceled)
+	j.pool.mu.Unlock()
+
+	j.callback()
+}
+
+// finish 记录 job 的结果，调用者需持有 pool.mu
+func (j *Job) finish(result *container.RunResult, err error) {
+	j.result, j.err = result, err
+	if err == ErrJobCanceled {
+		j.status = StatusCanceled
+	} else {
+		j.status = StatusDone
+	}
+	j.cancel()
+	close(j.done)
+	time.AfterFunc(j.pool.retention, func() {
+		j.pool.Forget(j.ID)
+	})
+}
+
+// finished 判断 job 是否已经结束，调用者需持有 pool.mu
+func (j *Job) finished() bool {
+	return j.status == StatusDone || j.status == StatusCanceled
+}
+
+func (j *Job) callback() {
+	if j.opt.Callback != nil {
+		j.opt.Callback(j)
+	}
+}
+
+// jobQueue 是按优先级排序的最大堆
+type jobQueue []*Job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].opt.Priority != q[j].opt.Priority {
+		return q[i].opt.Priority > q[j].opt.Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q jobQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].queueIdx = i
+	q[j].queueIdx = j
+}
+
+func (q *jobQueue) Push(x interface{}) {
+	job := x.(*Job)
+	job.queueIdx = len(*q)
+	*q = append(*q, job)
+}
+
+func (q *jobQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	job := old[n-1]
+	old[n-1] = nil
+	job.queueIdx = -1
+	*q = old[:n-1]
+	return job
+}
@@ -0,0 +1,216 @@
+package pool
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/pkg/stringid"
+
+	"github.com/loheagn/loclo/docker/container"
+)
+
+const (
+	DefaultMaxConcurrency = 4
+	DefaultRetention      = 10 * time.Minute
+)
+
+var (
+	ErrPoolClosed  = errors.New("pool is closed")
+	ErrJobCanceled = errors.New("job canceled")
+	ErrNoHost      = errors.New("no docker host configured")
+	ErrNoRunOption = errors.New("job has no run option")
+)
+
+// HostOption 描述一个可供调度的 Docker host
+type HostOption struct {
+	HostURL string
+	// MaxConcurrency 该 host 上同时运行的最大 job 数，<= 0 时使用 DefaultMaxConcurrency
+	MaxConcurrency int
+}
+
+type PoolOption struct {
+	Hosts []HostOption
+	// Retention 是 job 结束后仍能通过 Pool.Job 查到的时间，<= 0 时使用 DefaultRetention
+	Retention time.Duration
+}
+
+type runFunc func(ctx context.Context, opt *container.RunOption) (*container.RunResult, error)
+
+type host struct {
+	url     string
+	max     int
+	running int
+}
+
+// load 返回 host 当前的负载比例
+func (h *host) load() float64 {
+	return float64(h.running) / float64(h.max)
+}
+
+// Pool 将 container.Run 包装成一个限制并发的任务队列，
+// 按优先级出队，并将 job 分配给当前负载最低的 host
+type Pool struct {
+	mu        sync.Mutex
+	hosts     []*host
+	queue     jobQueue
+	jobs      map[string]*Job
+	seq       uint64
+	closed    bool
+	retention time.Duration
+	run       runFunc
+}
+
+func NewPool(opt *PoolOption) (*Pool, error) {
+	if len(opt.Hosts) <= 0 {
+		return nil, ErrNoHost
+	}
+	p := &Pool{
+		jobs:      make(map[string]*Job),
+		retention: opt.Retention,
+		run:       container.RunWithUsage,
+	}
+	if p.retention <= 0 {
+		p.retention = DefaultRetention
+	}
+	for _, h := range opt.Hosts {
+		if h.MaxConcurrency <= 0 {
+			h.MaxConcurrency = DefaultMaxConcurrency
+		}
+		p.hosts = append(p.hosts, &host{url: h.HostURL, max: h.MaxConcurrency})
+	}
+	return p, nil
+}
+
+type JobOption struct {
+	// Run 中的 HostURL 会被忽略，由 Pool 决定 job 运行在哪个 host 上；
+	// Owner.JobID 会被设置为 job 的 ID，供 reaper 判断容器所属的 job 是否还存在
+	Run *container.RunOption
+	// Priority 越大越先执行，相同优先级按提交顺序执行
+	Priority int
+	// Callback 在 job 结束（包括被取消）后调用
+	Callback func(job *Job)
+}
+
+// Submit 提交一个 job，ctx 被取消时 job 也会被取消
+func (p *Pool) Submit(ctx context.Context, opt *JobOption) (*Job, error) {
+	if opt == nil || opt.Run == nil {
+		return nil, ErrNoRunOption
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, ErrPoolClosed
+	}
+
+	p.seq++
+	ctx, cancel := context.WithCancel(ctx)
+	job := &Job{
+		ID:       stringid.GenerateRandomID(),
+		pool:     p,
+		opt:      opt,
+		seq:      p.seq,
+		status:   StatusQueued,
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		queueIdx: -1,
+	}
+	p.jobs[job.ID] = job
+	heap.Push(&p.queue, job)
+	p.schedule()
+
+	// ctx 在排队期间被取消时，将 job 移出队列
+	go func() {
+		select {
+		case <-ctx.Done():
+			job.Cancel()
+		case <-job.done:
+		}
+	}()
+	return job, nil
+}
+
+// Job 根据 ID 查找 job，已结束的 job 超过 Retention 或被 Forget 后查不到
+func (p *Pool) Job(id string) (*Job, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job, ok := p.jobs[id]
+	return job, ok
+}
+
+// Forget 移除一个已结束的 job，返回是否移除。排队或运行中的 job 不会被移除
+func (p *Pool) Forget(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	job, ok := p.jobs[id]
+	if !ok || !job.finished() {
+		return false
+	}
+	delete(p.jobs, id)
+	return true
+}
+
+// Close 拒绝新的 job，并取消所有尚未结束的 job
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	jobs := make([]*Job, 0, len(p.jobs))
+	for _, job := range p.jobs {
+		jobs = append(jobs, job)
+	}
+	p.mu.Unlock()
+
+	for _, job := range jobs {
+		job.Cancel()
+	}
+}
+
+// schedule 在有空闲 host 时将队首的 job 启动，调用者需持有 p.mu
+func (p *Pool) schedule() {
+	for p.queue.Len() > 0 {
+		h := p.pickHost()
+		if h == nil {
+			return
+		}
+		job := heap.Pop(&p.queue).(*Job)
+		h.running++
+		job.status = StatusRunning
+		job.hostURL = h.url
+		go p.execute(job, h)
+	}
+}
+
+// pickHost 返回负载最低且仍有空闲的 host
+func (p *Pool) pickHost() *host {
+	var picked *host
+	for _, h := range p.hosts {
+		if h.running >= h.max {
+			continue
+		}
+		if picked == nil || h.load() < picked.load() {
+			picked = h
+		}
+	}
+	return picked
+}
+
+func (p *Pool) execute(job *Job, h *host) {
+	runOpt := *job.opt.Run
+	runOpt.HostURL = h.url
+	runOpt.Owner.JobID = job.ID
+	result, err := p.run(job.ctx, &runOpt)
+	if job.ctx.Err() != nil && err != nil {
+		err = ErrJobCanceled
+	}
+
+	p.mu.Lock()
+	h.running--
+	job.finish(result, err)
+	p.schedule()
+	p.mu.Unlock()
+
+	job.callback()
+}
@@ -0,0 +1,208 @@
+package pool
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+	"github.com/loheagn/loclo/docker/container"
+)
+
+// fakeRunner 阻塞每个 job 直到 release 被关闭，并记录 job 的启动顺序
+type fakeRunner struct {
+	mu      sync.Mutex
+	started []string
+	opts    []container.RunOption
+	release chan struct{}
+}
+
+func (f *fakeRunner) run(ctx context.Context, opt *container.RunOption) (*container.RunResult, error) {
+	f.mu.Lock()
+	f.started = append(f.started, opt.Image)
+	f.opts = append(f.opts, *opt)
+	f.mu.Unlock()
+	select {
+	case <-f.release:
+		return &container.RunResult{Output: opt.HostURL}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func newTestPool(t *testing.T, runner *fakeRunner, hosts ...HostOption) *Pool {
+	p, err := NewPool(&PoolOption{Hosts: hosts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.run = runner.run
+	return p
+}
+
+func submit(t *testing.T, p *Pool, image string, priority int) *Job {
+	job, err := p.Submit(context.Background(), &JobOption{
+		Run:      &container.RunOption{Image: image},
+		Priority: priority,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func TestPool_SpreadHosts(t *testing.T) {
+	runner := &fakeRunner{release: make(chan struct{})}
+	p := newTestPool(t, runner,
+		HostOption{HostURL: "tcp://a:2375", MaxConcurrency: 1},
+		HostOption{HostURL: "tcp://b:2375", MaxConcurrency: 1},
+	)
+	first := submit(t, p, "first", 0)
+	second := submit(t, p, "second", 0)
+	queued := submit(t, p, "queued", 0)
+
+	if first.HostURL() == second.HostURL() {
+		t.Errorf("jobs should be spread across hosts, both got %s", first.HostURL())
+	}
+	if queued.Status() != StatusQueued {
+		t.Errorf("Status() = %s, want %s", queued.Status(), StatusQueued)
+	}
+
+	close(runner.release)
+	for _, job := range []*Job{first, second, queued} {
+		result, err := job.Wait(context.Background())
+		if err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+		if result.Output != job.HostURL() {
+			t.Errorf("job ran on %s, want %s", result.Output, job.HostURL())
+		}
+		if job.Status() != StatusDone {
+			t.Errorf("Status() = %s, want %s", job.Status(), StatusDone)
+		}
+	}
+}
+
+func TestPool_PriorityAndCancel(t *testing.T) {
+	runner := &fakeRunner{release: make(chan struct{})}
+	p := newTestPool(t, runner, HostOption{HostURL: "tcp://a:2375", MaxConcurrency: 1})
+
+	var (
+		mu       sync.Mutex
+		callback []string
+	)
+	first := submit(t, p, "first", 0)
+	low := submit(t, p, "low", 0)
+	high := submit(t, p, "high", 10)
+	canceled, err := p.Submit(context.Background(), &JobOption{
+		Run:      &container.RunOption{Image: "canceled"},
+		Priority: 5,
+		Callback: func(job *Job) {
+			mu.Lock()
+			defer mu.Unlock()
+			callback = append(callback, job.ID)
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.ID == low.ID || len(first.ID) != 64 {
+		t.Errorf("job IDs %q and %q should be unique random IDs", first.ID, low.ID)
+	}
+	canceled.Cancel()
+	if _, err := canceled.Wait(context.Background()); err != ErrJobCanceled {
+		t.Errorf("Wait() error = %v, want %v", err, ErrJobCanceled)
+	}
+	if canceled.Status() != StatusCanceled {
+		t.Errorf("Status() = %s, want %s", canceled.Status(), StatusCanceled)
+	}
+	if len(callback) != 1 || callback[0] != canceled.ID {
+		t.Errorf("callback = %v, want [%s]", callback, canceled.ID)
+	}
+
+	close(runner.release)
+	for _, job := range []*Job{first, low, high} {
+		if _, err := job.Wait(context.Background()); err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+	}
+
+	want := []string{"first", "high", "low"}
+	if len(runner.started) != len(want) {
+		t.Fatalf("started = %v, want %v", runner.started, want)
+	}
+	for i := range want {
+		if runner.started[i] != want[i] {
+			t.Errorf("started = %v, want %v", runner.started, want)
+			break
+		}
+	}
+}
+
+func TestPool_OwnerLabels(t *testing.T) {
+	runner := &fakeRunner{release: make(chan struct{})}
+	close(runner.release)
+	p := newTestPool(t, runner, HostOption{HostURL: "tcp://a:2375"})
+	job, err := p.Submit(context.Background(), &JobOption{
+		Run: &container.RunOption{Image: "job", Owner: docker.Ownership{JobID: "ignored", Owner: "student-1"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Wait(context.Background()); err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+
+	labels := runner.opts[0].Owner.Labels(nil)
+	if labels[docker.LabelJobID] != job.ID || labels[docker.LabelOwner] != "student-1" || labels[docker.LabelTool] != docker.ToolName {
+		t.Errorf("labels = %v, want job id %s", labels, job.ID)
+	}
+}
+
+func TestPool_ForgetAndRetention(t *testing.T) {
+	runner := &fakeRunner{release: make(chan struct{})}
+	p, err := NewPool(&PoolOption{Hosts: []HostOption{{HostURL: "tcp://a:2375", MaxConcurrency: 1}}, Retention: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.run = runner.run
+
+	if _, err := p.Submit(context.Background(), &JobOption{}); err != ErrNoRunOption {
+		t.Errorf("Submit() error = %v, want %v", err, ErrNoRunOption)
+	}
+
+	running := submit(t, p, "running", 0)
+	if p.Forget(running.ID) {
+		t.Errorf("Forget() should not remove a running job")
+	}
+	close(runner.release)
+	if _, err := running.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Forget(running.ID) {
+		t.Errorf("Forget() should remove a finished job")
+	}
+	if _, ok := p.Job(running.ID); ok {
+		t.Errorf("Job() found a forgotten job")
+	}
+
+	// 结束的 job 超过 Retention 后被自动移除
+	expired := submit(t, p, "expired", 0)
+	if _, err := expired.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.Job(expired.ID); !ok {
+		t.Errorf("Job() should find a job within retention")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := p.Job(expired.ID); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s not evicted after retention", expired.ID)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,152 @@
+package reaper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+	"github.com/loheagn/loclo/docker/container"
+	"github.com/loheagn/loclo/docker/network"
+	"github.com/loheagn/loclo/docker/volume"
+)
+
+type Kind string
+
+const (
+	KindContainer Kind = "container"
+	KindNetwork   Kind = "network"
+	KindVolume    Kind = "volume"
+)
+
+type Option struct {
+	HostURL string
+	// TTL 大于 0 时，创建时间早于 TTL 的资源会被移除
+	TTL time.Duration
+	// JobAlive 不为空时，所属 job 已经不存在的资源会被移除
+	JobAlive func(jobID string) bool
+	// Owner 不为空时只处理属于该 owner 的资源
+	Owner string
+	// DryRun 为 true 时只报告需要移除的资源，不做任何修改
+	DryRun bool
+}
+
+// Resource 是一个由本库创建的资源
+type Resource struct {
+	Kind      Kind
+	ID        string
+	Name      string
+	JobID     string
+	Owner     string
+	CreatedAt time.Time
+	// Reason 说明资源为什么会被移除
+	Reason string
+	// Err 是移除资源时遇到的错误
+	Err error
+}
+
+// Report 是一次清理的结果，DryRun 时 Removed 中的资源并没有真正被移除
+type Report struct {
+	DryRun  bool
+	Removed []Resource
+	Failed  []Resource
+}
+
+// Reap 找出 opt.HostURL 上由本库创建、已经过期或所属 job 已不存在的容器、网络与数据卷并移除。
+// 容器会先于网络和数据卷被移除，以免它们仍在被使用
+func Reap(ctx context.Context, opt *Option) (*Report, error) {
+	cli, err := docker.GetClient(ctx, &docker.InitOption{
+		Host: opt.HostURL,
+	})
+	if err != nil {
+		return nil, err
+	}
+	labels := map[string]string{docker.LabelTool: docker.ToolName}
+	if opt.Owner != "" {
+		labels[docker.LabelOwner] = opt.Owner
+	}
+
+	containerCli := container.NewClient(cli)
+	containers, err := containerCli.ListByLabels(ctx, labels)
+	if err != nil {
+		return nil, fmt.Errorf("list containers: %w", err)
+	}
+	networkCli := network.NewClient(cli)
+	networks, err := networkCli.ListByLabels(ctx, labels)
+	if err != nil {
+		return nil, fmt.Errorf("list networks: %w", err)
+	}
+	volumeCli := volume.NewClient(cli)
+	volumes, err := volumeCli.ListByLabels(ctx, labels)
+	if err != nil {
+		return nil, fmt.Errorf("list volumes: %w", err)
+	}
+
+	var resources []Resource
+	for _, c := range containers {
+		name := ""
+		if len(c.Names) > 0 {
+			name = c.Names[0]
+		}
+		resources = append(resources, newResource(KindContainer, c.ID, name, c.Labels, time.Unix(c.Created, 0)))
+	}
+	for _, n := range networks {
+		resources = append(resources, newResource(KindNetwork, n.ID, n.Name, n.Labels, n.Created))
+	}
+	for _, v := range volumes {
+		created, _ := time.Parse(time.RFC3339, v.CreatedAt)
+		resources = append(resources, newResource(KindVolume, v.Name, v.Name, v.Labels, created))
+	}
+
+	report := &Report{DryRun: opt.DryRun}
+	now := time.Now()
+	for _, res := range resources {
+		if !opt.judge(&res, now) {
+			continue
+		}
+		if !opt.DryRun {
+			switch res.Kind {
+			case KindContainer:
+				res.Err = containerCli.Remove(ctx, res.ID)
+			case KindNetwork:
+				res.Err = networkCli.Remove(ctx, res.ID)
+			case KindVolume:
+				res.Err = volumeCli.Remove(ctx, res.ID)
+			}
+		}
+		if res.Err != nil {
+			report.Failed = append(report.Failed, res)
+		} else {
+			report.Removed = append(report.Removed, res)
+		}
+	}
+	return report, nil
+}
+
+// newResource 从 label 中读取归属信息，创建时间优先使用 label 中记录的时间
+func newResource(kind Kind, id, name string, labels map[string]string, created time.Time) Resource {
+	if t, err := time.Parse(time.RFC3339, labels[docker.LabelCreatedAt]); err == nil {
+		created = t
+	}
+	return Resource{
+		Kind:      kind,
+		ID:        id,
+		Name:      name,
+		JobID:     labels[docker.LabelJobID],
+		Owner:     labels[docker.LabelOwner],
+		CreatedAt: created,
+	}
+}
+
+// judge 判断资源是否需要移除，并记录原因
+func (opt *Option) judge(res *Resource, now time.Time) bool {
+	if opt.JobAlive != nil && res.JobID != "" && !opt.JobAlive(res.JobID) {
+		res.Reason = fmt.Sprintf("job %s is gone", res.JobID)
+		return true
+	}
+	if opt.TTL > 0 && !res.CreatedAt.IsZero() && now.Sub(res.CreatedAt) > opt.TTL {
+		res.Reason = fmt.Sprintf("older than %s", opt.TTL)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package reaper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/loheagn/loclo/docker"
+)
+
+func TestOption_judge(t *testing.T) {
+	now := time.Now()
+	alive := func(jobID string) bool {
+		return jobID == "running"
+	}
+	tests := []struct {
+		name   string
+		opt    *Option
+		labels map[string]string
+		create time.Time
+		want   bool
+	}{
+		{
+			name:   "expired",
+			opt:    &Option{TTL: time.Hour},
+			labels: map[string]string{docker.LabelCreatedAt: now.Add(-2 * time.Hour).UTC().Format(time.RFC3339)},
+			want:   true,
+		},
+		{
+			name:   "fresh",
+			opt:    &Option{TTL: time.Hour},
+			labels: map[string]string{docker.LabelCreatedAt: now.Add(-time.Minute).UTC().Format(time.RFC3339)},
+			want:   false,
+		},
+		{
+			name:   "expired-without-label",
+			opt:    &Option{TTL: time.Hour},
+			create: now.Add(-2 * time.Hour),
+			want:   true,
+		},
+		{
+			name:   "job-gone",
+			opt:    &Option{JobAlive: alive},
+			labels: map[string]string{docker.LabelJobID: "finished"},
+			want:   true,
+		},
+		{
+			name:   "job-running",
+			opt:    &Option{TTL: time.Hour, JobAlive: alive},
+			labels: map[string]string{docker.LabelJobID: "running"},
+			create: now,
+			want:   false,
+		},
+		{
+			name: "no-job-no-ttl",
+			opt:  &Option{JobAlive: alive},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := newResource(KindContainer, "id", "name", tt.labels, tt.create)
+			if got := tt.opt.judge(&res, now); got != tt.want {
+				t.Errorf("judge() = %v, want %v", got, tt.want)
+			}
+			if tt.want && res.Reason == "" {
+				t.Errorf("judge() should record a reason")
+			}
+		})
+	}
+}
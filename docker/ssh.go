@@ -0,0 +1,201 @@
+package docker
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sshDummyHost 是通过 ssh 连接时交给 Docker client 的占位地址，实际连接由 dialer 建立
+const sshDummyHost = "http://docker.example.com"
+
+// sshSpec 描述 ssh://[user@]host[:port] 形式的 Docker host
+type sshSpec struct {
+	user string
+	host string
+	port string
+}
+
+func parseSSHHost(host string) (*sshSpec, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "ssh" {
+		return nil, fmt.Errorf("invalid ssh host %q: scheme must be ssh", host)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("invalid ssh host %q: no host specified", host)
+	}
+	if u.Path != "" && u.Path != "/" {
+		return nil, fmt.Errorf("invalid ssh host %q: path is not supported", host)
+	}
+	if _, ok := u.User.Password(); ok {
+		return nil, fmt.Errorf("invalid ssh host %q: plain-text password is not supported", host)
+	}
+	return &sshSpec{
+		user: u.User.Username(),
+		host: u.Hostname(),
+		port: u.Port(),
+	}, nil
+}
+
+// args 返回 ssh 命令的参数，远端通过 `docker system dial-stdio` 将 stdio 转发到 daemon socket。
+// 使用 BatchMode 禁止交互式的密码与 host key 确认，认证失败时立即退出而不是阻塞
+func (s *sshSpec) args() []string {
+	args := []string{"-o", "BatchMode=yes"}
+	if s.user != "" {
+		args = append(args, "-l", s.user)
+	}
+	if s.port != "" {
+		args = append(args, "-p", s.port)
+	}
+	return append(args, "--", s.host, "docker", "system", "dial-stdio")
+}
+
+// dial 启动一个 ssh 进程，并把它的 stdin/stdout 包装成 net.Conn。
+// 连接的生命周期长于 dial 的 ctx，因此进程不随 ctx 结束
+func (s *sshSpec) dial(_ context.Context, _, _ string) (net.Conn, error) {
+	return newCommandConn(exec.Command("ssh", s.args()...), s.host)
+}
+
+// sshExitTimeout 是连接读到 EOF 后等待 ssh 进程退出的时间
+const sshExitTimeout = 3 * time.Second
+
+// commandConn 将一个子进程的 stdin/stdout 作为连接使用，stderr 被保留下来用于错误信息
+type commandConn struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout io.ReadCloser
+	stderr *tailBuffer
+	remote string
+
+	waitOnce  sync.Once
+	waitDone  chan struct{}
+	waitErr   error
+	closeOnce sync.Once
+}
+
+func newCommandConn(cmd *exec.Cmd, remote string) (*commandConn, error) {
+	c := &commandConn{cmd: cmd, stderr: &tailBuffer{}, remote: remote, waitDone: make(chan struct{})}
+	var err error
+	if c.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, err
+	}
+	if c.stdout, err = cmd.StdoutPipe(); err != nil {
+		return nil, err
+	}
+	cmd.Stderr = c.stderr
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start ssh to %s: %w", remote, err)
+	}
+	return c, nil
+}
+
+// wait 等待进程退出，可以被多次调用
+func (c *commandConn) wait() <-chan struct{} {
+	c.waitOnce.Do(func() {
+		go func() {
+			c.waitErr = c.cmd.Wait()
+			close(c.waitDone)
+		}()
+	})
+	return c.waitDone
+}
+
+func (c *commandConn) Read(p []byte) (int, error) {
+	n, err := c.stdout.Read(p)
+	if err == io.EOF {
+		return n, c.onEOF()
+	}
+	return n, c.wrapErr(err)
+}
+
+func (c *commandConn) Write(p []byte) (int, error) {
+	n, err := c.stdin.Write(p)
+	return n, c.wrapErr(err)
+}
+
+// onEOF 在 ssh 进程异常退出时返回包含其 stderr 的错误，例如认证失败或远端没有安装 docker，
+// 否则返回 io.EOF
+func (c *commandConn) onEOF() error {
+	select {
+	case <-c.wait():
+	case <-time.After(sshExitTimeout):
+		return io.EOF
+	}
+	if c.waitErr != nil {
+		return fmt.Errorf("ssh to %s exited with %v: %s", c.remote, c.waitErr, c.stderr)
+	}
+	return io.EOF
+}
+
+func (c *commandConn) wrapErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if stderr := c.stderr.String(); stderr != "" {
+		return fmt.Errorf("ssh to %s: %w: %s", c.remote, err, stderr)
+	}
+	return err
+}
+
+func (c *commandConn) Close() error {
+	c.closeOnce.Do(func() {
+		_ = c.stdin.Close()
+		if c.cmd.Process != nil {
+			_ = c.cmd.Process.Kill()
+		}
+		<-c.wait()
+	})
+	return nil
+}
+
+func (c *commandConn) LocalAddr() net.Addr {
+	return dummyAddr("local")
+}
+
+func (c *commandConn) RemoteAddr() net.Addr {
+	return dummyAddr(c.remote)
+}
+
+// 子进程的管道不支持超时，deadline 相关的方法均为空操作
+func (c *commandConn) SetDeadline(time.Time) error      { return nil }
+func (c *commandConn) SetReadDeadline(time.Time) error  { return nil }
+func (c *commandConn) SetWriteDeadline(time.Time) error { return nil }
+
+type dummyAddr string
+
+func (a dummyAddr) Network() string { return "ssh" }
+
+func (a dummyAddr) String() string { return string(a) }
+
+// tailBuffer 保留最后写入的 tailBufferSize 字节，用于记录子进程的 stderr
+type tailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const tailBufferSize = 4096
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > tailBufferSize {
+		b.buf = b.buf[len(b.buf)-tailBufferSize:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.TrimSpace(string(b.buf))
+}
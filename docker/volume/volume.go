@@ -0,0 +1,58 @@
+package volume
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	volumetypes "github.com/docker/docker/api/types/volume"
+	"github.com/loheagn/loclo/docker"
+)
+
+// Client 在 docker.Client 上提供数据卷相关的操作
+type Client struct {
+	cli *docker.Client
+}
+
+func NewClient(cli *docker.Client) *Client {
+	return &Client{cli: cli}
+}
+
+type CreateOption struct {
+	Name string
+	// Driver 默认为 local
+	Driver string
+	Labels map[string]string
+	Owner  docker.Ownership
+}
+
+// Create 创建数据卷并返回其名称
+func (c *Client) Create(ctx context.Context, opt *CreateOption) (string, error) {
+	vol, err := c.cli.VolumeCreate(ctx, volumetypes.VolumeCreateBody{
+		Name:   opt.Name,
+		Driver: opt.Driver,
+		Labels: opt.Owner.Labels(opt.Labels),
+	})
+	if err != nil {
+		return "", err
+	}
+	return vol.Name, nil
+}
+
+func (c *Client) Remove(ctx context.Context, name string) error {
+	return c.cli.VolumeRemove(ctx, name, true)
+}
+
+// ListByLabels 列出带有全部指定 label 的数据卷
+func (c *Client) ListByLabels(ctx context.Context, labels map[string]string) ([]*types.Volume, error) {
+	args := filters.NewArgs()
+	for k, v := range labels {
+		args.Add("label", fmt.Sprintf("%s=%s", k, v))
+	}
+	resp, err := c.cli.VolumeList(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Volumes, nil
+}
@@ -2,6 +2,8 @@ package kube
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
@@ -13,31 +15,35 @@ import (
 )
 
 type StatefulSetController struct {
-	Client *kubernetes.Clientset
+	Client kubernetes.Interface
 	SCli   v1.StatefulSetInterface
 	S      *appsv1.StatefulSet
 }
 
-func NewStatefulSetController(container *apiv1.Container, client *kubernetes.Clientset, opt *DeployOpt) StatefulSetController {
+func NewStatefulSetController(container *apiv1.Container, client kubernetes.Interface, opt *DeployOpt) StatefulSetController {
 	deployment := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   opt.Name,
-			Labels: opt.Labels,
+			Labels: managedLabels(opt.Labels),
 		},
 		Spec: appsv1.StatefulSetSpec{
-			Replicas: &opt.ReplicaNum,
+			Replicas:             &opt.ReplicaNum,
+			ServiceName:          opt.ServiceName,
+			VolumeClaimTemplates: opt.VolumeClaimTemplates,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: opt.PodLabels,
 			},
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: opt.PodLabels,
+					Labels:      opt.PodLabels,
+					Annotations: opt.PodAnnotations,
 				},
 				Spec: apiv1.PodSpec{
 					Containers: []apiv1.Container{
 						*container,
 					},
 					ImagePullSecrets: opt.ImagePullSecrets,
+					Volumes:          opt.Volumes,
 				},
 			},
 		},
@@ -50,12 +56,8 @@ func NewStatefulSetController(container *apiv1.Container, client *kubernetes.Cli
 }
 
 func (s StatefulSetController) DeployOrUpdate(ctx context.Context) (err error) {
-	// 看看之前部署的deployment还存不存在
-	exists, err := s.Exists(ctx)
-	if err != nil {
-		return
-	}
-	if !exists {
+	old, err := s.SCli.Get(ctx, s.S.Name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
 		// create
 		result, err := s.SCli.Create(ctx, s.S, metav1.CreateOptions{})
 		if err != nil {
@@ -64,6 +66,18 @@ func (s StatefulSetController) DeployOrUpdate(ctx context.Context) (err error) {
 		s.S = result
 		return err
 	}
+	if err != nil {
+		return err
+	}
+	// StatefulSet 创建后 volumeClaimTemplates 不能修改，提前返回明确的错误，而不是由 API Server 拒绝整个更新
+	if !claimTemplatesEqual(old.Spec.VolumeClaimTemplates, s.S.Spec.VolumeClaimTemplates) {
+		return &ErrPodDeploy{Msg: fmt.Sprintf("the persistent volumes of statefulset %s cannot be changed after creation, "+
+			"delete it first (existing persistent volume claims are kept)", s.S.Name)}
+	}
+	// 使用服务端保存的模板，避免默认值的差异被当作修改
+	s.S.Spec.VolumeClaimTemplates = old.Spec.VolumeClaimTemplates
+	// serviceName 同样不能修改，之前创建的 StatefulSet 没有使用 headless Service，保留原值使其仍可以更新
+	s.S.Spec.ServiceName = old.Spec.ServiceName
 	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 		result, err := s.SCli.Update(ctx, s.S, metav1.UpdateOptions{})
 		if err != nil {
@@ -75,6 +89,28 @@ func (s StatefulSetController) DeployOrUpdate(ctx context.Context) (err error) {
 	return retryErr
 }
 
+// claimTemplatesEqual 比较由 PVCSource 决定的字段：名称、访问模式、storage class 与容量
+func claimTemplatesEqual(old, desired []apiv1.PersistentVolumeClaim) bool {
+	if len(old) != len(desired) {
+		return false
+	}
+	for i := range old {
+		o, d := old[i], desired[i]
+		if o.Name != d.Name || !reflect.DeepEqual(o.Spec.AccessModes, d.Spec.AccessModes) {
+			return false
+		}
+		// 未指定 storage class 时由集群填入默认值
+		if d.Spec.StorageClassName != nil && (o.Spec.StorageClassName == nil || *o.Spec.StorageClassName != *d.Spec.StorageClassName) {
+			return false
+		}
+		oldSize, desiredSize := o.Spec.Resources.Requests[apiv1.ResourceStorage], d.Spec.Resources.Requests[apiv1.ResourceStorage]
+		if oldSize.Cmp(desiredSize) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func (s StatefulSetController) GetPods(ctx context.Context) ([]apiv1.Pod, error) {
 	labels, err := metav1.LabelSelectorAsMap(s.S.Spec.Selector)
 	if err != nil {
@@ -101,3 +137,25 @@ func (s StatefulSetController) Exists(ctx context.Context) (bool, error) {
 	}
 	return true, nil
 }
+
+func (s StatefulSetController) RolloutDone(ctx context.Context) (bool, error) {
+	sts, err := s.SCli.Get(ctx, s.S.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if sts.Status.ObservedGeneration == 0 || sts.Generation > sts.Status.ObservedGeneration {
+		return false, nil
+	}
+	replicas := int32(1)
+	if sts.Spec.Replicas != nil {
+		replicas = *sts.Spec.Replicas
+	}
+	if sts.Status.ReadyReplicas < replicas {
+		return false, nil
+	}
+	if strategy := sts.Spec.UpdateStrategy; strategy.Type == appsv1.RollingUpdateStatefulSetStrategyType &&
+		strategy.RollingUpdate != nil && strategy.RollingUpdate.Partition != nil {
+		return sts.Status.UpdatedReplicas >= replicas-*strategy.RollingUpdate.Partition, nil
+	}
+	return sts.Status.UpdateRevision == sts.Status.CurrentRevision, nil
+}
@@ -2,50 +2,130 @@ package kube
 
 import (
 	"context"
+	"io/ioutil"
 	"path/filepath"
+	"strings"
 
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
 )
 
-// Client is a wrapper for *kubernetes.Clientset
+const inClusterNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Client is a wrapper for kubernetes.Interface
 type Client struct {
-	*kubernetes.Clientset
+	kubernetes.Interface
 	Ctx       context.Context
 	namespace string
+	config    *rest.Config
 }
 
+// CreateClientOpt 描述如何连接集群，按以下顺序选择配置来源：
+// KubeConfig、InCluster、ConfigPath，都为空时依次使用 $KUBECONFIG（可包含多个文件）、
+// ~/.kube/config，两者都不存在且运行在集群中时使用 Pod 的 service account
 type CreateClientOpt struct {
 	ConfigPath string
-	Namespace  string
+	// KubeConfig 是 kubeconfig 文件的内容
+	KubeConfig []byte
+	InCluster  bool
+	// Context 为空时使用 kubeconfig 的 current-context，InCluster 时忽略
+	Context string
+	// Namespace 为空时使用 kubeconfig context 或 service account 所在的命名空间，都没有时为 default
+	Namespace string
+
+	// QPS 与 Burst 为 0 时使用 client-go 的默认值
+	QPS       float32
+	Burst     int
+	UserAgent string
+}
+
+func (opt *CreateClientOpt) restConfig() (*rest.Config, string, error) {
+	switch {
+	case len(opt.KubeConfig) > 0:
+		raw, err := clientcmd.Load(opt.KubeConfig)
+		if err != nil {
+			return nil, "", err
+		}
+		return fromClientConfig(clientcmd.NewNonInteractiveClientConfig(*raw, opt.Context, opt.overrides(), nil))
+	case opt.InCluster:
+		return inClusterConfig()
+	case opt.ConfigPath != "":
+		path, _ := filepath.Abs(opt.ConfigPath)
+		rules := &clientcmd.ClientConfigLoadingRules{ExplicitPath: path}
+		return fromClientConfig(clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, opt.overrides()))
+	}
+
+	// 没有任何 kubeconfig 时 client-go 会自动使用集群内配置
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	return fromClientConfig(clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, opt.overrides()))
+}
+
+func (opt *CreateClientOpt) overrides() *clientcmd.ConfigOverrides {
+	return &clientcmd.ConfigOverrides{CurrentContext: opt.Context}
 }
 
-func client(confPath string) (*kubernetes.Clientset, error) {
-	if confPath == "" {
-		confPath = filepath.Join(homedir.HomeDir(), ".kube/config")
-	} else {
-		confPath, _ = filepath.Abs(confPath)
+func fromClientConfig(config clientcmd.ClientConfig) (*rest.Config, string, error) {
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return nil, "", err
 	}
-	config, err := clientcmd.BuildConfigFromFlags("", confPath)
+	namespace, _, err := config.Namespace()
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+	return restConfig, namespace, nil
+}
+
+func inClusterConfig() (*rest.Config, string, error) {
+	config, err := rest.InClusterConfig()
+	if err != nil {
+		return nil, "", err
 	}
-	return kubernetes.NewForConfig(config)
+	namespace := DefaultNameSpace
+	if data, err := ioutil.ReadFile(inClusterNamespaceFile); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		namespace = strings.TrimSpace(string(data))
+	}
+	return config, namespace, nil
 }
 
 func NewClient(ctx context.Context, opt *CreateClientOpt) (*Client, error) {
-	clientSet, err := client(opt.ConfigPath)
+	config, namespace, err := opt.restConfig()
+	if err != nil {
+		return nil, err
+	}
+	if opt.QPS > 0 {
+		config.QPS = opt.QPS
+	}
+	if opt.Burst > 0 {
+		config.Burst = opt.Burst
+	}
+	if opt.UserAgent != "" {
+		config.UserAgent = opt.UserAgent
+	}
+	clientSet, err := kubernetes.NewForConfig(config)
 	if err != nil {
 		return nil, err
 	}
+	if len(opt.Namespace) <= 0 {
+		opt.Namespace = namespace
+	}
 	if len(opt.Namespace) <= 0 {
 		opt.Namespace = DefaultNameSpace
 	}
+	cli := NewClientWithInterface(ctx, clientSet, opt.Namespace)
+	cli.config = config
+	return cli, nil
+}
+
+// NewClientWithInterface 使用已有的 clientset 创建 Client，例如 fake.NewSimpleClientset()
+func NewClientWithInterface(ctx context.Context, clientSet kubernetes.Interface, namespace string) *Client {
+	if len(namespace) <= 0 {
+		namespace = DefaultNameSpace
+	}
 	return &Client{
-		Clientset: clientSet,
+		Interface: clientSet,
 		Ctx:       ctx,
-		namespace: opt.Namespace,
-	}, nil
-
+		namespace: namespace,
+	}
 }
@@ -0,0 +1,93 @@
+package kube
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func kubeconfig(server, context, namespace string) string {
+	return `apiVersion: v1
+kind: Config
+current-context: ` + context + `
+clusters:
+- name: ` + context + `
+  cluster:
+    server: ` + server + `
+contexts:
+- name: ` + context + `
+  context:
+    cluster: ` + context + `
+    user: ` + context + `
+    namespace: ` + namespace + `
+users:
+- name: ` + context + `
+  user:
+    token: t0ken
+`
+}
+
+func TestNewClient(t *testing.T) {
+	dir := t.TempDir()
+	dev := filepath.Join(dir, "dev")
+	prod := filepath.Join(dir, "prod")
+	_ = ioutil.WriteFile(dev, []byte(kubeconfig("https://dev:6443", "dev", "students")), 0600)
+	_ = ioutil.WriteFile(prod, []byte(kubeconfig("https://prod:6443", "prod", "apps")), 0600)
+
+	oldEnv, hadEnv := os.LookupEnv("KUBECONFIG")
+	_ = os.Setenv("KUBECONFIG", dev+string(os.PathListSeparator)+prod)
+	defer func() {
+		if hadEnv {
+			_ = os.Setenv("KUBECONFIG", oldEnv)
+		} else {
+			_ = os.Unsetenv("KUBECONFIG")
+		}
+	}()
+
+	tests := []struct {
+		name          string
+		opt           CreateClientOpt
+		wantHost      string
+		wantNamespace string
+		wantErr       bool
+	}{
+		{name: "env first file context", opt: CreateClientOpt{}, wantHost: "https://dev:6443", wantNamespace: "students"},
+		{name: "env select context", opt: CreateClientOpt{Context: "prod"}, wantHost: "https://prod:6443", wantNamespace: "apps"},
+		{name: "explicit namespace", opt: CreateClientOpt{Context: "prod", Namespace: "other"}, wantHost: "https://prod:6443", wantNamespace: "other"},
+		{name: "path", opt: CreateClientOpt{ConfigPath: prod}, wantHost: "https://prod:6443", wantNamespace: "apps"},
+		{name: "bytes", opt: CreateClientOpt{KubeConfig: []byte(kubeconfig("https://bytes:6443", "b", "ns"))}, wantHost: "https://bytes:6443", wantNamespace: "ns"},
+		{name: "unknown context", opt: CreateClientOpt{Context: "missing"}, wantErr: true},
+		{name: "not in cluster", opt: CreateClientOpt{InCluster: true}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cli, err := NewClient(context.Background(), &tt.opt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewClient() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if cli.config.Host != tt.wantHost || cli.namespace != tt.wantNamespace {
+				t.Errorf("NewClient() host = %s, namespace = %s", cli.config.Host, cli.namespace)
+			}
+		})
+	}
+}
+
+func TestNewClient_rateLimit(t *testing.T) {
+	cli, err := NewClient(context.Background(), &CreateClientOpt{
+		KubeConfig: []byte(kubeconfig("https://dev:6443", "dev", "")),
+		QPS:        50,
+		Burst:      100,
+		UserAgent:  "loclo/test",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli.config.QPS != 50 || cli.config.Burst != 100 || cli.config.UserAgent != "loclo/test" || cli.namespace != DefaultNameSpace {
+		t.Errorf("config = %+v, namespace = %s", cli.config, cli.namespace)
+	}
+}
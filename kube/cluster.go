@@ -0,0 +1,335 @@
+package kube
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/version"
+)
+
+// Cluster 是注册表中的一个具名集群
+type Cluster struct {
+	Name   string
+	Client *Client
+
+	mu     sync.RWMutex
+	health ClusterHealth
+}
+
+type ClusterHealth struct {
+	Healthy   bool
+	Version   string
+	Err       error
+	CheckedAt time.Time
+}
+
+// Health 返回最近一次健康检查的结果，从未检查过时 CheckedAt 为零值
+func (c *Cluster) Health() ClusterHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.health
+}
+
+// HealthCheckTimeout 是单个集群一次健康检查的超时时间，避免无法连接的集群拖慢 Select
+var HealthCheckTimeout = 5 * time.Second
+
+// HealthCacheTTL 是 Select 复用健康检查结果的时长，超过后重新检查
+var HealthCacheTTL = 30 * time.Second
+
+// CheckHealth 通过 /version 与 /readyz 检查集群是否可用，请求受 ctx 与 HealthCheckTimeout 限制
+func (c *Cluster) CheckHealth(ctx context.Context) ClusterHealth {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	health := ClusterHealth{CheckedAt: time.Now()}
+	health.Version, health.Err = c.serverVersion(ctx)
+	if health.Err == nil {
+		_, health.Err = c.Client.Discovery().RESTClient().Get().AbsPath("/readyz").DoRaw(ctx)
+	}
+	health.Healthy = health.Err == nil
+
+	c.mu.Lock()
+	c.health = health
+	c.mu.Unlock()
+	return health
+}
+
+// serverVersion 与 Discovery().ServerVersion() 相同，但请求可以被 ctx 取消
+func (c *Cluster) serverVersion(ctx context.Context) (string, error) {
+	restClient := c.Client.Discovery().RESTClient()
+	if restClient == nil {
+		return "", fmt.Errorf("cluster %s has no rest client", c.Name)
+	}
+	body, err := restClient.Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		return "", err
+	}
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err != nil {
+		return "", fmt.Errorf("unable to parse the server version: %w", err)
+	}
+	return info.GitVersion, nil
+}
+
+// cachedHealth 返回不超过 HealthCacheTTL 的健康检查结果，过期或从未检查过时重新检查
+func (c *Cluster) cachedHealth(ctx context.Context) ClusterHealth {
+	if health := c.Health(); !health.CheckedAt.IsZero() && time.Since(health.CheckedAt) < HealthCacheTTL {
+		return health
+	}
+	return c.CheckHealth(ctx)
+}
+
+// Capacity 描述集群中可调度节点的资源与已被 Pod 申请的资源
+type Capacity struct {
+	AllocatableCPU    resource.Quantity
+	AllocatableMemory resource.Quantity
+	RequestedCPU      resource.Quantity
+	RequestedMemory   resource.Quantity
+}
+
+func (c Capacity) FreeCPU() resource.Quantity {
+	free := c.AllocatableCPU.DeepCopy()
+	free.Sub(c.RequestedCPU)
+	return free
+}
+
+func (c Capacity) FreeMemory() resource.Quantity {
+	free := c.AllocatableMemory.DeepCopy()
+	free.Sub(c.RequestedMemory)
+	return free
+}
+
+// Capacity 统计集群中所有 Ready 且可调度的节点的可分配资源，以及这些节点上未结束的 Pod 申请的资源
+func (c *Cluster) Capacity(ctx context.Context) (*Capacity, error) {
+	nodes, err := c.Client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	pods, err := c.Client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, err
+	}
+	return computeCapacity(nodes.Items, pods.Items), nil
+}
+
+func computeCapacity(nodes []v1.Node, pods []v1.Pod) *Capacity {
+	capacity := &Capacity{}
+	schedulable := make(map[string]bool)
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !nodeReady(node) {
+			continue
+		}
+		schedulable[node.Name] = true
+		capacity.AllocatableCPU.Add(node.Status.Allocatable[v1.ResourceCPU])
+		capacity.AllocatableMemory.Add(node.Status.Allocatable[v1.ResourceMemory])
+	}
+	for _, pod := range pods {
+		if !schedulable[pod.Spec.NodeName] {
+			continue
+		}
+		requests := podRequests(pod)
+		capacity.RequestedCPU.Add(requests[v1.ResourceCPU])
+		capacity.RequestedMemory.Add(requests[v1.ResourceMemory])
+	}
+	return capacity
+}
+
+func nodeReady(node v1.Node) bool {
+	for _, cond := range node.Status.Conditions {
+		if cond.Type == v1.NodeReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podRequests 与调度器的计算方式相同：容器申请之和与任一 init 容器申请中的较大值
+func podRequests(pod v1.Pod) v1.ResourceList {
+	requests := v1.ResourceList{}
+	for _, c := range pod.Spec.Containers {
+		for name, q := range c.Resources.Requests {
+			sum := requests[name]
+			sum.Add(q)
+			requests[name] = sum
+		}
+	}
+	for _, c := range pod.Spec.InitContainers {
+		for name, q := range c.Resources.Requests {
+			if cur, ok := requests[name]; !ok || q.Cmp(cur) > 0 {
+				requests[name] = q.DeepCopy()
+			}
+		}
+	}
+	return requests
+}
+
+// Registry 管理多个具名集群，可以并发使用
+type Registry struct {
+	mu       sync.RWMutex
+	clusters map[string]*Cluster
+}
+
+func NewRegistry() *Registry {
+	return &Registry{clusters: make(map[string]*Cluster)}
+}
+
+// Add 使用 opt 创建 client 并以 name 注册集群，同名集群会被替换
+func (r *Registry) Add(ctx context.Context, name string, opt *CreateClientOpt) (*Cluster, error) {
+	if name == "" {
+		return nil, fmt.Errorf("cluster name is required")
+	}
+	cli, err := NewClient(ctx, opt)
+	if err != nil {
+		return nil, fmt.Errorf("cluster %s: %w", name, err)
+	}
+	return r.AddClient(name, cli), nil
+}
+
+// AddClient 以 name 注册一个已经创建好的 client，同名集群会被替换
+func (r *Registry) AddClient(name string, cli *Client) *Cluster {
+	cluster := &Cluster{Name: name, Client: cli}
+	r.mu.Lock()
+	r.clusters[name] = cluster
+	r.mu.Unlock()
+	return cluster
+}
+
+func (r *Registry) Remove(name string) {
+	r.mu.Lock()
+	delete(r.clusters, name)
+	r.mu.Unlock()
+}
+
+func (r *Registry) Get(name string) (*Cluster, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	cluster, ok := r.clusters[name]
+	if !ok {
+		return nil, fmt.Errorf("cluster %s not found", name)
+	}
+	return cluster, nil
+}
+
+// Clusters 返回按名称排序的所有集群
+func (r *Registry) Clusters() []*Cluster {
+	r.mu.RLock()
+	clusters := make([]*Cluster, 0, len(r.clusters))
+	for _, c := range r.clusters {
+		clusters = append(clusters, c)
+	}
+	r.mu.RUnlock()
+	sort.Slice(clusters, func(i, j int) bool {
+		return clusters[i].Name < clusters[j].Name
+	})
+	return clusters
+}
+
+// eachCluster 并发地对每个集群执行 fn，并等待全部完成
+func eachCluster(clusters []*Cluster, fn func(i int, c *Cluster)) {
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c *Cluster) {
+			defer wg.Done()
+			fn(i, c)
+		}(i, c)
+	}
+	wg.Wait()
+}
+
+// CheckHealth 并发检查所有集群，返回集群名称到检查结果的映射
+func (r *Registry) CheckHealth(ctx context.Context) map[string]ClusterHealth {
+	var mu sync.Mutex
+	result := make(map[string]ClusterHealth)
+	eachCluster(r.Clusters(), func(_ int, c *Cluster) {
+		health := c.CheckHealth(ctx)
+		mu.Lock()
+		result[c.Name] = health
+		mu.Unlock()
+	})
+	return result
+}
+
+// ErrNoCluster 表示没有健康且资源足够的集群
+var ErrNoCluster = fmt.Errorf("no healthy cluster has enough free resources")
+
+// Select 在健康且空闲资源满足 request 的集群中，选择 CPU 与内存空闲比例中较小者最大的集群。
+// 健康状态在 HealthCacheTTL 内复用上一次检查的结果，空闲资源每次都重新统计
+func (r *Registry) Select(ctx context.Context, request Quota) (*Cluster, error) {
+	requests, err := request.convertResourceList()
+	if err != nil {
+		return nil, err
+	}
+	clusters := r.Clusters()
+	scores := make([]float64, len(clusters))
+	for i := range scores {
+		scores[i] = -1
+	}
+	eachCluster(clusters, func(i int, c *Cluster) {
+		if !c.cachedHealth(ctx).Healthy {
+			return
+		}
+		capacity, err := c.Capacity(ctx)
+		if err != nil {
+			return
+		}
+		if score, ok := capacity.score(requests); ok {
+			scores[i] = score
+		}
+	})
+
+	best := -1
+	for i := range clusters {
+		if scores[i] >= 0 && (best < 0 || scores[i] > scores[best]) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return nil, ErrNoCluster
+	}
+	return clusters[best], nil
+}
+
+// score 返回满足 requests 后 CPU 与内存空闲比例中较小的一个，不满足时返回 false
+func (c Capacity) score(requests v1.ResourceList) (float64, bool) {
+	freeCPU, freeMemory := c.FreeCPU(), c.FreeMemory()
+	freeCPU.Sub(requests[v1.ResourceCPU])
+	freeMemory.Sub(requests[v1.ResourceMemory])
+	if freeCPU.Sign() < 0 || freeMemory.Sign() < 0 || c.AllocatableCPU.IsZero() || c.AllocatableMemory.IsZero() {
+		return 0, false
+	}
+	cpu := float64(freeCPU.MilliValue()) / float64(c.AllocatableCPU.MilliValue())
+	memory := float64(freeMemory.Value()) / float64(c.AllocatableMemory.Value())
+	if cpu < memory {
+		return cpu, true
+	}
+	return memory, true
+}
+
+// ClusterPods 是在一个集群中列出 Pod 的结果
+type ClusterPods struct {
+	Cluster string
+	Pods    []v1.Pod
+	Err     error
+}
+
+// ListPods 在所有集群中各自的命名空间下列出带有 labels 的 Pod，
+// 单个集群失败不会影响其他集群的结果
+func (r *Registry) ListPods(ctx context.Context, labels map[string]string) []ClusterPods {
+	clusters := r.Clusters()
+	results := make([]ClusterPods, len(clusters))
+	eachCluster(clusters, func(i int, c *Cluster) {
+		pods, err := listPodsByLabels(ctx, c.Client.Interface, labels, c.Client.namespace)
+		results[i] = ClusterPods{Cluster: c.Name, Pods: pods, Err: err}
+	})
+	return results
+}
@@ -0,0 +1,199 @@
+package kube
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testNode(name, cpu, memory string, ready, unschedulable bool) v1.Node {
+	status := v1.ConditionTrue
+	if !ready {
+		status = v1.ConditionFalse
+	}
+	return v1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       v1.NodeSpec{Unschedulable: unschedulable},
+		Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse(memory),
+			},
+			Conditions: []v1.NodeCondition{{Type: v1.NodeReady, Status: status}},
+		},
+	}
+}
+
+func testPod(node string, init []string, containers ...string) v1.Pod {
+	toContainers := func(cpus []string) []v1.Container {
+		var cs []v1.Container
+		for _, cpu := range cpus {
+			cs = append(cs, v1.Container{Resources: v1.ResourceRequirements{Requests: v1.ResourceList{
+				v1.ResourceCPU:    resource.MustParse(cpu),
+				v1.ResourceMemory: resource.MustParse("1Gi"),
+			}}})
+		}
+		return cs
+	}
+	return v1.Pod{Spec: v1.PodSpec{NodeName: node, InitContainers: toContainers(init), Containers: toContainers(containers)}}
+}
+
+func Test_computeCapacity(t *testing.T) {
+	nodes := []v1.Node{
+		testNode("a", "4", "8Gi", true, false),
+		testNode("b", "4", "8Gi", false, false),
+		testNode("c", "4", "8Gi", true, true),
+		testNode("d", "2", "4Gi", true, false),
+	}
+	pods := []v1.Pod{
+		testPod("a", nil, "500m", "500m"),
+		// init 容器申请的 2 核大于普通容器之和
+		testPod("d", []string{"2"}, "1"),
+		testPod("b", nil, "4"),
+		testPod("", nil, "1"),
+	}
+	capacity := computeCapacity(nodes, pods)
+	check := func(name string, got resource.Quantity, want string) {
+		if got.Cmp(resource.MustParse(want)) != 0 {
+			t.Errorf("%s = %s, want %s", name, got.String(), want)
+		}
+	}
+	check("AllocatableCPU", capacity.AllocatableCPU, "6")
+	check("AllocatableMemory", capacity.AllocatableMemory, "12Gi")
+	check("RequestedCPU", capacity.RequestedCPU, "3")
+	check("RequestedMemory", capacity.RequestedMemory, "3Gi")
+	check("FreeCPU", capacity.FreeCPU(), "3")
+	check("FreeMemory", capacity.FreeMemory(), "9Gi")
+}
+
+func TestCapacity_score(t *testing.T) {
+	capacity := Capacity{
+		AllocatableCPU:    resource.MustParse("4"),
+		AllocatableMemory: resource.MustParse("8Gi"),
+		RequestedCPU:      resource.MustParse("1"),
+		RequestedMemory:   resource.MustParse("2Gi"),
+	}
+	tests := []struct {
+		name    string
+		request Quota
+		want    float64
+		wantOk  bool
+	}{
+		{name: "empty", request: Quota{}, want: 0.75, wantOk: true},
+		{name: "cpu bound", request: Quota{CPU: "2", Memory: "1Gi"}, want: 0.25, wantOk: true},
+		{name: "too much memory", request: Quota{Memory: "7Gi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests, err := tt.request.convertResourceList()
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := capacity.score(requests)
+			if ok != tt.wantOk || (ok && got != tt.want) {
+				t.Errorf("score() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	r.AddClient("staging", &Client{})
+	r.AddClient("production", &Client{})
+	r.AddClient("teaching", &Client{})
+	r.Remove("staging")
+
+	var names []string
+	for _, c := range r.Clusters() {
+		names = append(names, c.Name)
+	}
+	if len(names) != 2 || names[0] != "production" || names[1] != "teaching" {
+		t.Errorf("Clusters() = %v", names)
+	}
+	if _, err := r.Get("staging"); err == nil {
+		t.Errorf("Get() should fail for removed clusters")
+	}
+	if c, err := r.Get("teaching"); err != nil || c.Health().Healthy {
+		t.Errorf("Get() = %+v, %v", c, err)
+	}
+}
+
+// newHealthServer 返回一个模拟 apiserver 的 /version 与 /readyz 的集群，以及 /version 被请求的次数
+func newHealthServer(t *testing.T, delay time.Duration) (*Cluster, *int32) {
+	var versions int32
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+		case <-block:
+			return
+		}
+		switch r.URL.Path {
+		case "/version":
+			atomic.AddInt32(&versions, 1)
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"gitVersion":"v1.22.0"}`))
+		case "/readyz":
+			_, _ = w.Write([]byte("ok"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(func() {
+		close(block)
+		server.Close()
+	})
+
+	r := NewRegistry()
+	cluster, err := r.Add(context.Background(), "test", &CreateClientOpt{KubeConfig: []byte(kubeconfig(server.URL, "test", "default"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cluster, &versions
+}
+
+func TestCluster_CheckHealth(t *testing.T) {
+	cluster, versions := newHealthServer(t, 0)
+	health := cluster.CheckHealth(context.Background())
+	if !health.Healthy || health.Version != "v1.22.0" || health.Err != nil {
+		t.Errorf("CheckHealth() = %+v", health)
+	}
+
+	// 在 HealthCacheTTL 内复用上一次的结果
+	if cached := cluster.cachedHealth(context.Background()); cached != health || atomic.LoadInt32(versions) != 1 {
+		t.Errorf("cachedHealth() = %+v after %d checks, want the cached result", cached, atomic.LoadInt32(versions))
+	}
+	oldTTL := HealthCacheTTL
+	HealthCacheTTL = 0
+	defer func() { HealthCacheTTL = oldTTL }()
+	if cluster.cachedHealth(context.Background()); atomic.LoadInt32(versions) != 2 {
+		t.Errorf("cachedHealth() should check again after the TTL")
+	}
+}
+
+func TestCluster_CheckHealth_timeout(t *testing.T) {
+	cluster, _ := newHealthServer(t, time.Minute)
+	oldTimeout := HealthCheckTimeout
+	HealthCheckTimeout = 50 * time.Millisecond
+	defer func() { HealthCheckTimeout = oldTimeout }()
+
+	start := time.Now()
+	health := cluster.CheckHealth(context.Background())
+	if health.Healthy || health.Err == nil {
+		t.Errorf("CheckHealth() = %+v, want unhealthy", health)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("CheckHealth() took %v, want it bounded by HealthCheckTimeout", elapsed)
+	}
+	if cluster.Health() != health {
+		t.Errorf("Health() = %+v, want the last result", cluster.Health())
+	}
+}
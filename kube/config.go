@@ -0,0 +1,175 @@
+package kube
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// AnnotationConfigHash 是 Pod 模板引用的 ConfigMap 与 Secret 内容的摘要，内容变化时会触发滚动发布
+const AnnotationConfigHash = "loclo.config-hash"
+
+// ConfigRef 引用一个 ConfigMap 或 Secret，两者只能设置一个
+type ConfigRef struct {
+	ConfigMap string
+	Secret    string
+}
+
+// EnvFromSource 将 ConfigMap 或 Secret 中的所有键作为环境变量
+type EnvFromSource struct {
+	ConfigRef
+	// Prefix 会添加到每个环境变量的名称之前
+	Prefix string
+}
+
+// EnvKeyRef 将 ConfigMap 或 Secret 中 Key 的值作为名为 Name 的环境变量
+type EnvKeyRef struct {
+	Name string
+	ConfigRef
+	Key string
+}
+
+func getEnvFrom(sources []EnvFromSource) []v1.EnvFromSource {
+	var envFrom []v1.EnvFromSource
+	for _, source := range sources {
+		env := v1.EnvFromSource{Prefix: source.Prefix}
+		if source.ConfigMap != "" {
+			env.ConfigMapRef = &v1.ConfigMapEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: source.ConfigMap}}
+		} else {
+			env.SecretRef = &v1.SecretEnvSource{LocalObjectReference: v1.LocalObjectReference{Name: source.Secret}}
+		}
+		envFrom = append(envFrom, env)
+	}
+	return envFrom
+}
+
+func getEnvRefs(refs []EnvKeyRef) []v1.EnvVar {
+	var envs []v1.EnvVar
+	for _, ref := range refs {
+		source := &v1.EnvVarSource{}
+		if ref.ConfigMap != "" {
+			source.ConfigMapKeyRef = &v1.ConfigMapKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: ref.ConfigMap},
+				Key:                  ref.Key,
+			}
+		} else {
+			source.SecretKeyRef = &v1.SecretKeySelector{
+				LocalObjectReference: v1.LocalObjectReference{Name: ref.Secret},
+				Key:                  ref.Key,
+			}
+		}
+		envs = append(envs, v1.EnvVar{Name: ref.Name, ValueFrom: source})
+	}
+	return envs
+}
+
+// getKeyToPaths 将键到文件路径的映射按键排序后转换为 KeyToPath
+func getKeyToPaths(items map[string]string) []v1.KeyToPath {
+	var paths []v1.KeyToPath
+	for _, k := range sortedKeys(items) {
+		paths = append(paths, v1.KeyToPath{Key: k, Path: items[k]})
+	}
+	return paths
+}
+
+// configRefs 返回 spec 在环境变量与卷中引用的所有 ConfigMap 与 Secret
+func (spec *PodSpec) configRefs() []ConfigRef {
+	var refs []ConfigRef
+	for _, source := range spec.EnvFrom {
+		refs = append(refs, source.ConfigRef)
+	}
+	for _, ref := range spec.EnvRefs {
+		refs = append(refs, ref.ConfigRef)
+	}
+	for _, volume := range spec.Volumes {
+		if volume.ConfigMap != nil {
+			refs = append(refs, ConfigRef{ConfigMap: volume.ConfigMap.Name})
+		}
+		if volume.Secret != nil {
+			refs = append(refs, ConfigRef{Secret: volume.Secret.Name})
+		}
+	}
+
+	seen := make(map[ConfigRef]bool)
+	var result []ConfigRef
+	for _, ref := range refs {
+		if !seen[ref] {
+			seen[ref] = true
+			result = append(result, ref)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].ConfigMap != result[j].ConfigMap {
+			return result[i].ConfigMap < result[j].ConfigMap
+		}
+		return result[i].Secret < result[j].Secret
+	})
+	return result
+}
+
+// configHash 计算 spec 引用的所有 ConfigMap 与 Secret 内容的摘要，没有引用时返回空字符串。
+// 引用的对象不存在时返回错误，避免 Pod 一直无法启动直到超时
+func (cli *Client) configHash(ctx context.Context, spec *PodSpec) (string, error) {
+	refs := spec.configRefs()
+	if len(refs) <= 0 {
+		return "", nil
+	}
+	h := sha256.New()
+	for _, ref := range refs {
+		if ref.ConfigMap != "" {
+			c, err := cli.GetConfigMap(ctx, ref.ConfigMap)
+			if kerrors.IsNotFound(err) {
+				return "", fmt.Errorf("configmap %s referenced by %s not found", ref.ConfigMap, spec.Name)
+			}
+			if err != nil {
+				return "", err
+			}
+			writeHashEntry(h, "configmap", c.Name)
+			for _, k := range sortedKeys(c.Data) {
+				writeHashEntry(h, k, c.Data[k])
+			}
+			for _, k := range sortedByteKeys(c.BinaryData) {
+				writeHashEntry(h, k, string(c.BinaryData[k]))
+			}
+			continue
+		}
+
+		s, err := cli.CoreV1().Secrets(cli.namespace).Get(ctx, ref.Secret, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return "", fmt.Errorf("secret %s referenced by %s not found", ref.Secret, spec.Name)
+		}
+		if err != nil {
+			return "", err
+		}
+		writeHashEntry(h, "secret", s.Name)
+		for _, k := range sortedByteKeys(s.Data) {
+			writeHashEntry(h, k, string(s.Data[k]))
+		}
+		// StringData 只在写入时使用，这里为了兼容没有转换 StringData 的 API 实现
+		for _, k := range sortedKeys(s.StringData) {
+			writeHashEntry(h, k, s.StringData[k])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// writeHashEntry 写入带长度前缀的键值，避免不同的键值拼接出相同的内容
+func writeHashEntry(w io.Writer, key, value string) {
+	_, _ = fmt.Fprintf(w, "%d:%s%d:%s", len(key), key, len(value), value)
+}
+
+func sortedByteKeys(m map[string][]byte) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package kube
+
+import (
+	"context"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type ConfigMapOpt struct {
+	Name       string
+	Immutable  bool
+	Data       map[string]string
+	BinaryData map[string][]byte
+}
+
+func (opt ConfigMapOpt) convertToConfigMap() *v1.ConfigMap {
+	return &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: opt.Name,
+		},
+		Immutable:  &opt.Immutable,
+		Data:       opt.Data,
+		BinaryData: opt.BinaryData,
+	}
+}
+
+// CreateOrUpdateConfigMap 创建或更新 ConfigMap，已有的 ConfigMap 不可修改时不做任何操作
+func (cli *Client) CreateOrUpdateConfigMap(ctx context.Context, opt *ConfigMapOpt) error {
+	c, err := cli.GetConfigMap(ctx, opt.Name)
+	if err != nil && kerrors.IsNotFound(err) {
+		return cli.CreateConfigMap(ctx, opt)
+	}
+	if err != nil {
+		return err
+	}
+	if c.Immutable != nil && *c.Immutable {
+		return nil
+	}
+	return cli.UpdateConfigMap(ctx, opt)
+}
+
+func (cli *Client) CreateConfigMap(ctx context.Context, opt *ConfigMapOpt) (err error) {
+	_, err = cli.CoreV1().ConfigMaps(cli.namespace).Create(ctx, opt.convertToConfigMap(), metav1.CreateOptions{})
+	return
+}
+
+func (cli *Client) UpdateConfigMap(ctx context.Context, opt *ConfigMapOpt) (err error) {
+	_, err = cli.CoreV1().ConfigMaps(cli.namespace).Update(ctx, opt.convertToConfigMap(), metav1.UpdateOptions{})
+	return
+}
+
+func (cli *Client) GetConfigMap(ctx context.Context, name string) (*v1.ConfigMap, error) {
+	return cli.CoreV1().ConfigMaps(cli.namespace).Get(ctx, name, metav1.GetOptions{})
+}
+
+// DeleteConfigMap 删除 ConfigMap，ConfigMap 不存在时不返回错误
+func (cli *Client) DeleteConfigMap(ctx context.Context, name string) error {
+	err := cli.CoreV1().ConfigMaps(cli.namespace).Delete(ctx, name, metav1.DeleteOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
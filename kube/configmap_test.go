@@ -0,0 +1,135 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestClient_ConfigMap(t *testing.T) {
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "apps")
+	opt := &ConfigMapOpt{Name: "app", Data: map[string]string{"mode": "dev"}}
+	if err := cli.CreateOrUpdateConfigMap(ctx, opt); err != nil {
+		t.Fatalf("CreateOrUpdateConfigMap() error = %v", err)
+	}
+	opt.Data["mode"] = "prod"
+	if err := cli.CreateOrUpdateConfigMap(ctx, opt); err != nil {
+		t.Fatalf("CreateOrUpdateConfigMap() error = %v", err)
+	}
+	c, err := cli.GetConfigMap(ctx, "app")
+	if err != nil || c.Data["mode"] != "prod" {
+		t.Fatalf("GetConfigMap() = %+v, %v", c, err)
+	}
+
+	// 不可修改的 ConfigMap 保持不变
+	immutable := &ConfigMapOpt{Name: "frozen", Immutable: true, Data: map[string]string{"mode": "dev"}}
+	if err := cli.CreateConfigMap(ctx, immutable); err != nil {
+		t.Fatal(err)
+	}
+	immutable.Data = map[string]string{"mode": "prod"}
+	if err := cli.CreateOrUpdateConfigMap(ctx, immutable); err != nil {
+		t.Fatalf("CreateOrUpdateConfigMap() error = %v", err)
+	}
+	if c, _ := cli.GetConfigMap(ctx, "frozen"); c.Data["mode"] != "dev" {
+		t.Errorf("immutable configmap updated: %+v", c)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := cli.DeleteConfigMap(ctx, "app"); err != nil {
+			t.Fatalf("DeleteConfigMap() error = %v", err)
+		}
+	}
+	if _, err := cli.GetConfigMap(ctx, "app"); !kerrors.IsNotFound(err) {
+		t.Errorf("GetConfigMap() error = %v, want not found", err)
+	}
+}
+
+func TestPodDeploy_config(t *testing.T) {
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "apps", &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-secret", Namespace: "apps"},
+		Data:       map[string][]byte{"token": []byte("s3cr3t")},
+	})
+	if err := cli.CreateConfigMap(ctx, &ConfigMapOpt{Name: "app-config", Data: map[string]string{"mode": "dev", "app.yaml": "debug: true"}}); err != nil {
+		t.Fatal(err)
+	}
+	opt := &PodDeployOpt{
+		Labels:     map[string]string{"app": "web"},
+		ReplicaNum: 1,
+		Spec: PodSpec{
+			Name:     "web",
+			ImageTag: "nginx:1.17",
+			Envs:     map[string]string{"B": "2", "A": "1"},
+			EnvFrom:  []EnvFromSource{{ConfigRef: ConfigRef{ConfigMap: "app-config"}, Prefix: "APP_"}},
+			EnvRefs:  []EnvKeyRef{{Name: "TOKEN", ConfigRef: ConfigRef{Secret: "app-secret"}, Key: "token"}},
+			Volumes: []Volume{
+				{Name: "config", MountPath: "/etc/app", ReadOnly: true, ConfigMap: &ConfigMapSource{Name: "app-config", Items: map[string]string{"app.yaml": "conf/app.yaml"}}},
+				{Name: "secret", MountPath: "/run/secrets/app", ReadOnly: true, Secret: &SecretSource{Name: "app-secret"}},
+			},
+		},
+	}
+	getTemplate := func() v1.PodTemplateSpec {
+		deployment, err := cli.AppsV1().Deployments("apps").Get(ctx, "web", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return deployment.Spec.Template
+	}
+
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	template := getTemplate()
+	container := template.Spec.Containers[0]
+	if len(container.Env) != 3 || container.Env[0].Name != "A" || container.Env[1].Name != "B" ||
+		container.Env[2].ValueFrom.SecretKeyRef.Name != "app-secret" || container.Env[2].ValueFrom.SecretKeyRef.Key != "token" {
+		t.Errorf("env = %+v", container.Env)
+	}
+	if len(container.EnvFrom) != 1 || container.EnvFrom[0].ConfigMapRef.Name != "app-config" || container.EnvFrom[0].Prefix != "APP_" {
+		t.Errorf("envFrom = %+v", container.EnvFrom)
+	}
+	if items := template.Spec.Volumes[0].ConfigMap.Items; len(items) != 1 || items[0].Path != "conf/app.yaml" {
+		t.Errorf("items = %+v", items)
+	}
+	hash := template.Annotations[AnnotationConfigHash]
+	if hash == "" {
+		t.Fatalf("config hash annotation not set: %+v", template.Annotations)
+	}
+
+	// 配置不变时 hash 不变，ConfigMap 或 Secret 变化时 hash 变化
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if got := getTemplate().Annotations[AnnotationConfigHash]; got != hash {
+		t.Errorf("hash changed without config change: %s != %s", got, hash)
+	}
+	if err := cli.UpdateConfigMap(ctx, &ConfigMapOpt{Name: "app-config", Data: map[string]string{"mode": "prod", "app.yaml": "debug: true"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	configChanged := getTemplate().Annotations[AnnotationConfigHash]
+	if configChanged == hash {
+		t.Errorf("hash not changed after configmap update")
+	}
+	if err := cli.UpdateSecret(ctx, &SecretOpt{Name: "app-secret", Data: map[string][]byte{"token": []byte("rotated")}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if got := getTemplate().Annotations[AnnotationConfigHash]; got == configChanged {
+		t.Errorf("hash not changed after secret update")
+	}
+
+	// 引用不存在的配置时部署失败
+	opt.Spec.EnvRefs[0].Secret = "missing"
+	if _, err := cli.PodDeploy(ctx, opt); err == nil {
+		t.Errorf("PodDeploy() should fail when a referenced secret is missing")
+	}
+}
@@ -2,6 +2,7 @@ package kube
 
 import (
 	"context"
+	"fmt"
 
 	appsv1 "k8s.io/api/apps/v1"
 	apiv1 "k8s.io/api/core/v1"
@@ -13,16 +14,16 @@ import (
 )
 
 type DeploymentController struct {
-	Client *kubernetes.Clientset
+	Client kubernetes.Interface
 	DCli   v1.DeploymentInterface
 	D      *appsv1.Deployment
 }
 
-func NewDeploymentController(container *apiv1.Container, client *kubernetes.Clientset, opt *DeployOpt) DeploymentController {
+func NewDeploymentController(container *apiv1.Container, client kubernetes.Interface, opt *DeployOpt) DeploymentController {
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:   opt.Name,
-			Labels: opt.Labels,
+			Labels: managedLabels(opt.Labels),
 		},
 		Spec: appsv1.DeploymentSpec{
 			Replicas: &opt.ReplicaNum,
@@ -31,17 +32,22 @@ func NewDeploymentController(container *apiv1.Container, client *kubernetes.Clie
 			},
 			Template: apiv1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: opt.PodLabels,
+					Labels:      opt.PodLabels,
+					Annotations: opt.PodAnnotations,
 				},
 				Spec: apiv1.PodSpec{
 					Containers: []apiv1.Container{
 						*container,
 					},
 					ImagePullSecrets: opt.ImagePullSecrets,
+					Volumes:          opt.Volumes,
 				},
 			},
 		},
 	}
+	if opt.Recreate {
+		deployment.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+	}
 	return DeploymentController{
 		Client: client,
 		D:      deployment,
@@ -101,3 +107,26 @@ func (d DeploymentController) Exists(ctx context.Context) (bool, error) {
 	}
 	return true, nil
 }
+
+func (d DeploymentController) RolloutDone(ctx context.Context) (bool, error) {
+	deployment, err := d.DCli.Get(ctx, d.D.Name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if deployment.Generation > deployment.Status.ObservedGeneration {
+		return false, nil
+	}
+	for _, cond := range deployment.Status.Conditions {
+		if cond.Type == appsv1.DeploymentProgressing && cond.Reason == "ProgressDeadlineExceeded" {
+			return false, &ErrPodDeploy{Msg: fmt.Sprintf("deployment %s exceeded its progress deadline", deployment.Name)}
+		}
+	}
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	status := deployment.Status
+	return status.UpdatedReplicas >= replicas &&
+		status.Replicas <= status.UpdatedReplicas &&
+		status.AvailableReplicas >= status.UpdatedReplicas, nil
+}
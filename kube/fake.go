@@ -0,0 +1,138 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// NewFakeClient 返回由 fake clientset 支持的 Client，用于在没有集群的情况下测试。
+// fake clientset 中没有控制器，因此 Deployment 与 StatefulSet 在创建或更新时会被模拟为
+// 立即完成滚动发布，并生成对应数量的 Ready Pod
+func NewFakeClient(ctx context.Context, namespace string, objects ...runtime.Object) *Client {
+	clientSet := fake.NewSimpleClientset(objects...)
+	for _, verb := range []string{"create", "update"} {
+		clientSet.PrependReactor(verb, "deployments", fakeRollout(clientSet.Tracker()))
+		clientSet.PrependReactor(verb, "statefulsets", fakeRollout(clientSet.Tracker()))
+	}
+	return NewClientWithInterface(ctx, clientSet, namespace)
+}
+
+// fakeRollout 在对象被保存前设置其 generation 与已完成的 status，并替换对应的 Pod。
+// 返回 false 使后续的默认 reactor 继续保存对象，因此这里的错误会被忽略，由默认 reactor 报告
+func fakeRollout(tracker k8stesting.ObjectTracker) k8stesting.ReactionFunc {
+	return func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj := action.(interface{ GetObject() runtime.Object }).GetObject()
+		ns := action.GetNamespace()
+
+		var generation int64 = 1
+		if action.GetVerb() == "update" {
+			meta, err := metaAccessor(obj)
+			if err != nil {
+				return false, nil, nil
+			}
+			old, err := tracker.Get(action.GetResource(), ns, meta.GetName())
+			if err != nil {
+				return false, nil, nil
+			}
+			if oldMeta, err := metaAccessor(old); err == nil {
+				generation = oldMeta.GetGeneration() + 1
+			}
+		}
+
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			replicas := replicasOf(o.Spec.Replicas)
+			o.Generation = generation
+			o.Status = appsv1.DeploymentStatus{
+				ObservedGeneration: generation,
+				Replicas:           replicas,
+				UpdatedReplicas:    replicas,
+				ReadyReplicas:      replicas,
+				AvailableReplicas:  replicas,
+			}
+			_ = fakePods(tracker, ns, fmt.Sprintf("%s-%d", o.Name, generation), replicas, o.Spec.Template)
+		case *appsv1.StatefulSet:
+			replicas := replicasOf(o.Spec.Replicas)
+			revision := fmt.Sprintf("%s-%d", o.Name, generation)
+			o.Generation = generation
+			o.Status = appsv1.StatefulSetStatus{
+				ObservedGeneration: generation,
+				Replicas:           replicas,
+				ReadyReplicas:      replicas,
+				CurrentReplicas:    replicas,
+				UpdatedReplicas:    replicas,
+				CurrentRevision:    revision,
+				UpdateRevision:     revision,
+			}
+			_ = fakePods(tracker, ns, o.Name, replicas, o.Spec.Template)
+		}
+		return false, nil, nil
+	}
+}
+
+func metaAccessor(obj runtime.Object) (metav1.Object, error) {
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return nil, fmt.Errorf("%T has no object meta", obj)
+	}
+	return meta, nil
+}
+
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
+
+// fakePods 删除带有模板 label 的旧 Pod，并按模板创建 replicas 个 Ready 的 Pod
+func fakePods(tracker k8stesting.ObjectTracker, ns, prefix string, replicas int32, template v1.PodTemplateSpec) error {
+	gvr := v1.SchemeGroupVersion.WithResource("pods")
+	gvk := v1.SchemeGroupVersion.WithKind("Pod")
+	list, err := tracker.List(gvr, gvk, ns)
+	if err != nil {
+		return err
+	}
+	selector := labels.SelectorFromSet(template.Labels)
+	for _, pod := range list.(*v1.PodList).Items {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			if err := tracker.Delete(gvr, ns, pod.Name); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i := int32(0); i < replicas; i++ {
+		pod := &v1.Pod{
+			ObjectMeta: *template.ObjectMeta.DeepCopy(),
+			Spec:       *template.Spec.DeepCopy(),
+			Status: v1.PodStatus{
+				Phase:      v1.PodRunning,
+				Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}},
+			},
+		}
+		pod.Name = fmt.Sprintf("%s-%d", prefix, i)
+		pod.Namespace = ns
+		for _, c := range pod.Spec.Containers {
+			pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{
+				Name:    c.Name,
+				Image:   c.Image,
+				Ready:   true,
+				Started: func(b bool) *bool { return &b }(true),
+				State:   v1.ContainerState{Running: &v1.ContainerStateRunning{}},
+			})
+		}
+		if err := tracker.Create(gvr, pod, ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
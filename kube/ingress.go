@@ -0,0 +1,143 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// IngressOpt 描述 PodDeploy 创建的 Ingress，Ingress 与 Spec.Name 同名，
+// 将 Host 下的 Paths 转发到同名 Service 的 Port 端口
+type IngressOpt struct {
+	// Host 为空时使用 <Spec.Name>.<namespace>.<Domain>
+	Host   string
+	Domain string
+	// Paths 是以 / 开头的路径前缀，为空时为 /
+	Paths []string
+	// Port 是 Spec.Ports 中的端口名称，未命名的端口为 port-<下标>
+	Port string
+	// ClassName 为空时使用集群默认的 ingress class
+	ClassName string
+	// TLSSecret 是保存证书的 Secret 名称，不为空时通过 https 访问
+	TLSSecret   string
+	Annotations map[string]string
+}
+
+func (opt *IngressOpt) host(name, namespace string) string {
+	if opt.Host != "" {
+		return opt.Host
+	}
+	return fmt.Sprintf("%s.%s.%s", name, namespace, opt.Domain)
+}
+
+func (opt *IngressOpt) paths() []string {
+	if len(opt.Paths) <= 0 {
+		return []string{"/"}
+	}
+	return opt.Paths
+}
+
+// url 返回 Ingress 的访问地址，opt 为 nil 时返回空字符串
+func (opt *IngressOpt) url(name, namespace string) string {
+	if opt == nil {
+		return ""
+	}
+	scheme := "http"
+	if opt.TLSSecret != "" {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s%s", scheme, opt.host(name, namespace), opt.paths()[0])
+}
+
+func (opt *IngressOpt) convertToIngress(name, namespace string, labels map[string]string) *networkingv1.Ingress {
+	host := opt.host(name, namespace)
+	pathType := networkingv1.PathTypePrefix
+	var paths []networkingv1.HTTPIngressPath
+	for _, path := range opt.paths() {
+		paths = append(paths, networkingv1.HTTPIngressPath{
+			Path:     path,
+			PathType: &pathType,
+			Backend: networkingv1.IngressBackend{
+				Service: &networkingv1.IngressServiceBackend{
+					Name: name,
+					Port: networkingv1.ServiceBackendPort{Name: opt.Port},
+				},
+			},
+		})
+	}
+
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   namespace,
+			Labels:      managedLabels(labels),
+			Annotations: opt.Annotations,
+		},
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{{
+				Host: host,
+				IngressRuleValue: networkingv1.IngressRuleValue{
+					HTTP: &networkingv1.HTTPIngressRuleValue{Paths: paths},
+				},
+			}},
+		},
+	}
+	if opt.ClassName != "" {
+		className := opt.ClassName
+		ingress.Spec.IngressClassName = &className
+	}
+	if opt.TLSSecret != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{{Hosts: []string{host}, SecretName: opt.TLSSecret}}
+	}
+	return ingress
+}
+
+// syncIngress 按 opt.Ingress 创建或更新 Ingress，opt.Ingress 为 nil 时删除之前创建的 Ingress
+func (cli *Client) syncIngress(ctx context.Context, opt *PodDeployOpt) error {
+	if opt.Ingress == nil {
+		return cli.deleteIngress(ctx, opt.Spec.Name)
+	}
+	ingress := opt.Ingress.convertToIngress(opt.Spec.Name, cli.namespace, opt.Labels)
+	ingresses := cli.NetworkingV1().Ingresses(cli.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		old, err := ingresses.Get(ctx, ingress.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			_, err = ingresses.Create(ctx, ingress, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !isManaged(old) {
+			return fmt.Errorf("ingress %s already exists and is not managed by %s", ingress.Name, managedBy)
+		}
+		update := ingress.DeepCopy()
+		update.ResourceVersion = old.ResourceVersion
+		_, err = ingresses.Update(ctx, update, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// deleteIngress 删除由 loclo 创建的 Ingress，Ingress 不存在时不返回错误
+func (cli *Client) deleteIngress(ctx context.Context, name string) error {
+	ingresses := cli.NetworkingV1().Ingresses(cli.namespace)
+	ingress, err := ingresses.Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isManaged(ingress) {
+		return nil
+	}
+	err = ingresses.Delete(ctx, name, metav1.DeleteOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
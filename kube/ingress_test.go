@@ -0,0 +1,113 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestIngressOpt_url(t *testing.T) {
+	tests := []struct {
+		name string
+		opt  *IngressOpt
+		want string
+	}{
+		{name: "nil"},
+		{name: "domain", opt: &IngressOpt{Domain: "apps.example.com"}, want: "http://web.student.apps.example.com/"},
+		{name: "host", opt: &IngressOpt{Host: "web.example.com", Paths: []string{"/app"}, TLSSecret: "tls"}, want: "https://web.example.com/app"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opt.url("web", "student"); got != tt.want {
+				t.Errorf("url() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClient_syncIngress(t *testing.T) {
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "student")
+	opt := &PodDeployOpt{
+		Labels:     map[string]string{"app": "web"},
+		ReplicaNum: 1,
+		Spec: PodSpec{
+			Name:     "web",
+			ImageTag: "nginx:1.17",
+			Ports:    []Port{{Name: "http", Port: 80}},
+		},
+		Ingress: &IngressOpt{
+			Domain:      "apps.example.com",
+			Port:        "http",
+			ClassName:   "nginx",
+			Annotations: map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "8m"},
+		},
+	}
+	result, err := cli.PodDeploy(ctx, opt)
+	if err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if result.URL != "http://web.student.apps.example.com/" {
+		t.Errorf("URL = %v", result.URL)
+	}
+
+	ingresses := cli.NetworkingV1().Ingresses("student")
+	ingress, err := ingresses.Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rule := ingress.Spec.Rules[0]
+	backend := rule.HTTP.Paths[0].Backend.Service
+	if rule.Host != "web.student.apps.example.com" || backend.Name != "web" || backend.Port.Name != "http" ||
+		*ingress.Spec.IngressClassName != "nginx" || ingress.Annotations["nginx.ingress.kubernetes.io/proxy-body-size"] != "8m" {
+		t.Errorf("ingress = %+v", ingress)
+	}
+
+	// 更新 host 与 TLS
+	opt.Ingress = &IngressOpt{Host: "web.example.com", Port: "http", Paths: []string{"/", "/api"}, TLSSecret: "web-tls"}
+	if result, err = cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if result.URL != "https://web.example.com/" {
+		t.Errorf("URL = %v", result.URL)
+	}
+	if ingress, err = ingresses.Get(ctx, "web", metav1.GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if len(ingress.Spec.Rules[0].HTTP.Paths) != 2 || ingress.Spec.TLS[0].SecretName != "web-tls" || ingress.Spec.IngressClassName != nil {
+		t.Errorf("ingress = %+v", ingress)
+	}
+
+	// 不再需要 Ingress 时删除
+	opt.Ingress = nil
+	if result, err = cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if result.URL != "" {
+		t.Errorf("URL = %v", result.URL)
+	}
+	if _, err = ingresses.Get(ctx, "web", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("ingress not deleted: %v", err)
+	}
+
+	// PodDelete 删除 Ingress，但不删除不是由 loclo 创建的 Ingress
+	opt.Ingress = &IngressOpt{Domain: "apps.example.com", Port: "http"}
+	if _, err = cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if err = cli.PodDelete(ctx, "web"); err != nil {
+		t.Fatalf("PodDelete() error = %v", err)
+	}
+	if _, err = ingresses.Get(ctx, "web", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("ingress not deleted: %v", err)
+	}
+	if _, err = ingresses.Create(ctx, &networkingv1.Ingress{ObjectMeta: metav1.ObjectMeta{Name: "web"}}, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = cli.PodDeploy(ctx, opt); err == nil {
+		t.Errorf("PodDeploy() should not overwrite a foreign ingress")
+	}
+}
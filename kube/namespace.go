@@ -6,21 +6,26 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
-// EnsureNS 确保命名空间存在，不存在则创建 幂等
+// EnsureNS 确保命名空间存在，不存在则创建并为其设置 quota，幂等。
+// 命名空间已存在时不修改其 quota；查询命名空间失败（NotFound 以外的错误，例如没有权限）时直接返回该错误而不尝试创建；
+// 创建时命名空间已被并发创建（AlreadyExists）视为成功且不设置 quota，其他创建错误原样返回
 func (cli *Client) EnsureNS(quota Quota) error {
 	namespace := &apiv1.Namespace{
 		ObjectMeta: metav1.ObjectMeta{Name: cli.namespace},
 	}
 	_, err := cli.CoreV1().Namespaces().Get(cli.Ctx, cli.namespace, metav1.GetOptions{})
-	if err == nil && !kerrors.IsNotFound(err) {
+	if err == nil || !kerrors.IsNotFound(err) {
 		return err
 	}
 
 	// 没有找到就create
 	_, err = cli.CoreV1().Namespaces().Create(cli.Ctx, namespace, metav1.CreateOptions{})
-	if err != nil {
+	if kerrors.IsAlreadyExists(err) {
 		return nil
 	}
+	if err != nil {
+		return err
+	}
 	return cli.createQuotaForNS(quota)
 }
 
@@ -2,26 +2,30 @@ package kube
 
 import (
 	"context"
+	"errors"
 	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
 )
 
 func TestClient_EnsureNS(t *testing.T) {
-	// 创建 Client
-	namespace := "test-ns"
-	cli, err := NewClient(context.Background(), &CreateClientOpt{
-		ConfigPath: "",
-		Namespace:  namespace,
-	})
-	if err != nil {
-		panic(err.Error())
-	}
 	type args struct {
 		quota Quota
 	}
 	tests := []struct {
-		name    string
-		args    args
-		wantErr bool
+		name      string
+		existing  bool
+		getErr    error
+		createErr error
+		args      args
+		wantQuota bool
+		wantErr   bool
 	}{
 		{
 			name: "test-namespace",
@@ -29,16 +33,84 @@ func TestClient_EnsureNS(t *testing.T) {
 				CPU:    "3",
 				Memory: "6g",
 			}},
+			wantQuota: true,
+		},
+		{
+			name:     "existing-namespace",
+			existing: true,
+			args:     args{quota: Quota{CPU: "3"}},
+		},
+		{
+			name: "no-quota",
+			args: args{quota: Quota{}},
+		},
+		{
+			name:    "get-forbidden",
+			getErr:  kerrors.NewForbidden(v1.Resource("namespaces"), "test-ns", errors.New("no permission")),
+			args:    args{quota: Quota{CPU: "3"}},
+			wantErr: true,
+		},
+		{
+			name:      "created-concurrently",
+			createErr: kerrors.NewAlreadyExists(v1.Resource("namespaces"), "test-ns"),
+			args:      args{quota: Quota{CPU: "3"}},
+		},
+		{
+			name:      "create-failed",
+			createErr: kerrors.NewInternalError(errors.New("etcd unavailable")),
+			args:      args{quota: Quota{CPU: "3"}},
+			wantErr:   true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			// 创建 Client
+			namespace := "test-ns"
+			cli := NewFakeClient(context.Background(), namespace)
+			if tt.existing {
+				_, _ = cli.CoreV1().Namespaces().Create(context.Background(), &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, metav1.CreateOptions{})
+			}
 			defer func() {
 				_ = cli.DeleteNS()
 			}()
+			creates := 0
+			clientSet := cli.Interface.(*fake.Clientset)
+			clientSet.PrependReactor("get", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+				return tt.getErr != nil, nil, tt.getErr
+			})
+			clientSet.PrependReactor("create", "namespaces", func(k8stesting.Action) (bool, runtime.Object, error) {
+				creates++
+				return tt.createErr != nil, nil, tt.createErr
+			})
+
 			if err := cli.EnsureNS(tt.args.quota); (err != nil) != tt.wantErr {
 				t.Errorf("EnsureNS() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			if tt.getErr != nil {
+				// 查询失败时不尝试创建
+				if creates != 0 {
+					t.Errorf("EnsureNS() created the namespace after get failed")
+				}
+				return
+			}
+			if tt.createErr != nil {
+				if _, err := cli.CoreV1().ResourceQuotas(namespace).Get(context.Background(), namespace, metav1.GetOptions{}); err == nil {
+					t.Errorf("EnsureNS() should not create a quota when create failed")
+				}
+				return
+			}
+			if _, err := cli.CoreV1().Namespaces().Get(context.Background(), namespace, metav1.GetOptions{}); err != nil {
+				t.Errorf("namespace not created: %v", err)
+			}
+			quota, err := cli.CoreV1().ResourceQuotas(namespace).Get(context.Background(), namespace, metav1.GetOptions{})
+			if (err == nil) != tt.wantQuota {
+				t.Fatalf("get quota error = %v, wantQuota %v", err, tt.wantQuota)
+			}
+			if tt.wantQuota {
+				if memory := quota.Spec.Hard[v1.ResourceMemory]; memory.Cmp(resource.MustParse("6G")) != 0 {
+					t.Errorf("quota memory = %s", memory.String())
+				}
+			}
 		})
 	}
 }
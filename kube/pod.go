@@ -11,6 +11,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
 	watch2 "k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/cache"
@@ -18,25 +19,49 @@ import (
 )
 
 type PodDeployOpt struct {
-	Labels               map[string]string
-	extraLabels          map[string]string
+	// Labels 同时作为工作负载的 label 与 selector，不能为空
+	Labels map[string]string
+	// ExtraLabels 只添加到 Pod 上，不能覆盖 Labels 中的值
+	ExtraLabels          map[string]string
 	ReplicaNum           int32
 	Stateful             bool
 	Duration             time.Duration
 	DockerRegistrySecret string
-	spec                 PodSpec
+	Spec                 PodSpec
+	Service              ServiceOpt
+	// Ingress 为 nil 时不创建 Ingress，并删除之前创建的 Ingress
+	Ingress *IngressOpt
+}
+
+// PodDeployResult 是部署完成后返回给调用者的信息
+type PodDeployResult struct {
+	// URL 是通过 Ingress 访问工作负载的地址，没有 Ingress 时为空
+	URL string
+	// QOSClass 是 Pod 的 QoS 类型
+	QOSClass v1.PodQOSClass
 }
 
 type PodSpec struct {
 	Name       string
 	ImageTag   string
 	Envs       map[string]string
+	EnvFrom    []EnvFromSource
+	EnvRefs    []EnvKeyRef
 	Ports      []Port
 	WorkDir    string
 	Cmd        Cmd
 	labels     map[string]string
 	PullPolicy PullPolicy
+	Volumes    []Volume
+	// ReadinessProbe 决定 Pod 何时可以接收流量，PodDeploy 等待所有 Pod 就绪后才返回
+	ReadinessProbe *Probe
+	LivenessProbe  *Probe
+	StartupProbe   *Probe
+	// Quota 在 Requests 与 Limits 都为 nil 时同时作为容器的 requests 与 limits
 	Quota
+	// Requests 与 Limits 分别设置容器的 requests 与 limits，只设置 Limits 时 requests 与 limits 相同
+	Requests *Quota
+	Limits   *Quota
 }
 
 func (opt *PodDeployOpt) fix() {
@@ -49,13 +74,13 @@ func (opt *PodDeployOpt) fix() {
 	for k, v := range opt.Labels {
 		podLabels[k] = v
 	}
-	for k, v := range opt.extraLabels {
+	for k, v := range opt.ExtraLabels {
 		podLabels[k] = v
 	}
-	opt.spec.labels = podLabels
+	opt.Spec.labels = podLabels
 
-	if len(opt.spec.PullPolicy) <= 0 {
-		opt.spec.PullPolicy = PullIfNotPresent
+	if len(opt.Spec.PullPolicy) <= 0 {
+		opt.Spec.PullPolicy = PullIfNotPresent
 	}
 }
 
@@ -69,17 +94,24 @@ func (err *ErrPodDeploy) Error() string {
 
 var ErrPodDeployTimeout = &ErrPodDeploy{Msg: "timeout"}
 
-func (cli *Client) PodDeploy(ctx context.Context, opt *PodDeployOpt) (err error) {
+func (cli *Client) PodDeploy(ctx context.Context, opt *PodDeployOpt) (result *PodDeployResult, err error) {
+	if err = opt.Validate(); err != nil {
+		return
+	}
 	opt.fix()
 	ctx, cancel := context.WithTimeout(ctx, opt.Duration)
 	defer cancel()
 
-	container, err := getContainer(opt.spec)
+	container, err := getContainer(opt.Spec)
+	if err != nil {
+		return
+	}
+	volumes, claimTemplates, err := opt.podVolumes()
 	if err != nil {
 		return
 	}
 
-	errCh := make(chan error)
+	errCh := make(chan error, 1)
 
 	go func() {
 		var err error
@@ -88,21 +120,50 @@ func (cli *Client) PodDeploy(ctx context.Context, opt *PodDeployOpt) (err error)
 		}()
 
 		deployOpt := &DeployOpt{
-			Name:       opt.spec.Name,
+			Name:       opt.Spec.Name,
 			Labels:     opt.Labels,
 			ReplicaNum: opt.ReplicaNum,
 			Namespace:  cli.namespace,
-			PodLabels:  opt.spec.labels,
+			PodLabels:  opt.Spec.labels,
+			Volumes:    volumes,
+			Recreate:   opt.sharesReadWriteOnce(),
 		}
 		if len(opt.DockerRegistrySecret) > 0 {
 			deployOpt.ImagePullSecrets = append(deployOpt.ImagePullSecrets, v1.LocalObjectReference{Name: opt.DockerRegistrySecret})
 		}
+		if opt.Stateful {
+			deployOpt.ServiceName = headlessServiceName(opt.Spec.Name)
+			deployOpt.VolumeClaimTemplates = claimTemplates
+		}
+
+		// 引用的配置变化时修改 Pod 模板，从而触发滚动发布
+		configHash, err := cli.configHash(ctx, &opt.Spec)
+		if err != nil {
+			return
+		}
+		if configHash != "" {
+			deployOpt.PodAnnotations = map[string]string{AnnotationConfigHash: configHash}
+		}
+
+		// StatefulSet 的 Pod DNS 依赖 headless Service，因此先于工作负载同步
+		err = cli.syncServices(ctx, opt)
+		if err != nil {
+			return
+		}
+		err = cli.syncIngress(ctx, opt)
+		if err != nil {
+			return
+		}
+		err = cli.ensurePVCs(ctx, opt)
+		if err != nil {
+			return
+		}
 
 		var controller PodController
 		if opt.Stateful {
-			controller = NewStatefulSetController(container, cli.Clientset, deployOpt)
+			controller = NewStatefulSetController(container, cli.Interface, deployOpt)
 		} else {
-			controller = NewDeploymentController(container, cli.Clientset, deployOpt)
+			controller = NewDeploymentController(container, cli.Interface, deployOpt)
 		}
 
 		// deploy
@@ -111,7 +172,7 @@ func (cli *Client) PodDeploy(ctx context.Context, opt *PodDeployOpt) (err error)
 			return
 		}
 		// wait pod for done
-		//errMsg, err = waitPodsForRunning(ctx, clientSet, opt.Namespace, opt.spec.labels)
+		err = waitRollout(ctx, controller)
 		return
 	}()
 
@@ -120,10 +181,34 @@ func (cli *Client) PodDeploy(ctx context.Context, opt *PodDeployOpt) (err error)
 		err = ErrPodDeployTimeout
 	case err = <-errCh:
 	}
-	return
+	if err != nil {
+		return nil, err
+	}
+	return &PodDeployResult{
+		URL:      opt.Ingress.url(opt.Spec.Name, cli.namespace),
+		QOSClass: qosClass(container.Resources.Requests, container.Resources.Limits),
+	}, nil
 }
 
-func waitPodForReady(ctx context.Context, client *kubernetes.Clientset, namespace string, labels map[string]string) (errMsg string, err error) {
+// rolloutPollInterval 是等待滚动发布完成时查询状态的间隔
+var rolloutPollInterval = time.Second
+
+// waitRollout 等待滚动发布完成，并且所有 Pod 都通过就绪探针
+func waitRollout(ctx context.Context, controller PodController) error {
+	err := wait.PollImmediateUntil(rolloutPollInterval, func() (bool, error) {
+		done, err := controller.RolloutDone(ctx)
+		if !done || err != nil {
+			return done, err
+		}
+		return podsReady(ctx, controller)
+	}, ctx.Done())
+	if err == wait.ErrWaitTimeout {
+		return ErrPodDeployTimeout
+	}
+	return err
+}
+
+func waitPodForReady(ctx context.Context, client kubernetes.Interface, namespace string, labels map[string]string) (errMsg string, err error) {
 	listOpt, err := getListOpt(labels)
 	if err != nil {
 		return err.Error(), err
@@ -200,15 +285,16 @@ func getContainer(spec PodSpec) (*apiv1.Container, error) {
 	// 端口
 	container.Ports = getContainerPorts(spec.Ports)
 
-	// 环境变量
+	// 环境变量，按名称排序使 Pod 模板保持稳定
 	var envs []apiv1.EnvVar
-	for k, v := range spec.Envs {
+	for _, k := range sortedKeys(spec.Envs) {
 		envs = append(envs, apiv1.EnvVar{
 			Name:  k,
-			Value: v,
+			Value: spec.Envs[k],
 		})
 	}
-	container.Env = envs
+	container.Env = append(envs, getEnvRefs(spec.EnvRefs)...)
+	container.EnvFrom = getEnvFrom(spec.EnvFrom)
 
 	// workingDir
 	if len(spec.WorkDir) > 0 {
@@ -224,30 +310,37 @@ func getContainer(spec PodSpec) (*apiv1.Container, error) {
 	}
 
 	// quota
-	resourceList, err := spec.Quota.convertResourceList()
+	requests, limits, err := spec.resources()
 	if err != nil {
 		return nil, err
 	}
-	if len(resourceList) > 0 {
-		container.Resources.Limits = resourceList
-		container.Resources.Requests = resourceList
-	}
+	container.Resources.Requests = requests
+	container.Resources.Limits = limits
 
-	// TODO: 持久化
+	// 探针
+	container.ReadinessProbe = spec.ReadinessProbe.convert()
+	container.LivenessProbe = spec.LivenessProbe.convert()
+	container.StartupProbe = spec.StartupProbe.convert()
+
+	// 持久化
+	container.VolumeMounts = getVolumeMounts(spec.Volumes)
 	return container, nil
 }
 
+// name 返回第 i 个端口在容器与 Service 中使用的名称，没有指定名称时为 port-<i>
+func (port Port) name(i int) string {
+	if port.Name != "" {
+		return port.Name
+	}
+	return fmt.Sprintf("port-%d", i)
+}
+
 func getContainerPorts(dbPorts []Port) []apiv1.ContainerPort {
 	var ports []apiv1.ContainerPort
 	for i, port := range dbPorts {
 		p := apiv1.ContainerPort{}
 
-		// 处理端口名称
-		if port.Name != "" {
-			p.Name = port.Name
-		} else {
-			p.Name = fmt.Sprintf("port-%d", i)
-		}
+		p.Name = port.name(i)
 
 		// 处理端口协议，默认为tcp
 		var protocol apiv1.Protocol
@@ -269,7 +362,7 @@ func getContainerPorts(dbPorts []Port) []apiv1.ContainerPort {
 	return ports
 }
 
-func listPodsByLabels(ctx context.Context, client *kubernetes.Clientset, labels map[string]string, namespace string) ([]v1.Pod, error) {
+func listPodsByLabels(ctx context.Context, client kubernetes.Interface, labels map[string]string, namespace string) ([]v1.Pod, error) {
 	selector, err := metav1.LabelSelectorAsSelector(&metav1.LabelSelector{
 		MatchLabels: labels,
 	})
@@ -279,7 +372,7 @@ func listPodsByLabels(ctx context.Context, client *kubernetes.Clientset, labels
 	return listPodsBySelector(ctx, client, selector, namespace)
 }
 
-func listPodsBySelector(ctx context.Context, client *kubernetes.Clientset, selector labels.Selector, namespace string) ([]v1.Pod, error) {
+func listPodsBySelector(ctx context.Context, client kubernetes.Interface, selector labels.Selector, namespace string) ([]v1.Pod, error) {
 	podList, err := client.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
 		LabelSelector: selector.String(),
 	})
@@ -3,6 +3,10 @@ package kube
 import (
 	"context"
 	"testing"
+	"time"
+
+	apiv1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 func TestPodDeploy(t *testing.T) {
@@ -10,13 +14,7 @@ func TestPodDeploy(t *testing.T) {
 		ctx context.Context
 		opt *PodDeployOpt
 	}
-	cli, err := NewClient(context.Background(), &CreateClientOpt{
-		ConfigPath: "",
-		Namespace:  "default",
-	})
-	if err != nil {
-		panic(err.Error())
-	}
+	cli := NewFakeClient(context.Background(), "default")
 	timeS := "2021-8-11-99"
 	tests := []struct {
 		name    string
@@ -32,13 +30,13 @@ func TestPodDeploy(t *testing.T) {
 						"simple": "test",
 						"time":   timeS,
 					},
-					extraLabels: map[string]string{
+					ExtraLabels: map[string]string{
 						"inner": "pod56",
 					},
 					ReplicaNum: 5,
 					Stateful:   false,
 					Duration:   0,
-					spec: PodSpec{
+					Spec: PodSpec{
 						Name:     "simple-test",
 						ImageTag: "harbor.scs.buaa.edu.cn/library/nginx:1.17",
 						Envs:     nil,
@@ -61,9 +59,70 @@ func TestPodDeploy(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := cli.PodDeploy(tt.args.ctx, tt.args.opt); (err != nil) != tt.wantErr {
+			if _, err := cli.PodDeploy(tt.args.ctx, tt.args.opt); (err != nil) != tt.wantErr {
 				t.Errorf("PodDeploy() error = %v, wantErr %v", err, tt.wantErr)
 			}
 		})
 	}
 }
+
+func TestPodDeploy_rollout(t *testing.T) {
+	newOpt := func(stateful bool, image string) *PodDeployOpt {
+		return &PodDeployOpt{
+			Labels:      map[string]string{"app": "web"},
+			ExtraLabels: map[string]string{"inner": "pod56"},
+			ReplicaNum:  2,
+			Stateful:    stateful,
+			Spec: PodSpec{
+				Name:     "web",
+				ImageTag: image,
+				Ports:    []Port{{Name: "http", Port: 80}},
+			},
+		}
+	}
+	for _, stateful := range []bool{false, true} {
+		cli := NewFakeClient(context.Background(), "apps")
+		ctx := context.Background()
+		if _, err := cli.PodDeploy(ctx, newOpt(stateful, "nginx:1.17")); err != nil {
+			t.Fatalf("PodDeploy() error = %v", err)
+		}
+		if _, err := cli.PodDeploy(ctx, newOpt(stateful, "nginx:1.19")); err != nil {
+			t.Fatalf("PodDeploy() update error = %v", err)
+		}
+
+		var controller PodController
+		if stateful {
+			controller = NewStatefulSetController(&apiv1.Container{}, cli.Interface, &DeployOpt{Name: "web", Namespace: "apps", PodLabels: map[string]string{"app": "web", "inner": "pod56"}})
+		} else {
+			controller = NewDeploymentController(&apiv1.Container{}, cli.Interface, &DeployOpt{Name: "web", Namespace: "apps", Labels: map[string]string{"app": "web"}})
+		}
+		if done, err := controller.RolloutDone(ctx); !done || err != nil {
+			t.Errorf("RolloutDone() = %v, %v", done, err)
+		}
+		pods, err := listPodsByLabels(ctx, cli.Interface, map[string]string{"app": "web"}, "apps")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pods) != 2 || pods[0].Spec.Containers[0].Image != "nginx:1.19" || pods[0].Labels["inner"] != "pod56" {
+			t.Errorf("stateful = %v, pods = %+v", stateful, pods)
+		}
+	}
+}
+
+func TestPodDeploy_timeout(t *testing.T) {
+	// 不模拟滚动发布的 fake clientset 中，Deployment 永远不会就绪
+	cli := NewClientWithInterface(context.Background(), fake.NewSimpleClientset(), "apps")
+	rolloutPollInterval = 10 * time.Millisecond
+	defer func() {
+		rolloutPollInterval = time.Second
+	}()
+	_, err := cli.PodDeploy(context.Background(), &PodDeployOpt{
+		Labels:     map[string]string{"app": "web"},
+		ReplicaNum: 1,
+		Duration:   100 * time.Millisecond,
+		Spec:       PodSpec{Name: "web", ImageTag: "nginx:1.17"},
+	})
+	if err != ErrPodDeployTimeout {
+		t.Errorf("PodDeploy() error = %v, want timeout", err)
+	}
+}
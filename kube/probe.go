@@ -0,0 +1,141 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// GRPCHealthProbe 是 gRPC 探针在容器中执行的命令。当前使用的 Kubernetes API 还没有原生的 gRPC 探针，
+// 因此 gRPC 探针被转换为执行 grpc_health_probe 的 exec 探针，镜像中需要包含该命令
+var GRPCHealthProbe = "grpc_health_probe"
+
+// Probe 描述容器的探针，HTTPGet、TCPSocket、Exec、GRPC 只能设置一个，
+// 其余字段为 0 时使用 Kubernetes 的默认值
+type Probe struct {
+	HTTPGet   *HTTPGetProbe
+	TCPSocket *TCPSocketProbe
+	Exec      *ExecProbe
+	GRPC      *GRPCProbe
+
+	InitialDelaySeconds int32
+	PeriodSeconds       int32
+	TimeoutSeconds      int32
+	// SuccessThreshold 对存活探针与启动探针只能为 1
+	SuccessThreshold int32
+	FailureThreshold int32
+}
+
+type HTTPGetProbe struct {
+	// Path 为空时为 /
+	Path string
+	// Port 是端口号或 Spec.Ports 中的端口名称
+	Port string
+	// HTTPS 为 true 时使用 https 且不校验证书
+	HTTPS   bool
+	Headers map[string]string
+}
+
+type TCPSocketProbe struct {
+	// Port 是端口号或 Spec.Ports 中的端口名称
+	Port string
+}
+
+type ExecProbe struct {
+	Command []string
+}
+
+// GRPCProbe 使用 grpc.health.v1 协议检查容器。当前使用的 Kubernetes API 没有原生的 gRPC 探针，
+// 它会被转换为在容器中执行 GRPCHealthProbe 的 exec 探针，
+// 因此镜像中必须包含 grpc_health_probe 命令（https://github.com/grpc-ecosystem/grpc-health-probe），否则探针总是失败
+type GRPCProbe struct {
+	Port int32
+	// Service 是 grpc.health.v1 检查的服务名称，为空时检查整个服务器
+	Service string
+}
+
+func (p *Probe) convert() *v1.Probe {
+	if p == nil {
+		return nil
+	}
+	probe := &v1.Probe{
+		InitialDelaySeconds: p.InitialDelaySeconds,
+		PeriodSeconds:       p.PeriodSeconds,
+		TimeoutSeconds:      p.TimeoutSeconds,
+		SuccessThreshold:    p.SuccessThreshold,
+		FailureThreshold:    p.FailureThreshold,
+	}
+	switch {
+	case p.HTTPGet != nil:
+		action := &v1.HTTPGetAction{
+			Path:   p.HTTPGet.Path,
+			Port:   intstr.Parse(p.HTTPGet.Port),
+			Scheme: v1.URISchemeHTTP,
+		}
+		if action.Path == "" {
+			action.Path = "/"
+		}
+		if p.HTTPGet.HTTPS {
+			action.Scheme = v1.URISchemeHTTPS
+		}
+		for _, k := range sortedKeys(p.HTTPGet.Headers) {
+			action.HTTPHeaders = append(action.HTTPHeaders, v1.HTTPHeader{Name: k, Value: p.HTTPGet.Headers[k]})
+		}
+		probe.HTTPGet = action
+	case p.TCPSocket != nil:
+		probe.TCPSocket = &v1.TCPSocketAction{Port: intstr.Parse(p.TCPSocket.Port)}
+	case p.Exec != nil:
+		probe.Exec = &v1.ExecAction{Command: p.Exec.Command}
+	case p.GRPC != nil:
+		command := []string{GRPCHealthProbe, fmt.Sprintf("-addr=:%d", p.GRPC.Port)}
+		if p.GRPC.Service != "" {
+			command = append(command, "-service="+p.GRPC.Service)
+		}
+		if p.TimeoutSeconds > 0 {
+			// grpc_health_probe 默认 1 秒超时，与探针的超时保持一致
+			command = append(command, fmt.Sprintf("-connect-timeout=%ds", p.TimeoutSeconds), fmt.Sprintf("-rpc-timeout=%ds", p.TimeoutSeconds))
+		}
+		probe.Exec = &v1.ExecAction{Command: command}
+	}
+	return probe
+}
+
+// podReady 判断 Pod 的 Ready 条件，配置了就绪探针时只有探针成功后才为 Ready
+func podReady(pod v1.Pod) bool {
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodReady {
+			return cond.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podsReady 判断 controller 管理的所有未被删除的 Pod 是否都已 Ready
+func podsReady(ctx context.Context, controller PodController) (bool, error) {
+	pods, err := controller.GetPods(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, pod := range pods {
+		if pod.DeletionTimestamp == nil && !podReady(pod) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// probePort 判断 port 是否是合法的端口号或 ports 中存在的端口名称
+func probePort(port string, ports []Port) bool {
+	if n, err := strconv.Atoi(port); err == nil {
+		return n > 0 && n <= 65535
+	}
+	for _, p := range getContainerPorts(ports) {
+		if p.Name == port {
+			return true
+		}
+	}
+	return false
+}
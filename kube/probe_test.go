@@ -0,0 +1,131 @@
+package kube
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestProbe_convert(t *testing.T) {
+	tests := []struct {
+		name  string
+		probe *Probe
+		want  *v1.Probe
+	}{
+		{name: "nil"},
+		{
+			name: "http",
+			probe: &Probe{
+				HTTPGet:          &HTTPGetProbe{Port: "http", HTTPS: true, Headers: map[string]string{"X-B": "2", "X-A": "1"}},
+				PeriodSeconds:    5,
+				FailureThreshold: 3,
+			},
+			want: &v1.Probe{
+				Handler: v1.Handler{HTTPGet: &v1.HTTPGetAction{
+					Path:        "/",
+					Port:        intstr.FromString("http"),
+					Scheme:      v1.URISchemeHTTPS,
+					HTTPHeaders: []v1.HTTPHeader{{Name: "X-A", Value: "1"}, {Name: "X-B", Value: "2"}},
+				}},
+				PeriodSeconds:    5,
+				FailureThreshold: 3,
+			},
+		},
+		{
+			name:  "tcp",
+			probe: &Probe{TCPSocket: &TCPSocketProbe{Port: "5432"}, InitialDelaySeconds: 10},
+			want:  &v1.Probe{Handler: v1.Handler{TCPSocket: &v1.TCPSocketAction{Port: intstr.FromInt(5432)}}, InitialDelaySeconds: 10},
+		},
+		{
+			name:  "exec",
+			probe: &Probe{Exec: &ExecProbe{Command: []string{"pg_isready"}}},
+			want:  &v1.Probe{Handler: v1.Handler{Exec: &v1.ExecAction{Command: []string{"pg_isready"}}}},
+		},
+		{
+			name:  "grpc",
+			probe: &Probe{GRPC: &GRPCProbe{Port: 9090, Service: "api"}, TimeoutSeconds: 2},
+			want: &v1.Probe{
+				Handler:        v1.Handler{Exec: &v1.ExecAction{Command: []string{"grpc_health_probe", "-addr=:9090", "-service=api", "-connect-timeout=2s", "-rpc-timeout=2s"}}},
+				TimeoutSeconds: 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.probe.convert(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("convert() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_waitRollout_readiness(t *testing.T) {
+	rolloutPollInterval = 10 * time.Millisecond
+	defer func() {
+		rolloutPollInterval = time.Second
+	}()
+
+	labels := map[string]string{"app": "web"}
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps", Generation: 1},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		Status:     appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1},
+	}
+	pod := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "apps", Labels: labels},
+		Status:     v1.PodStatus{Conditions: []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}}},
+	}
+	cli := NewFakeClient(context.Background(), "apps", deployment, pod)
+	controller := NewDeploymentController(&v1.Container{}, cli.Interface, &DeployOpt{Name: "web", Namespace: "apps", Labels: labels})
+
+	// 工作负载的状态已经完成，但 Pod 没有通过就绪探针
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := waitRollout(ctx, controller); err != ErrPodDeployTimeout {
+		t.Fatalf("waitRollout() error = %v, want timeout", err)
+	}
+
+	pod.Status.Conditions[0].Status = v1.ConditionTrue
+	if _, err := cli.CoreV1().Pods("apps").UpdateStatus(context.Background(), pod, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitRollout(context.Background(), controller); err != nil {
+		t.Errorf("waitRollout() error = %v", err)
+	}
+}
+
+func TestPodDeploy_probes(t *testing.T) {
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "apps")
+	_, err := cli.PodDeploy(ctx, &PodDeployOpt{
+		Labels:     map[string]string{"app": "web"},
+		ReplicaNum: 2,
+		Spec: PodSpec{
+			Name:           "web",
+			ImageTag:       "nginx:1.17",
+			Ports:          []Port{{Name: "http", Port: 80}},
+			ReadinessProbe: &Probe{HTTPGet: &HTTPGetProbe{Path: "/ready", Port: "http"}},
+			LivenessProbe:  &Probe{TCPSocket: &TCPSocketProbe{Port: "80"}},
+			StartupProbe:   &Probe{Exec: &ExecProbe{Command: []string{"true"}}, FailureThreshold: 30},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	deployment, err := cli.AppsV1().Deployments("apps").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.ReadinessProbe.HTTPGet.Path != "/ready" || container.LivenessProbe.TCPSocket.Port.IntVal != 80 ||
+		container.StartupProbe.FailureThreshold != 30 {
+		t.Errorf("container = %+v", container)
+	}
+}
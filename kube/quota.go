@@ -1,6 +1,7 @@
 package kube
 
 import (
+	"fmt"
 	"strings"
 
 	v1 "k8s.io/api/core/v1"
@@ -9,12 +10,20 @@ import (
 )
 
 type Quota struct {
-	CPU    string
-	Memory string
+	CPU              string
+	Memory           string
+	EphemeralStorage string
+	// Extended 是扩展资源名称到数量的映射，例如 nvidia.com/gpu
+	Extended map[string]string
+}
+
+func (quota Quota) isEmpty() bool {
+	return quota.CPU == "" && quota.Memory == "" && quota.EphemeralStorage == "" && len(quota.Extended) <= 0
 }
 
 func (quota Quota) convertResourceList() (v1.ResourceList, error) {
 	quota.Memory = strings.ReplaceAll(quota.Memory, "g", "G")
+	quota.EphemeralStorage = strings.ReplaceAll(quota.EphemeralStorage, "g", "G")
 	resourceList := make(map[v1.ResourceName]resource.Quantity)
 	if len(quota.CPU) > 0 {
 		quantity, err := resource.ParseQuantity(quota.CPU)
@@ -30,12 +39,31 @@ func (quota Quota) convertResourceList() (v1.ResourceList, error) {
 		}
 		resourceList[v1.ResourceMemory] = quantity
 	}
+	if len(quota.EphemeralStorage) > 0 {
+		quantity, err := resource.ParseQuantity(quota.EphemeralStorage)
+		if err != nil {
+			return nil, err
+		}
+		resourceList[v1.ResourceEphemeralStorage] = quantity
+	}
+	for name, value := range quota.Extended {
+		quantity, err := resource.ParseQuantity(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		resourceList[v1.ResourceName(name)] = quantity
+	}
 	if len(resourceList) <= 0 {
 		return nil, nil
 	}
 	return resourceList, nil
 }
 
+// isExtendedResource 判断 name 是否是 Kubernetes 内置资源以外的扩展资源
+func isExtendedResource(name v1.ResourceName) bool {
+	return strings.Contains(string(name), "/") && !strings.HasPrefix(string(name), v1.ResourceDefaultNamespacePrefix)
+}
+
 func (cli *Client) createQuotaForNS(quota Quota) error {
 	resourceList, err := quota.convertResourceList()
 	if err != nil {
@@ -44,6 +72,14 @@ func (cli *Client) createQuotaForNS(quota Quota) error {
 	if len(resourceList) <= 0 {
 		return nil
 	}
+	// ResourceQuota 中的扩展资源只能限制 requests
+	hard := v1.ResourceList{}
+	for name, quantity := range resourceList {
+		if isExtendedResource(name) {
+			name = v1.DefaultResourceRequestsPrefix + name
+		}
+		hard[name] = quantity
+	}
 	_, err = cli.CoreV1().ResourceQuotas(cli.namespace).Create(
 		cli.Ctx,
 		&v1.ResourceQuota{
@@ -51,7 +87,7 @@ func (cli *Client) createQuotaForNS(quota Quota) error {
 				Name: cli.namespace,
 			},
 			Spec: v1.ResourceQuotaSpec{
-				Hard: resourceList,
+				Hard: hard,
 			},
 		},
 		metav1.CreateOptions{},
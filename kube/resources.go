@@ -0,0 +1,60 @@
+package kube
+
+import (
+	v1 "k8s.io/api/core/v1"
+)
+
+// resources 返回容器的 requests 与 limits。Requests 与 Limits 都为 nil 时，
+// Quota 同时作为两者，与之前的行为相同
+func (spec *PodSpec) resources() (requests, limits v1.ResourceList, err error) {
+	if spec.Requests == nil && spec.Limits == nil {
+		resourceList, err := spec.Quota.convertResourceList()
+		return resourceList, resourceList, err
+	}
+	if spec.Requests != nil {
+		if requests, err = spec.Requests.convertResourceList(); err != nil {
+			return nil, nil, err
+		}
+	}
+	if spec.Limits != nil {
+		if limits, err = spec.Limits.convertResourceList(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return requests, limits, nil
+}
+
+// QOSClass 返回按 spec 部署的 Pod 的 QoS 类型，计算方式与 Kubernetes 相同：
+// 只考虑 CPU 与内存，没有申请任何资源时为 BestEffort，
+// 两者的 limits 都设置且与 requests 相等时为 Guaranteed，其余为 Burstable
+func (spec *PodSpec) QOSClass() (v1.PodQOSClass, error) {
+	requests, limits, err := spec.resources()
+	if err != nil {
+		return "", err
+	}
+	return qosClass(requests, limits), nil
+}
+
+func qosClass(requests, limits v1.ResourceList) v1.PodQOSClass {
+	computeResources := []v1.ResourceName{v1.ResourceCPU, v1.ResourceMemory}
+	bestEffort, guaranteed := true, true
+	for _, name := range computeResources {
+		request, hasRequest := requests[name]
+		limit, hasLimit := limits[name]
+		if (hasRequest && !request.IsZero()) || (hasLimit && !limit.IsZero()) {
+			bestEffort = false
+		}
+		// 只设置 limits 时 Kubernetes 会使用 limits 作为 requests
+		if !hasLimit || limit.IsZero() || (hasRequest && request.Cmp(limit) != 0) {
+			guaranteed = false
+		}
+	}
+	switch {
+	case bestEffort:
+		return v1.PodQOSBestEffort
+	case guaranteed:
+		return v1.PodQOSGuaranteed
+	default:
+		return v1.PodQOSBurstable
+	}
+}
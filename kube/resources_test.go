@@ -0,0 +1,95 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPodSpec_QOSClass(t *testing.T) {
+	tests := []struct {
+		name string
+		spec PodSpec
+		want v1.PodQOSClass
+	}{
+		{name: "no resources", spec: PodSpec{}, want: v1.PodQOSBestEffort},
+		{name: "single quota", spec: PodSpec{Quota: Quota{CPU: "1", Memory: "1g"}}, want: v1.PodQOSGuaranteed},
+		{name: "single quota without memory", spec: PodSpec{Quota: Quota{CPU: "1"}}, want: v1.PodQOSBurstable},
+		{name: "only limits", spec: PodSpec{Limits: &Quota{CPU: "1", Memory: "1g"}}, want: v1.PodQOSGuaranteed},
+		{name: "equal requests and limits", spec: PodSpec{Requests: &Quota{CPU: "1000m", Memory: "1Gi"}, Limits: &Quota{CPU: "1", Memory: "1Gi"}}, want: v1.PodQOSGuaranteed},
+		{name: "requests below limits", spec: PodSpec{Requests: &Quota{CPU: "100m", Memory: "128Mi"}, Limits: &Quota{CPU: "1", Memory: "1Gi"}}, want: v1.PodQOSBurstable},
+		{name: "only requests", spec: PodSpec{Requests: &Quota{CPU: "100m"}}, want: v1.PodQOSBurstable},
+		{name: "only ephemeral storage", spec: PodSpec{Requests: &Quota{EphemeralStorage: "1Gi"}}, want: v1.PodQOSBestEffort},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.spec.QOSClass()
+			if err != nil {
+				t.Fatalf("QOSClass() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("QOSClass() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPodDeploy_resources(t *testing.T) {
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "apps")
+	result, err := cli.PodDeploy(ctx, &PodDeployOpt{
+		Labels:     map[string]string{"app": "train"},
+		ReplicaNum: 1,
+		Spec: PodSpec{
+			Name:     "train",
+			ImageTag: "pytorch/pytorch:1.9.0-cuda10.2-cudnn7-runtime",
+			Requests: &Quota{CPU: "500m", Memory: "1g", EphemeralStorage: "1g"},
+			Limits:   &Quota{CPU: "2", Memory: "4g", EphemeralStorage: "10g", Extended: map[string]string{"nvidia.com/gpu": "1"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if result.QOSClass != v1.PodQOSBurstable {
+		t.Errorf("QOSClass = %v", result.QOSClass)
+	}
+	deployment, err := cli.AppsV1().Deployments("apps").Get(ctx, "train", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := deployment.Spec.Template.Spec.Containers[0].Resources
+	for _, c := range []struct {
+		list v1.ResourceList
+		name v1.ResourceName
+		want string
+	}{
+		{resources.Requests, v1.ResourceCPU, "500m"},
+		{resources.Requests, v1.ResourceEphemeralStorage, "1G"},
+		{resources.Limits, v1.ResourceMemory, "4G"},
+		{resources.Limits, "nvidia.com/gpu", "1"},
+	} {
+		if got := c.list[c.name]; got.Cmp(resource.MustParse(c.want)) != 0 {
+			t.Errorf("%s = %s, want %s", c.name, got.String(), c.want)
+		}
+	}
+	if _, ok := resources.Requests["nvidia.com/gpu"]; ok {
+		t.Errorf("requests should not contain limits only resources: %v", resources.Requests)
+	}
+}
+
+func TestClient_createQuotaForNS_extended(t *testing.T) {
+	cli := NewFakeClient(context.Background(), "lab")
+	if err := cli.createQuotaForNS(Quota{CPU: "8", Extended: map[string]string{"nvidia.com/gpu": "2"}}); err != nil {
+		t.Fatalf("createQuotaForNS() error = %v", err)
+	}
+	quota, err := cli.CoreV1().ResourceQuotas("lab").Get(context.Background(), "lab", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := quota.Spec.Hard["requests.nvidia.com/gpu"]; !ok || len(quota.Spec.Hard) != 2 {
+		t.Errorf("hard = %v", quota.Spec.Hard)
+	}
+}
@@ -2,17 +2,15 @@ package kube
 
 import (
 	"context"
+	"strings"
 	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestClient_EnsureDockerRegistry(t *testing.T) {
-	cli, err := NewClient(context.Background(), &CreateClientOpt{
-		ConfigPath: "",
-		Namespace:  "default",
-	})
-	if err != nil {
-		panic(err.Error())
-	}
+	cli := NewFakeClient(context.Background(), "default")
 	type args struct {
 		ctx context.Context
 		p   *PrivateDockerRegistrySecret
@@ -41,6 +39,18 @@ func TestClient_EnsureDockerRegistry(t *testing.T) {
 			if err := cli.CreateOrUpdateDockerRegistrySecret(tt.args.ctx, tt.args.p); (err != nil) != tt.wantErr {
 				t.Errorf("CreateOrUpdateDockerRegistrySecret() error = %v, wantErr %v", err, tt.wantErr)
 			}
+			// 再次调用时更新已有的 secret
+			tt.args.p.Password = "changed"
+			if err := cli.CreateOrUpdateDockerRegistrySecret(tt.args.ctx, tt.args.p); (err != nil) != tt.wantErr {
+				t.Errorf("CreateOrUpdateDockerRegistrySecret() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			secret, err := cli.CoreV1().Secrets("default").Get(tt.args.ctx, tt.args.p.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if secret.Type != v1.SecretTypeDockerConfigJson || !strings.Contains(secret.StringData[".dockerconfigjson"], `"password":"changed"`) {
+				t.Errorf("secret = %+v", secret)
+			}
 		})
 	}
 }
@@ -0,0 +1,262 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// LabelManagedBy 标记由 loclo 创建的对象，只有带有该 label 的对象才会被更新或删除
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	managedBy      = "loclo"
+)
+
+type ServiceType string
+
+const (
+	ServiceClusterIP    ServiceType = "ClusterIP"
+	ServiceNodePort     ServiceType = "NodePort"
+	ServiceLoadBalancer ServiceType = "LoadBalancer"
+	// ServiceNone 表示不创建 Service，Stateful 部署仍然会创建 headless Service
+	ServiceNone ServiceType = "None"
+)
+
+// ServiceOpt 描述 PodDeploy 为 Spec.Ports 创建的 Service，Service 与 Spec.Name 同名
+type ServiceOpt struct {
+	// Type 为空时为 ClusterIP
+	Type        ServiceType
+	Annotations map[string]string
+}
+
+func isManaged(obj metav1.Object) bool {
+	return obj.GetLabels()[LabelManagedBy] == managedBy
+}
+
+// managedLabels 返回带有 LabelManagedBy 的 labels 副本
+func managedLabels(labels map[string]string) map[string]string {
+	result := map[string]string{LabelManagedBy: managedBy}
+	for k, v := range labels {
+		result[k] = v
+	}
+	return result
+}
+
+func (t ServiceType) exposesNodePort() bool {
+	return t == ServiceNodePort || t == ServiceLoadBalancer
+}
+
+func headlessServiceName(name string) string {
+	return name + "-headless"
+}
+
+// desiredServices 返回 opt 对应的所有 Service，值为 nil 表示同名的 Service 不应存在
+func (opt *PodDeployOpt) desiredServices(namespace string) map[string]*v1.Service {
+	name := opt.Spec.Name
+	services := map[string]*v1.Service{
+		name:                      nil,
+		headlessServiceName(name): nil,
+	}
+
+	var ports []v1.ServicePort
+	for i, port := range getContainerPorts(opt.Spec.Ports) {
+		ports = append(ports, v1.ServicePort{
+			Name:       port.Name,
+			Protocol:   port.Protocol,
+			Port:       port.ContainerPort,
+			TargetPort: intstr.FromString(port.Name),
+			NodePort:   opt.Spec.Ports[i].NodePort,
+		})
+	}
+
+	newService := func(name string) *v1.Service {
+		return &v1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels:    managedLabels(opt.Labels),
+			},
+			Spec: v1.ServiceSpec{
+				Selector: opt.Labels,
+			},
+		}
+	}
+
+	serviceType := opt.Service.Type
+	if serviceType == "" {
+		serviceType = ServiceClusterIP
+	}
+	if serviceType != ServiceNone && len(ports) > 0 {
+		svc := newService(name)
+		svc.Annotations = opt.Service.Annotations
+		svc.Spec.Type = v1.ServiceType(serviceType)
+		svc.Spec.Ports = ports
+		if !serviceType.exposesNodePort() {
+			for i := range svc.Spec.Ports {
+				svc.Spec.Ports[i].NodePort = 0
+			}
+		}
+		services[name] = svc
+	}
+
+	// headless Service 为 StatefulSet 的每个 Pod 提供稳定的 DNS，可以没有端口
+	if opt.Stateful {
+		svc := newService(headlessServiceName(name))
+		svc.Spec.ClusterIP = v1.ClusterIPNone
+		for _, port := range ports {
+			port.NodePort = 0
+			svc.Spec.Ports = append(svc.Spec.Ports, port)
+		}
+		services[svc.Name] = svc
+	}
+	return services
+}
+
+// syncServices 创建或更新 opt 需要的 Service，并删除之前创建但已不再需要的 Service
+func (cli *Client) syncServices(ctx context.Context, opt *PodDeployOpt) error {
+	services := opt.desiredServices(cli.namespace)
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		var err error
+		if svc := services[name]; svc != nil {
+			err = cli.applyService(ctx, svc)
+		} else {
+			err = cli.deleteService(ctx, name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cli *Client) applyService(ctx context.Context, svc *v1.Service) error {
+	services := cli.CoreV1().Services(cli.namespace)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		old, err := services.Get(ctx, svc.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			_, err = services.Create(ctx, svc, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if !isManaged(old) {
+			return fmt.Errorf("service %s already exists and is not managed by %s", svc.Name, managedBy)
+		}
+
+		// clusterIP 创建后不能修改，headless 与普通 Service 之间切换时只能重建
+		if (old.Spec.ClusterIP == v1.ClusterIPNone) != (svc.Spec.ClusterIP == v1.ClusterIPNone) {
+			if err := services.Delete(ctx, svc.Name, metav1.DeleteOptions{}); err != nil && !kerrors.IsNotFound(err) {
+				return err
+			}
+			_, err = services.Create(ctx, svc, metav1.CreateOptions{})
+			return err
+		}
+
+		update := svc.DeepCopy()
+		update.ResourceVersion = old.ResourceVersion
+		update.Spec.ClusterIP = old.Spec.ClusterIP
+		update.Spec.ClusterIPs = old.Spec.ClusterIPs
+		keepNodePorts(update, old)
+		_, err = services.Update(ctx, update, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// keepNodePorts 保留集群已经分配的节点端口，避免每次更新都重新分配
+func keepNodePorts(svc, old *v1.Service) {
+	if !ServiceType(svc.Spec.Type).exposesNodePort() {
+		return
+	}
+	allocated := make(map[string]int32)
+	for _, port := range old.Spec.Ports {
+		allocated[port.Name] = port.NodePort
+	}
+	for i, port := range svc.Spec.Ports {
+		if port.NodePort == 0 {
+			svc.Spec.Ports[i].NodePort = allocated[port.Name]
+		}
+	}
+}
+
+// deleteService 删除由 loclo 创建的 Service，Service 不存在时不返回错误
+func (cli *Client) deleteService(ctx context.Context, name string) error {
+	services := cli.CoreV1().Services(cli.namespace)
+	svc, err := services.Get(ctx, name, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !isManaged(svc) {
+		return nil
+	}
+	err = services.Delete(ctx, name, metav1.DeleteOptions{})
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// PodDelete 删除 PodDeploy 创建的名为 name 的工作负载及其 Service 与 Ingress，幂等。
+// 不是由 loclo 创建的同名对象不会被删除
+func (cli *Client) PodDelete(ctx context.Context, name string) error {
+	if err := cli.deleteWorkloads(ctx, name); err != nil {
+		return err
+	}
+	for _, svc := range []string{name, headlessServiceName(name)} {
+		if err := cli.deleteService(ctx, svc); err != nil {
+			return err
+		}
+	}
+	return cli.deleteIngress(ctx, name)
+}
+
+// deleteWorkloads 删除由 loclo 创建的名为 name 的 Deployment 与 StatefulSet，不存在时不返回错误
+func (cli *Client) deleteWorkloads(ctx context.Context, name string) error {
+	deployments := cli.AppsV1().Deployments(cli.namespace)
+	statefulSets := cli.AppsV1().StatefulSets(cli.namespace)
+	workloads := []struct {
+		get    func() (metav1.Object, error)
+		delete func(opts metav1.DeleteOptions) error
+	}{
+		{
+			get:    func() (metav1.Object, error) { return deployments.Get(ctx, name, metav1.GetOptions{}) },
+			delete: func(opts metav1.DeleteOptions) error { return deployments.Delete(ctx, name, opts) },
+		},
+		{
+			get:    func() (metav1.Object, error) { return statefulSets.Get(ctx, name, metav1.GetOptions{}) },
+			delete: func(opts metav1.DeleteOptions) error { return statefulSets.Delete(ctx, name, opts) },
+		},
+	}
+	deletePolicy := metav1.DeletePropagationForeground
+	for _, workload := range workloads {
+		obj, err := workload.get()
+		if kerrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !isManaged(obj) {
+			continue
+		}
+		err = workload.delete(metav1.DeleteOptions{PropagationPolicy: &deletePolicy})
+		if err != nil && !kerrors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package kube
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestClient_syncServices(t *testing.T) {
+	newOpt := func(stateful bool, service ServiceOpt, ports ...Port) *PodDeployOpt {
+		return &PodDeployOpt{
+			Labels:      map[string]string{"app": "web"},
+			ExtraLabels: map[string]string{"inner": "pod56"},
+			ReplicaNum:  1,
+			Stateful:    stateful,
+			Service:     service,
+			Spec: PodSpec{
+				Name:     "web",
+				ImageTag: "nginx:1.17",
+				Ports:    ports,
+			},
+		}
+	}
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "apps")
+	services := cli.CoreV1().Services("apps")
+	getService := func(name string) *v1.Service {
+		svc, err := services.Get(ctx, name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return svc
+	}
+
+	// 默认为 ClusterIP，选择 Pod 的 label，targetPort 指向容器端口名称
+	if _, err := cli.PodDeploy(ctx, newOpt(false, ServiceOpt{}, Port{Name: "http", Port: 80}, Port{Port: 53, Protocol: "udp"})); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	svc := getService("web")
+	if svc == nil || svc.Spec.Type != v1.ServiceTypeClusterIP || svc.Spec.Selector["app"] != "web" || len(svc.Spec.Ports) != 2 {
+		t.Fatalf("service = %+v", svc)
+	}
+	if p := svc.Spec.Ports[1]; p.Name != "port-1" || p.TargetPort.StrVal != "port-1" || p.Protocol != v1.ProtocolUDP || p.Port != 53 {
+		t.Errorf("port = %+v", p)
+	}
+	if getService(headlessServiceName("web")) != nil {
+		t.Errorf("headless service should only be created for stateful deployments")
+	}
+
+	// 更新为 NodePort 时保留已分配的 clusterIP 与节点端口
+	svc.Spec.ClusterIP = "10.0.0.10"
+	if _, err := services.Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	opt := newOpt(false, ServiceOpt{Type: ServiceNodePort, Annotations: map[string]string{"note": "x"}}, Port{Name: "http", Port: 80, NodePort: 30080}, Port{Name: "admin", Port: 8080})
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	svc = getService("web")
+	if svc.Spec.Type != v1.ServiceTypeNodePort || svc.Spec.ClusterIP != "10.0.0.10" || svc.Spec.Ports[0].NodePort != 30080 || svc.Annotations["note"] != "x" {
+		t.Fatalf("service = %+v", svc)
+	}
+	svc.Spec.Ports[1].NodePort = 31000
+	if _, err := services.Update(ctx, svc, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if svc = getService("web"); svc.Spec.Ports[1].NodePort != 31000 {
+		t.Errorf("allocated node port not kept: %+v", svc.Spec.Ports)
+	}
+
+	// 没有端口时删除 Service
+	if _, err := cli.PodDeploy(ctx, newOpt(false, ServiceOpt{})); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	if getService("web") != nil {
+		t.Errorf("service should be removed when there are no ports")
+	}
+
+	// Stateful 部署使用 headless Service
+	if err := cli.PodDelete(ctx, "web"); err != nil {
+		t.Fatalf("PodDelete() error = %v", err)
+	}
+	if _, err := cli.PodDeploy(ctx, newOpt(true, ServiceOpt{Type: ServiceNone}, Port{Name: "http", Port: 80})); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	headless := getService(headlessServiceName("web"))
+	if headless == nil || headless.Spec.ClusterIP != v1.ClusterIPNone || len(headless.Spec.Ports) != 1 {
+		t.Fatalf("headless service = %+v", headless)
+	}
+	if getService("web") != nil {
+		t.Errorf("service type None should not create a service")
+	}
+	sts, err := cli.AppsV1().StatefulSets("apps").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil || sts.Spec.ServiceName != headless.Name {
+		t.Fatalf("statefulset = %+v, %v", sts, err)
+	}
+
+	// 删除工作负载时一并删除 Service，不删除不是由 loclo 创建的 Service
+	foreign := &v1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web"}}
+	if _, err := services.Create(ctx, foreign, metav1.CreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cli.PodDelete(ctx, "web"); err != nil {
+		t.Fatalf("PodDelete() error = %v", err)
+	}
+	if getService(headlessServiceName("web")) != nil || getService("web") == nil {
+		t.Errorf("PodDelete() removed the wrong services")
+	}
+	if _, err := cli.AppsV1().StatefulSets("apps").Get(ctx, "web", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("statefulset not deleted: %v", err)
+	}
+	if _, err := cli.PodDeploy(ctx, newOpt(false, ServiceOpt{}, Port{Name: "http", Port: 80})); err == nil {
+		t.Errorf("PodDeploy() should not overwrite a foreign service")
+	}
+}
+
+func TestClient_PodDelete_foreignWorkloads(t *testing.T) {
+	ctx := context.Background()
+	cli := NewFakeClient(ctx, "apps",
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps", Labels: map[string]string{"app": "web"}}},
+	)
+	if err := cli.PodDelete(ctx, "web"); err != nil {
+		t.Fatalf("PodDelete() error = %v", err)
+	}
+	if _, err := cli.AppsV1().Deployments("apps").Get(ctx, "web", metav1.GetOptions{}); err != nil {
+		t.Errorf("PodDelete() should not delete a foreign deployment: %v", err)
+	}
+	if _, err := cli.AppsV1().StatefulSets("apps").Get(ctx, "web", metav1.GetOptions{}); err != nil {
+		t.Errorf("PodDelete() should not delete a foreign statefulset: %v", err)
+	}
+
+	// PodDeploy 创建的工作负载带有 LabelManagedBy
+	deployment, err := cli.AppsV1().Deployments("apps").Create(ctx, NewDeploymentController(&v1.Container{}, cli.Interface, &DeployOpt{Name: "api", Namespace: "apps"}).D, metav1.CreateOptions{})
+	if err != nil || !isManaged(deployment) {
+		t.Fatalf("deployment = %+v, %v", deployment, err)
+	}
+	if err := cli.PodDelete(ctx, "api"); err != nil {
+		t.Fatalf("PodDelete() error = %v", err)
+	}
+	if _, err := cli.AppsV1().Deployments("apps").Get(ctx, "api", metav1.GetOptions{}); !kerrors.IsNotFound(err) {
+		t.Errorf("deployment not deleted: %v", err)
+	}
+}
+
+func TestClient_PodDeploy_legacyStatefulSetServiceName(t *testing.T) {
+	ctx := context.Background()
+	// 之前创建的 StatefulSet 没有 serviceName
+	legacy := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps", Labels: managedLabels(map[string]string{"app": "web"})},
+		Spec: appsv1.StatefulSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+		},
+	}
+	cli := NewFakeClient(ctx, "apps", legacy)
+	// 与 API Server 一样拒绝修改 serviceName
+	cli.Interface.(*fake.Clientset).PrependReactor("update", "statefulsets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		sts := action.(k8stesting.UpdateAction).GetObject().(*appsv1.StatefulSet)
+		if sts.Spec.ServiceName != legacy.Spec.ServiceName {
+			return true, nil, kerrors.NewInvalid(appsv1.SchemeGroupVersion.WithKind("StatefulSet").GroupKind(), sts.Name, nil)
+		}
+		return false, nil, nil
+	})
+
+	opt := &PodDeployOpt{
+		Labels:     map[string]string{"app": "web"},
+		ReplicaNum: 1,
+		Stateful:   true,
+		Spec:       PodSpec{Name: "web", ImageTag: "nginx:1.17", Ports: []Port{{Name: "http", Port: 80}}},
+	}
+	if _, err := cli.PodDeploy(ctx, opt); err != nil {
+		t.Fatalf("PodDeploy() error = %v", err)
+	}
+	sts, err := cli.AppsV1().StatefulSets("apps").Get(ctx, "web", metav1.GetOptions{})
+	if err != nil || sts.Spec.ServiceName != "" {
+		t.Errorf("statefulset = %+v, %v, want the original serviceName", sts, err)
+	}
+}
@@ -33,13 +33,23 @@ type DeployOpt struct {
 	ReplicaNum       int32
 	Namespace        string
 	PodLabels        map[string]string
+	PodAnnotations   map[string]string
 	ImagePullSecrets []v1.LocalObjectReference
+	Volumes          []v1.Volume
+	// ServiceName 是 StatefulSet 使用的 headless Service
+	ServiceName string
+	// VolumeClaimTemplates 只用于 StatefulSet
+	VolumeClaimTemplates []v1.PersistentVolumeClaim
+	// Recreate 为 true 时 Deployment 先删除旧的 Pod 再创建新的 Pod，而不是滚动更新
+	Recreate bool
 }
 
 type Port struct {
 	Name     string
 	Protocol string
 	Port     int32
+	// NodePort 是 NodePort/LoadBalancer 类型的 Service 上请求的节点端口，为 0 时由集群分配
+	NodePort int32
 }
 
 type Cmd struct {
@@ -52,4 +62,6 @@ type PodController interface {
 	GetPods(ctx context.Context) ([]v1.Pod, error)
 	Delete(ctx context.Context) error
 	Exists(ctx context.Context) (bool, error)
+	// RolloutDone 判断最近一次更新是否已经完成滚动发布，语义与 kubectl rollout status 相同
+	RolloutDone(ctx context.Context) (bool, error)
 }
@@ -0,0 +1,524 @@
+package kube
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+	v1 "k8s.io/api/core/v1"
+	apivalidation "k8s.io/apimachinery/pkg/api/validation"
+	metav1validation "k8s.io/apimachinery/pkg/apis/meta/v1/validation"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Validate 在部署前检查参数，返回所有字段错误的聚合，参数合法时返回 nil
+func (opt *PodDeployOpt) Validate() error {
+	var errs field.ErrorList
+	if len(opt.Labels) <= 0 {
+		errs = append(errs, field.Required(field.NewPath("Labels"), "used as the workload selector"))
+	}
+	errs = append(errs, metav1validation.ValidateLabels(opt.Labels, field.NewPath("Labels"))...)
+	errs = append(errs, metav1validation.ValidateLabels(opt.ExtraLabels, field.NewPath("ExtraLabels"))...)
+	for _, k := range sortedKeys(opt.ExtraLabels) {
+		// ExtraLabels 覆盖 Labels 会导致 Pod 不再匹配 selector
+		if v, ok := opt.Labels[k]; ok && v != opt.ExtraLabels[k] {
+			errs = append(errs, field.Invalid(field.NewPath("ExtraLabels").Key(k), opt.ExtraLabels[k], "conflicts with Labels"))
+		}
+	}
+	if opt.ReplicaNum < 0 {
+		errs = append(errs, field.Invalid(field.NewPath("ReplicaNum"), opt.ReplicaNum, "must be greater than or equal to 0"))
+	}
+	errs = append(errs, opt.Spec.validate(field.NewPath("Spec"))...)
+	errs = append(errs, opt.validateSharedClaims()...)
+	errs = append(errs, opt.validateService()...)
+	errs = append(errs, opt.validateIngress()...)
+	return errs.ToAggregate()
+}
+
+func (spec *PodSpec) validateResources(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.Requests == nil && spec.Limits == nil {
+		return validateQuota(spec.Quota, fldPath.Child("Quota"))
+	}
+	if !spec.Quota.isEmpty() {
+		errs = append(errs, field.Forbidden(fldPath.Child("Quota"), "may not be used together with Requests or Limits"))
+	}
+
+	var requests, limits v1.ResourceList
+	if spec.Requests != nil {
+		errs = append(errs, validateQuota(*spec.Requests, fldPath.Child("Requests"))...)
+		requests, _ = spec.Requests.convertResourceList()
+	}
+	if spec.Limits != nil {
+		errs = append(errs, validateQuota(*spec.Limits, fldPath.Child("Limits"))...)
+		limits, _ = spec.Limits.convertResourceList()
+	}
+	for _, name := range sortedResourceNames(requests) {
+		request := requests[name]
+		limit, ok := limits[name]
+		// 扩展资源不能超售，Kubernetes 要求设置 limits 且与 requests 相等
+		if isExtendedResource(name) {
+			if !ok || request.Cmp(limit) != 0 {
+				errs = append(errs, field.Invalid(fldPath.Child("Requests").Key(string(name)), request.String(), "must be equal to the limit for extended resources"))
+			}
+			continue
+		}
+		if ok && request.Cmp(limit) > 0 {
+			errs = append(errs, field.Invalid(fldPath.Child("Requests").Key(string(name)), request.String(),
+				fmt.Sprintf("must be less than or equal to the limit %s", limit.String())))
+		}
+	}
+	return errs
+}
+
+func validateQuota(quota Quota, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, name := range sortedKeys(quota.Extended) {
+		if !isExtendedResource(v1.ResourceName(name)) {
+			errs = append(errs, field.Invalid(fldPath.Child("Extended").Key(name), name, "must be a domain-prefixed name outside of kubernetes.io, e.g. nvidia.com/gpu"))
+			continue
+		}
+		for _, msg := range validation.IsQualifiedName(name) {
+			errs = append(errs, field.Invalid(fldPath.Child("Extended").Key(name), name, msg))
+		}
+	}
+	resourceList, err := quota.convertResourceList()
+	if err != nil {
+		return append(errs, field.Invalid(fldPath, quota, err.Error()))
+	}
+	for _, name := range sortedResourceNames(resourceList) {
+		quantity := resourceList[name]
+		if quantity.Sign() < 0 {
+			errs = append(errs, field.Invalid(fldPath.Key(string(name)), quantity.String(), "must be greater than or equal to 0"))
+		}
+		if isExtendedResource(name) && quantity.MilliValue()%1000 != 0 {
+			errs = append(errs, field.Invalid(fldPath.Key(string(name)), quantity.String(), "must be an integer for extended resources"))
+		}
+	}
+	return errs
+}
+
+func sortedResourceNames(resourceList v1.ResourceList) []v1.ResourceName {
+	names := make([]v1.ResourceName, 0, len(resourceList))
+	for name := range resourceList {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return names[i] < names[j]
+	})
+	return names
+}
+
+func validateVolumes(volumes []Volume, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]bool)
+	mountPaths := make(map[string]bool)
+	for i, volume := range volumes {
+		idxPath := fldPath.Index(i)
+		if volume.Name == "" {
+			errs = append(errs, field.Required(idxPath.Child("Name"), ""))
+		} else {
+			for _, msg := range validation.IsDNS1123Label(volume.Name) {
+				errs = append(errs, field.Invalid(idxPath.Child("Name"), volume.Name, msg))
+			}
+		}
+		if names[volume.Name] {
+			errs = append(errs, field.Duplicate(idxPath.Child("Name"), volume.Name))
+		}
+		names[volume.Name] = true
+
+		if !path.IsAbs(volume.MountPath) {
+			errs = append(errs, field.Invalid(idxPath.Child("MountPath"), volume.MountPath, "must be an absolute path"))
+		} else if mountPaths[path.Clean(volume.MountPath)] {
+			errs = append(errs, field.Duplicate(idxPath.Child("MountPath"), volume.MountPath))
+		}
+		mountPaths[path.Clean(volume.MountPath)] = true
+		errs = append(errs, validateRelativePath(volume.SubPath, idxPath.Child("SubPath"))...)
+
+		sources := 0
+		if volume.PVC != nil {
+			sources++
+			if size, err := parseSize(volume.PVC.Size); err != nil || size.Sign() <= 0 {
+				errs = append(errs, field.Invalid(idxPath.Child("PVC", "Size"), volume.PVC.Size, "must be a positive quantity"))
+			}
+			switch volume.PVC.AccessMode {
+			case "", ReadWriteOnce, ReadOnlyMany, ReadWriteMany:
+			default:
+				errs = append(errs, field.NotSupported(idxPath.Child("PVC", "AccessMode"), volume.PVC.AccessMode,
+					[]string{string(ReadWriteOnce), string(ReadOnlyMany), string(ReadWriteMany)}))
+			}
+			if volume.PVC.StorageClass != "" {
+				errs = append(errs, validateDNS1123Subdomain(volume.PVC.StorageClass, idxPath.Child("PVC", "StorageClass"))...)
+			}
+		}
+		if volume.EmptyDir != nil {
+			sources++
+			if volume.EmptyDir.SizeLimit != "" {
+				if _, err := parseSize(volume.EmptyDir.SizeLimit); err != nil {
+					errs = append(errs, field.Invalid(idxPath.Child("EmptyDir", "SizeLimit"), volume.EmptyDir.SizeLimit, err.Error()))
+				}
+			}
+		}
+		if volume.ConfigMap != nil {
+			sources++
+			errs = append(errs, validateDNS1123Subdomain(volume.ConfigMap.Name, idxPath.Child("ConfigMap", "Name"))...)
+			errs = append(errs, validateKeyToPaths(volume.ConfigMap.Items, idxPath.Child("ConfigMap", "Items"))...)
+		}
+		if volume.Secret != nil {
+			sources++
+			errs = append(errs, validateDNS1123Subdomain(volume.Secret.Name, idxPath.Child("Secret", "Name"))...)
+			errs = append(errs, validateKeyToPaths(volume.Secret.Items, idxPath.Child("Secret", "Items"))...)
+		}
+		if sources != 1 {
+			errs = append(errs, field.Invalid(idxPath, volume.Name, "exactly one of PVC, EmptyDir, ConfigMap and Secret must be set"))
+		}
+	}
+	return errs
+}
+
+// validateSharedClaims 检查非 Stateful 部署中由所有副本共享的 PVC，
+// ReadWriteOnce 的卷只能挂载到一个节点上，多个副本会因无法挂载而一直处于 Pending
+func (opt *PodDeployOpt) validateSharedClaims() field.ErrorList {
+	if opt.Stateful || opt.ReplicaNum <= 1 {
+		return nil
+	}
+	var errs field.ErrorList
+	for i, volume := range opt.Spec.Volumes {
+		if volume.PVC != nil && volume.PVC.accessMode() == ReadWriteOnce {
+			errs = append(errs, field.Invalid(field.NewPath("Spec", "Volumes").Index(i).Child("PVC", "AccessMode"), ReadWriteOnce,
+				"a ReadWriteOnce claim cannot be shared by multiple replicas, use ReadWriteMany or a Stateful deployment"))
+		}
+	}
+	return errs
+}
+
+func validateProbe(probe *Probe, ports []Port, successOnce bool, fldPath *field.Path) field.ErrorList {
+	if probe == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	handlers := 0
+	if probe.HTTPGet != nil {
+		handlers++
+		if probe.HTTPGet.Path != "" && !strings.HasPrefix(probe.HTTPGet.Path, "/") {
+			errs = append(errs, field.Invalid(fldPath.Child("HTTPGet", "Path"), probe.HTTPGet.Path, "must start with /"))
+		}
+		if !probePort(probe.HTTPGet.Port, ports) {
+			errs = append(errs, field.Invalid(fldPath.Child("HTTPGet", "Port"), probe.HTTPGet.Port, "must be a port number or the name of a port in Ports"))
+		}
+		for _, k := range sortedKeys(probe.HTTPGet.Headers) {
+			for _, msg := range validation.IsHTTPHeaderName(k) {
+				errs = append(errs, field.Invalid(fldPath.Child("HTTPGet", "Headers").Key(k), k, msg))
+			}
+		}
+	}
+	if probe.TCPSocket != nil {
+		handlers++
+		if !probePort(probe.TCPSocket.Port, ports) {
+			errs = append(errs, field.Invalid(fldPath.Child("TCPSocket", "Port"), probe.TCPSocket.Port, "must be a port number or the name of a port in Ports"))
+		}
+	}
+	if probe.Exec != nil {
+		handlers++
+		if len(probe.Exec.Command) <= 0 {
+			errs = append(errs, field.Required(fldPath.Child("Exec", "Command"), ""))
+		}
+	}
+	if probe.GRPC != nil {
+		handlers++
+		for _, msg := range validation.IsValidPortNum(int(probe.GRPC.Port)) {
+			errs = append(errs, field.Invalid(fldPath.Child("GRPC", "Port"), probe.GRPC.Port, msg))
+		}
+	}
+	if handlers != 1 {
+		errs = append(errs, field.Invalid(fldPath, "", "exactly one of HTTPGet, TCPSocket, Exec and GRPC must be set"))
+	}
+
+	for _, value := range []struct {
+		name  string
+		value int32
+	}{
+		{"InitialDelaySeconds", probe.InitialDelaySeconds},
+		{"PeriodSeconds", probe.PeriodSeconds},
+		{"TimeoutSeconds", probe.TimeoutSeconds},
+		{"SuccessThreshold", probe.SuccessThreshold},
+		{"FailureThreshold", probe.FailureThreshold},
+	} {
+		if value.value < 0 {
+			errs = append(errs, field.Invalid(fldPath.Child(value.name), value.value, "must be greater than or equal to 0"))
+		}
+	}
+	if successOnce && probe.SuccessThreshold > 1 {
+		errs = append(errs, field.Invalid(fldPath.Child("SuccessThreshold"), probe.SuccessThreshold, "must be 1"))
+	}
+	return errs
+}
+
+func validateConfigRef(ref ConfigRef, fldPath *field.Path) field.ErrorList {
+	switch {
+	case ref.ConfigMap != "" && ref.Secret != "":
+		return field.ErrorList{field.Invalid(fldPath, ref, "only one of ConfigMap and Secret may be set")}
+	case ref.ConfigMap != "":
+		return validateDNS1123Subdomain(ref.ConfigMap, fldPath.Child("ConfigMap"))
+	case ref.Secret != "":
+		return validateDNS1123Subdomain(ref.Secret, fldPath.Child("Secret"))
+	default:
+		return field.ErrorList{field.Required(fldPath, "either ConfigMap or Secret is required")}
+	}
+}
+
+func validateKeyToPaths(items map[string]string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, k := range sortedKeys(items) {
+		for _, msg := range validation.IsConfigMapKey(k) {
+			errs = append(errs, field.Invalid(fldPath.Key(k), k, msg))
+		}
+		if items[k] == "" {
+			errs = append(errs, field.Required(fldPath.Key(k), ""))
+		}
+		errs = append(errs, validateRelativePath(items[k], fldPath.Key(k))...)
+	}
+	return errs
+}
+
+func validateRelativePath(p string, fldPath *field.Path) field.ErrorList {
+	if path.IsAbs(p) || p == ".." || strings.HasPrefix(p, "../") || strings.HasSuffix(p, "/..") || strings.Contains(p, "/../") {
+		return field.ErrorList{field.Invalid(fldPath, p, "must be a relative path without '..'")}
+	}
+	return nil
+}
+
+func (opt *PodDeployOpt) validateService() field.ErrorList {
+	var errs field.ErrorList
+	switch opt.Service.Type {
+	case "", ServiceClusterIP, ServiceNodePort, ServiceLoadBalancer, ServiceNone:
+	default:
+		errs = append(errs, field.NotSupported(field.NewPath("Service", "Type"), opt.Service.Type,
+			[]string{string(ServiceClusterIP), string(ServiceNodePort), string(ServiceLoadBalancer), string(ServiceNone)}))
+	}
+	errs = append(errs, apivalidation.ValidateAnnotations(opt.Service.Annotations, field.NewPath("Service", "Annotations"))...)
+	errs = append(errs, opt.validateServiceNames()...)
+
+	nodePorts := make(map[int32]bool)
+	for i, port := range opt.Spec.Ports {
+		if port.NodePort == 0 {
+			continue
+		}
+		fldPath := field.NewPath("Spec", "Ports").Index(i).Child("NodePort")
+		if !opt.Service.Type.exposesNodePort() {
+			errs = append(errs, field.Forbidden(fldPath, "may only be used when Service.Type is NodePort or LoadBalancer"))
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.NodePort)) {
+			errs = append(errs, field.Invalid(fldPath, port.NodePort, msg))
+		}
+		if nodePorts[port.NodePort] {
+			errs = append(errs, field.Duplicate(fldPath, port.NodePort))
+		}
+		nodePorts[port.NodePort] = true
+	}
+	return errs
+}
+
+// validateServiceNames 检查由 Spec.Name 派生的 Service 名称，Service 名称必须是 DNS-1035 label，
+// 比 Spec.Name 要求的 DNS-1123 label 更严格：必须以字母开头，headless Service 的名称还要加上 -headless 后缀
+func (opt *PodDeployOpt) validateServiceNames() field.ErrorList {
+	name := opt.Spec.Name
+	if name == "" || len(validation.IsDNS1123Label(name)) > 0 {
+		// 已经在 Spec.Name 中报告
+		return nil
+	}
+	var errs field.ErrorList
+	fldPath := field.NewPath("Spec", "Name")
+	serviceType := opt.Service.Type
+	if serviceType != ServiceNone && len(opt.Spec.Ports) > 0 {
+		for _, msg := range validation.IsDNS1035Label(name) {
+			errs = append(errs, field.Invalid(fldPath, name, "used as the Service name: "+msg))
+		}
+	}
+	if opt.Stateful {
+		headless := headlessServiceName(name)
+		for _, msg := range validation.IsDNS1035Label(headless) {
+			errs = append(errs, field.Invalid(fldPath, name, fmt.Sprintf("used as the headless Service name %s: %s", headless, msg)))
+		}
+	}
+	return errs
+}
+
+func (spec *PodSpec) validate(fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	if spec.Name == "" {
+		errs = append(errs, field.Required(fldPath.Child("Name"), ""))
+	} else {
+		for _, msg := range validation.IsDNS1123Label(spec.Name) {
+			errs = append(errs, field.Invalid(fldPath.Child("Name"), spec.Name, msg))
+		}
+	}
+
+	if spec.ImageTag == "" {
+		errs = append(errs, field.Required(fldPath.Child("ImageTag"), ""))
+	} else if _, err := reference.ParseNormalizedNamed(spec.ImageTag); err != nil {
+		errs = append(errs, field.Invalid(fldPath.Child("ImageTag"), spec.ImageTag, err.Error()))
+	}
+
+	switch spec.PullPolicy {
+	case "", PullAlways, PullNever, PullIfNotPresent:
+	default:
+		errs = append(errs, field.NotSupported(fldPath.Child("PullPolicy"), spec.PullPolicy,
+			[]string{string(PullAlways), string(PullNever), string(PullIfNotPresent)}))
+	}
+
+	for _, k := range sortedKeys(spec.Envs) {
+		for _, msg := range validation.IsEnvVarName(k) {
+			errs = append(errs, field.Invalid(fldPath.Child("Envs").Key(k), k, msg))
+		}
+	}
+	for i, source := range spec.EnvFrom {
+		idxPath := fldPath.Child("EnvFrom").Index(i)
+		errs = append(errs, validateConfigRef(source.ConfigRef, idxPath)...)
+		if source.Prefix != "" {
+			for _, msg := range validation.IsEnvVarName(source.Prefix) {
+				errs = append(errs, field.Invalid(idxPath.Child("Prefix"), source.Prefix, msg))
+			}
+		}
+	}
+	envNames := make(map[string]bool)
+	for i, ref := range spec.EnvRefs {
+		idxPath := fldPath.Child("EnvRefs").Index(i)
+		for _, msg := range validation.IsEnvVarName(ref.Name) {
+			errs = append(errs, field.Invalid(idxPath.Child("Name"), ref.Name, msg))
+		}
+		if _, ok := spec.Envs[ref.Name]; ok || envNames[ref.Name] {
+			errs = append(errs, field.Duplicate(idxPath.Child("Name"), ref.Name))
+		}
+		envNames[ref.Name] = true
+		errs = append(errs, validateConfigRef(ref.ConfigRef, idxPath)...)
+		for _, msg := range validation.IsConfigMapKey(ref.Key) {
+			errs = append(errs, field.Invalid(idxPath.Child("Key"), ref.Key, msg))
+		}
+	}
+
+	errs = append(errs, validatePorts(spec.Ports, fldPath.Child("Ports"))...)
+	errs = append(errs, validateVolumes(spec.Volumes, fldPath.Child("Volumes"))...)
+	errs = append(errs, validateProbe(spec.ReadinessProbe, spec.Ports, false, fldPath.Child("ReadinessProbe"))...)
+	errs = append(errs, validateProbe(spec.LivenessProbe, spec.Ports, true, fldPath.Child("LivenessProbe"))...)
+	errs = append(errs, validateProbe(spec.StartupProbe, spec.Ports, true, fldPath.Child("StartupProbe"))...)
+
+	errs = append(errs, spec.validateResources(fldPath)...)
+	return errs
+}
+
+func validatePorts(ports []Port, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	names := make(map[string]bool)
+	numbers := make(map[string]bool)
+	for i, port := range ports {
+		idxPath := fldPath.Index(i)
+		if port.Name != "" {
+			for _, msg := range validation.IsValidPortName(port.Name) {
+				errs = append(errs, field.Invalid(idxPath.Child("Name"), port.Name, msg))
+			}
+			if names[port.Name] {
+				errs = append(errs, field.Duplicate(idxPath.Child("Name"), port.Name))
+			}
+			names[port.Name] = true
+		}
+		for _, msg := range validation.IsValidPortNum(int(port.Port)) {
+			errs = append(errs, field.Invalid(idxPath.Child("Port"), port.Port, msg))
+		}
+		protocol := strings.ToLower(port.Protocol)
+		switch protocol {
+		case "", "tcp", "udp", "sctp":
+		default:
+			errs = append(errs, field.NotSupported(idxPath.Child("Protocol"), port.Protocol, []string{"TCP", "UDP", "SCTP"}))
+		}
+		if protocol == "" {
+			protocol = "tcp"
+		}
+		key := fmt.Sprintf("%d/%s", port.Port, protocol)
+		if numbers[key] {
+			errs = append(errs, field.Duplicate(idxPath.Child("Port"), key))
+		}
+		numbers[key] = true
+	}
+	// 没有名称的端口使用自动生成的 port-<i>，不能与其他端口指定的名称重复
+	for i, port := range ports {
+		if port.Name == "" && names[port.name(i)] {
+			errs = append(errs, field.Duplicate(fldPath.Index(i).Child("Name"), port.name(i)))
+		}
+	}
+	return errs
+}
+
+func (opt *PodDeployOpt) validateIngress() field.ErrorList {
+	ingress := opt.Ingress
+	if ingress == nil {
+		return nil
+	}
+	var errs field.ErrorList
+	fldPath := field.NewPath("Ingress")
+
+	switch {
+	case ingress.Host != "":
+		errs = append(errs, validateDNS1123Subdomain(ingress.Host, fldPath.Child("Host"))...)
+	case ingress.Domain != "":
+		errs = append(errs, validateDNS1123Subdomain(ingress.Domain, fldPath.Child("Domain"))...)
+	default:
+		errs = append(errs, field.Required(fldPath.Child("Host"), "either Host or Domain is required"))
+	}
+
+	for i, path := range ingress.Paths {
+		if !strings.HasPrefix(path, "/") {
+			errs = append(errs, field.Invalid(fldPath.Child("Paths").Index(i), path, "must start with /"))
+		}
+	}
+
+	// Ingress 转发到同名 Service，因此端口必须是 Service 中的 TCP 端口
+	if opt.Service.Type == ServiceNone {
+		errs = append(errs, field.Forbidden(fldPath, "requires a Service, Service.Type must not be None"))
+	}
+	if ingress.Port == "" {
+		errs = append(errs, field.Required(fldPath.Child("Port"), ""))
+	} else {
+		found := false
+		for _, port := range getContainerPorts(opt.Spec.Ports) {
+			if port.Name == ingress.Port {
+				found = true
+				if port.Protocol != "TCP" {
+					errs = append(errs, field.Invalid(fldPath.Child("Port"), ingress.Port, "must be a TCP port"))
+				}
+			}
+		}
+		if !found {
+			errs = append(errs, field.NotFound(fldPath.Child("Port"), ingress.Port))
+		}
+	}
+
+	if ingress.ClassName != "" {
+		errs = append(errs, validateDNS1123Subdomain(ingress.ClassName, fldPath.Child("ClassName"))...)
+	}
+	if ingress.TLSSecret != "" {
+		errs = append(errs, validateDNS1123Subdomain(ingress.TLSSecret, fldPath.Child("TLSSecret"))...)
+	}
+	errs = append(errs, apivalidation.ValidateAnnotations(ingress.Annotations, fldPath.Child("Annotations"))...)
+	return errs
+}
+
+func validateDNS1123Subdomain(value string, fldPath *field.Path) field.ErrorList {
+	var errs field.ErrorList
+	for _, msg := range validation.IsDNS1123Subdomain(value) {
+		errs = append(errs, field.Invalid(fldPath, value, msg))
+	}
+	return errs
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
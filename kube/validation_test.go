@@ -0,0 +1,236 @@
+package kube
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPodDeployOpt_Validate(t *testing.T) {
+	valid := func() *PodDeployOpt {
+		return &PodDeployOpt{
+			Labels:      map[string]string{"app": "simple"},
+			ExtraLabels: map[string]string{"inner": "pod56"},
+			ReplicaNum:  1,
+			Spec: PodSpec{
+				Name:     "simple-test",
+				ImageTag: "harbor.scs.buaa.edu.cn/library/nginx:1.17",
+				Envs:     map[string]string{"MODE": "dev"},
+				Ports:    []Port{{Name: "main", Port: 80}, {Port: 53, Protocol: "udp"}},
+				Quota:    Quota{CPU: "1", Memory: "2g"},
+			},
+		}
+	}
+	tests := []struct {
+		name     string
+		modify   func(opt *PodDeployOpt)
+		wantErrs []string
+	}{
+		{name: "valid", modify: func(opt *PodDeployOpt) {}},
+		{
+			name:     "no labels",
+			modify:   func(opt *PodDeployOpt) { opt.Labels = nil },
+			wantErrs: []string{"Labels: Required value"},
+		},
+		{
+			name: "bad labels",
+			modify: func(opt *PodDeployOpt) {
+				opt.Labels["bad key!"] = "v"
+				opt.ExtraLabels["app"] = "other"
+			},
+			wantErrs: []string{"Labels: Invalid value: \"bad key!\"", "ExtraLabels[app]: Invalid value: \"other\": conflicts with Labels"},
+		},
+		{
+			name: "aggregated spec errors",
+			modify: func(opt *PodDeployOpt) {
+				opt.ReplicaNum = -1
+				opt.Spec.Name = "Simple_Test"
+				opt.Spec.ImageTag = "harbor/Library/nginx:1.17"
+				opt.Spec.PullPolicy = "Sometimes"
+				opt.Spec.Envs = map[string]string{"1BAD": "x"}
+				opt.Spec.Ports = []Port{{Name: "main", Port: 0}, {Name: "main", Port: 80, Protocol: "http"}, {Port: 8080}, {Port: 8080, Protocol: "TCP"}}
+				opt.Spec.Quota.CPU = "one"
+			},
+			wantErrs: []string{
+				"ReplicaNum: Invalid value: -1",
+				"Spec.Name: Invalid value: \"Simple_Test\"",
+				"Spec.ImageTag: Invalid value: \"harbor/Library/nginx:1.17\"",
+				"Spec.PullPolicy: Unsupported value: \"Sometimes\"",
+				"Spec.Envs[1BAD]: Invalid value",
+				"Spec.Ports[0].Port: Invalid value: 0",
+				"Spec.Ports[1].Name: Duplicate value: \"main\"",
+				"Spec.Ports[1].Protocol: Unsupported value: \"http\"",
+				"Spec.Ports[3].Port: Duplicate value: \"8080/tcp\"",
+				"Spec.Quota: Invalid value",
+			},
+		},
+		{
+			name: "service",
+			modify: func(opt *PodDeployOpt) {
+				opt.Spec.Ports[0].NodePort = 30080
+				opt.Spec.Ports[1].NodePort = 70000
+				opt.Service.Type = "External"
+			},
+			wantErrs: []string{
+				"Service.Type: Unsupported value: \"External\"",
+				"Spec.Ports[0].NodePort: Forbidden",
+				"Spec.Ports[1].NodePort: Invalid value: 70000",
+			},
+		},
+		{
+			name: "generated port name collides",
+			modify: func(opt *PodDeployOpt) {
+				opt.Spec.Ports = []Port{{Port: 80}, {Name: "port-0", Port: 8080}}
+			},
+			wantErrs: []string{"Spec.Ports[0].Name: Duplicate value: \"port-0\""},
+		},
+		{
+			name:     "service name starts with a digit",
+			modify:   func(opt *PodDeployOpt) { opt.Spec.Name = "1-simple" },
+			wantErrs: []string{"Spec.Name: Invalid value: \"1-simple\": used as the Service name"},
+		},
+		{
+			name: "headless service name too long",
+			modify: func(opt *PodDeployOpt) {
+				opt.Stateful = true
+				opt.Service.Type = ServiceNone
+				opt.Spec.Name = strings.Repeat("a", 60)
+			},
+			wantErrs: []string{"used as the headless Service name " + strings.Repeat("a", 60) + "-headless: must be no more than 63 characters"},
+		},
+		{
+			name: "volumes",
+			modify: func(opt *PodDeployOpt) {
+				opt.Spec.Volumes = []Volume{
+					{Name: "data", MountPath: "/data", PVC: &PVCSource{Size: "10g", AccessMode: "ReadWriteSometimes"}},
+					{Name: "data", MountPath: "/data/", SubPath: "../etc", EmptyDir: &EmptyDirSource{}},
+					{Name: "conf", MountPath: "conf", ConfigMap: &ConfigMapSource{Name: "app"}, Secret: &SecretSource{Name: "app"}},
+					{Name: "cache", MountPath: "/cache", PVC: &PVCSource{Size: "0"}},
+				}
+			},
+			wantErrs: []string{
+				"Spec.Volumes[0].PVC.AccessMode: Unsupported value: \"ReadWriteSometimes\"",
+				"Spec.Volumes[1].Name: Duplicate value: \"data\"",
+				"Spec.Volumes[1].MountPath: Duplicate value: \"/data/\"",
+				"Spec.Volumes[1].SubPath: Invalid value: \"../etc\"",
+				"Spec.Volumes[2].MountPath: Invalid value: \"conf\"",
+				"Spec.Volumes[2]: Invalid value: \"conf\": exactly one of",
+				"Spec.Volumes[3].PVC.Size: Invalid value: \"0\"",
+			},
+		},
+		{
+			name: "shared read write once claim",
+			modify: func(opt *PodDeployOpt) {
+				opt.ReplicaNum = 2
+				opt.Spec.Volumes = []Volume{
+					{Name: "data", MountPath: "/data", PVC: &PVCSource{Size: "10g"}},
+					{Name: "shared", MountPath: "/shared", PVC: &PVCSource{Size: "10g", AccessMode: ReadWriteMany}},
+				}
+			},
+			wantErrs: []string{"Spec.Volumes[0].PVC.AccessMode: Invalid value: \"ReadWriteOnce\": a ReadWriteOnce claim cannot be shared"},
+		},
+		{
+			name: "stateful read write once claims",
+			modify: func(opt *PodDeployOpt) {
+				opt.ReplicaNum = 3
+				opt.Stateful = true
+				opt.Spec.Volumes = []Volume{{Name: "data", MountPath: "/data", PVC: &PVCSource{Size: "10g"}}}
+			},
+		},
+		{
+			name: "config refs",
+			modify: func(opt *PodDeployOpt) {
+				opt.Spec.EnvFrom = []EnvFromSource{{ConfigRef: ConfigRef{ConfigMap: "app", Secret: "app"}}, {Prefix: "1_"}}
+				opt.Spec.EnvRefs = []EnvKeyRef{
+					{Name: "MODE", ConfigRef: ConfigRef{ConfigMap: "app"}, Key: "mode"},
+					{Name: "TOKEN", ConfigRef: ConfigRef{Secret: "app"}, Key: "bad/key"},
+				}
+				opt.Spec.Volumes = []Volume{{Name: "conf", MountPath: "/conf", ConfigMap: &ConfigMapSource{Name: "app", Items: map[string]string{"app.yaml": "../app.yaml"}}}}
+			},
+			wantErrs: []string{
+				"Spec.EnvFrom[0]: Invalid value",
+				"Spec.EnvFrom[1]: Required value",
+				"Spec.EnvFrom[1].Prefix: Invalid value: \"1_\"",
+				"Spec.EnvRefs[0].Name: Duplicate value: \"MODE\"",
+				"Spec.EnvRefs[1].Key: Invalid value: \"bad/key\"",
+				"Spec.Volumes[0].ConfigMap.Items[app.yaml]: Invalid value: \"../app.yaml\"",
+			},
+		},
+		{
+			name: "probes",
+			modify: func(opt *PodDeployOpt) {
+				opt.Spec.ReadinessProbe = &Probe{HTTPGet: &HTTPGetProbe{Path: "healthz", Port: "http"}, PeriodSeconds: -1}
+				opt.Spec.LivenessProbe = &Probe{TCPSocket: &TCPSocketProbe{Port: "main"}, Exec: &ExecProbe{}, SuccessThreshold: 2}
+				opt.Spec.StartupProbe = &Probe{GRPC: &GRPCProbe{}}
+			},
+			wantErrs: []string{
+				"Spec.ReadinessProbe.HTTPGet.Path: Invalid value: \"healthz\"",
+				"Spec.ReadinessProbe.HTTPGet.Port: Invalid value: \"http\"",
+				"Spec.ReadinessProbe.PeriodSeconds: Invalid value: -1",
+				"Spec.LivenessProbe.Exec.Command: Required value",
+				"Spec.LivenessProbe: Invalid value: \"\": exactly one of",
+				"Spec.LivenessProbe.SuccessThreshold: Invalid value: 2",
+				"Spec.StartupProbe.GRPC.Port: Invalid value: 0",
+			},
+		},
+		{
+			name: "requests and limits",
+			modify: func(opt *PodDeployOpt) {
+				opt.Spec.Requests = &Quota{CPU: "2", Memory: "1g", Extended: map[string]string{"nvidia.com/gpu": "1"}}
+				opt.Spec.Limits = &Quota{CPU: "1", Memory: "2g", EphemeralStorage: "-1Gi", Extended: map[string]string{"gpu": "1", "example.com/fpga": "0.5"}}
+			},
+			wantErrs: []string{
+				"Spec.Quota: Forbidden",
+				"Spec.Requests[cpu]: Invalid value: \"2\": must be less than or equal to the limit 1",
+				"Spec.Requests[nvidia.com/gpu]: Invalid value: \"1\": must be equal to the limit",
+				"Spec.Limits.Extended[gpu]: Invalid value: \"gpu\"",
+				"Spec.Limits[ephemeral-storage]: Invalid value: \"-1Gi\"",
+				"Spec.Limits[example.com/fpga]: Invalid value: \"500m\": must be an integer",
+			},
+		},
+		{
+			name: "ingress",
+			modify: func(opt *PodDeployOpt) {
+				opt.Ingress = &IngressOpt{Domain: "apps.example.com", Port: "main", Paths: []string{"/", "api"}}
+			},
+			wantErrs: []string{"Ingress.Paths[1]: Invalid value: \"api\""},
+		},
+		{
+			name: "ingress port",
+			modify: func(opt *PodDeployOpt) {
+				opt.Service.Type = ServiceNone
+				opt.Ingress = &IngressOpt{Host: "Web_App", Port: "port-1", TLSSecret: "tls"}
+			},
+			wantErrs: []string{
+				"Ingress.Host: Invalid value: \"Web_App\"",
+				"Ingress: Forbidden",
+				"Ingress.Port: Invalid value: \"port-1\": must be a TCP port",
+			},
+		},
+		{
+			name:     "ingress without host",
+			modify:   func(opt *PodDeployOpt) { opt.Ingress = &IngressOpt{Port: "admin"} },
+			wantErrs: []string{"Ingress.Host: Required value", "Ingress.Port: Not found: \"admin\""},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opt := valid()
+			tt.modify(opt)
+			err := opt.Validate()
+			if len(tt.wantErrs) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Validate() should fail")
+			}
+			for _, want := range tt.wantErrs {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() error = %v, want %q", err, want)
+				}
+			}
+		})
+	}
+}
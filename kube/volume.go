@@ -0,0 +1,237 @@
+package kube
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Volume 描述挂载到容器中的一个卷，PVC、EmptyDir、ConfigMap、Secret 只能设置一个
+type Volume struct {
+	Name      string
+	MountPath string
+	// SubPath 是卷中被挂载的相对路径，为空时挂载整个卷
+	SubPath  string
+	ReadOnly bool
+
+	PVC       *PVCSource
+	EmptyDir  *EmptyDirSource
+	ConfigMap *ConfigMapSource
+	Secret    *SecretSource
+}
+
+type AccessMode string
+
+const (
+	ReadWriteOnce AccessMode = "ReadWriteOnce"
+	ReadOnlyMany  AccessMode = "ReadOnlyMany"
+	ReadWriteMany AccessMode = "ReadWriteMany"
+)
+
+// PVCSource 描述持久化存储。Stateful 部署中渲染为 volumeClaimTemplates，每个 Pod 有独立的 PVC，
+// StatefulSet 创建后不能再修改；其他部署中渲染为名为 <Spec.Name>-<Volume.Name> 的 PVC，由所有 Pod 共享，
+// 因此 ReadWriteOnce 的 PVC 只能用于单副本，并且使用先删除旧 Pod 的 Recreate 策略更新。
+// PodDelete 不会删除 PVC，重新部署时会继续使用原有的数据
+type PVCSource struct {
+	// StorageClass 为空时使用集群默认的 storage class
+	StorageClass string
+	Size         string
+	// AccessMode 为空时为 ReadWriteOnce
+	AccessMode AccessMode
+}
+
+type EmptyDirSource struct {
+	// Memory 为 true 时使用 tmpfs
+	Memory    bool
+	SizeLimit string
+}
+
+// ConfigMapSource 将 ConfigMap 的键挂载为文件
+type ConfigMapSource struct {
+	Name string
+	// Items 是键到卷中相对路径的映射，为空时挂载所有键，文件名与键相同
+	Items map[string]string
+}
+
+// SecretSource 将 Secret 的键挂载为文件
+type SecretSource struct {
+	Name string
+	// Items 是键到卷中相对路径的映射，为空时挂载所有键，文件名与键相同
+	Items map[string]string
+}
+
+func parseSize(size string) (resource.Quantity, error) {
+	return resource.ParseQuantity(strings.ReplaceAll(size, "g", "G"))
+}
+
+func pvcName(name, volume string) string {
+	return fmt.Sprintf("%s-%s", name, volume)
+}
+
+func getVolumeMounts(volumes []Volume) []v1.VolumeMount {
+	var mounts []v1.VolumeMount
+	for _, volume := range volumes {
+		mounts = append(mounts, v1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: volume.MountPath,
+			SubPath:   volume.SubPath,
+			ReadOnly:  volume.ReadOnly,
+		})
+	}
+	return mounts
+}
+
+func (source *PVCSource) accessMode() AccessMode {
+	if source.AccessMode == "" {
+		return ReadWriteOnce
+	}
+	return source.AccessMode
+}
+
+func (source *PVCSource) claimSpec() (v1.PersistentVolumeClaimSpec, error) {
+	size, err := parseSize(source.Size)
+	if err != nil {
+		return v1.PersistentVolumeClaimSpec{}, err
+	}
+	spec := v1.PersistentVolumeClaimSpec{
+		AccessModes: []v1.PersistentVolumeAccessMode{v1.PersistentVolumeAccessMode(source.accessMode())},
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{v1.ResourceStorage: size},
+		},
+	}
+	if source.StorageClass != "" {
+		storageClass := source.StorageClass
+		spec.StorageClassName = &storageClass
+	}
+	return spec, nil
+}
+
+// podVolumes 返回 Pod 的卷与 StatefulSet 的 volumeClaimTemplates，
+// 非 Stateful 部署中 PVC 卷引用 persistentVolumeClaims 返回的独立 PVC
+func (opt *PodDeployOpt) podVolumes() ([]v1.Volume, []v1.PersistentVolumeClaim, error) {
+	var volumes []v1.Volume
+	var templates []v1.PersistentVolumeClaim
+	for _, volume := range opt.Spec.Volumes {
+		source := v1.VolumeSource{}
+		switch {
+		case volume.PVC != nil:
+			if opt.Stateful {
+				spec, err := volume.PVC.claimSpec()
+				if err != nil {
+					return nil, nil, err
+				}
+				templates = append(templates, v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{Name: volume.Name, Labels: managedLabels(opt.Labels)},
+					Spec:       spec,
+				})
+				continue
+			}
+			source.PersistentVolumeClaim = &v1.PersistentVolumeClaimVolumeSource{ClaimName: pvcName(opt.Spec.Name, volume.Name)}
+		case volume.EmptyDir != nil:
+			source.EmptyDir = &v1.EmptyDirVolumeSource{}
+			if volume.EmptyDir.Memory {
+				source.EmptyDir.Medium = v1.StorageMediumMemory
+			}
+			if volume.EmptyDir.SizeLimit != "" {
+				size, err := parseSize(volume.EmptyDir.SizeLimit)
+				if err != nil {
+					return nil, nil, err
+				}
+				source.EmptyDir.SizeLimit = &size
+			}
+		case volume.ConfigMap != nil:
+			source.ConfigMap = &v1.ConfigMapVolumeSource{
+				LocalObjectReference: v1.LocalObjectReference{Name: volume.ConfigMap.Name},
+				Items:                getKeyToPaths(volume.ConfigMap.Items),
+			}
+		case volume.Secret != nil:
+			source.Secret = &v1.SecretVolumeSource{
+				SecretName: volume.Secret.Name,
+				Items:      getKeyToPaths(volume.Secret.Items),
+			}
+		}
+		volumes = append(volumes, v1.Volume{Name: volume.Name, VolumeSource: source})
+	}
+	return volumes, templates, nil
+}
+
+// sharesReadWriteOnce 判断非 Stateful 部署是否挂载了 ReadWriteOnce 的 PVC。
+// 滚动更新时新旧 Pod 同时存在，调度到其他节点的新 Pod 无法挂载该卷，滚动更新会一直卡住
+func (opt *PodDeployOpt) sharesReadWriteOnce() bool {
+	if opt.Stateful {
+		return false
+	}
+	for _, volume := range opt.Spec.Volumes {
+		if volume.PVC != nil && volume.PVC.accessMode() == ReadWriteOnce {
+			return true
+		}
+	}
+	return false
+}
+
+// persistentVolumeClaims 返回非 Stateful 部署需要的独立 PVC
+func (opt *PodDeployOpt) persistentVolumeClaims(namespace string) ([]*v1.PersistentVolumeClaim, error) {
+	if opt.Stateful {
+		return nil, nil
+	}
+	var claims []*v1.PersistentVolumeClaim
+	for _, volume := range opt.Spec.Volumes {
+		if volume.PVC == nil {
+			continue
+		}
+		spec, err := volume.PVC.claimSpec()
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pvcName(opt.Spec.Name, volume.Name),
+				Namespace: namespace,
+				Labels:    managedLabels(opt.Labels),
+			},
+			Spec: spec,
+		})
+	}
+	return claims, nil
+}
+
+// ensurePVCs 创建不存在的 PVC。PVC 创建后大部分字段不能修改，因此已有的 PVC 只在申请的容量变大时扩容
+func (cli *Client) ensurePVCs(ctx context.Context, opt *PodDeployOpt) error {
+	claims, err := opt.persistentVolumeClaims(cli.namespace)
+	if err != nil {
+		return err
+	}
+	pvcs := cli.CoreV1().PersistentVolumeClaims(cli.namespace)
+	for _, claim := range claims {
+		old, err := pvcs.Get(ctx, claim.Name, metav1.GetOptions{})
+		if kerrors.IsNotFound(err) {
+			if _, err = pvcs.Create(ctx, claim, metav1.CreateOptions{}); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !isManaged(old) {
+			return fmt.Errorf("persistent volume claim %s already exists and is not managed by %s", claim.Name, managedBy)
+		}
+		size := claim.Spec.Resources.Requests[v1.ResourceStorage]
+		if size.Cmp(old.Spec.Resources.Requests[v1.ResourceStorage]) <= 0 {
+			continue
+		}
+		if old.Spec.Resources.Requests == nil {
+			old.Spec.Resources.Requests = v1.ResourceList{}
+		}
+		old.Spec.Resources.Requests[v1.ResourceStorage] = size
+		if _, err = pvcs.Update(ctx, old, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
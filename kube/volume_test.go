@@ -0,0 +1,132 @@
+package kube
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+func TestPodDeploy_volumes(t *testing.T) {
+	newOpt := func(stateful bool, size string) *PodDeployOpt {
+		return &PodDeployOpt{
+			Labels:     map[string]string{"app": "db"},
+			ReplicaNum: 1,
+			Stateful:   stateful,
+			Spec: PodSpec{
+				Name:     "db",
+				ImageTag: "mysql:8",
+				Volumes: []Volume{
+					{Name: "data", MountPath: "/var/lib/mysql", PVC: &PVCSource{StorageClass: "nfs", Size: size}},
+					{Name: "tmp", MountPath: "/tmp", EmptyDir: &EmptyDirSource{Memory: true, SizeLimit: "64Mi"}},
+					{Name: "conf", MountPath: "/etc/mysql/conf.d/my.cnf", SubPath: "my.cnf", ReadOnly: true, ConfigMap: &ConfigMapSource{Name: "db-conf"}},
+					{Name: "certs", MountPath: "/certs", ReadOnly: true, Secret: &SecretSource{Name: "db-certs"}},
+				},
+			},
+		}
+	}
+	ctx := context.Background()
+	// 引用的 ConfigMap 与 Secret 必须存在
+	config := []runtime.Object{
+		&v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "db-conf", Namespace: "apps"}},
+		&v1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "db-certs", Namespace: "apps"}},
+	}
+
+	t.Run("deployment", func(t *testing.T) {
+		cli := NewFakeClient(ctx, "apps", config...)
+		if _, err := cli.PodDeploy(ctx, newOpt(false, "1g")); err != nil {
+			t.Fatalf("PodDeploy() error = %v", err)
+		}
+		deployment, err := cli.AppsV1().Deployments("apps").Get(ctx, "db", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 共享 ReadWriteOnce 的 PVC 时不能滚动更新
+		if deployment.Spec.Strategy.Type != appsv1.RecreateDeploymentStrategyType {
+			t.Errorf("strategy = %+v, want Recreate", deployment.Spec.Strategy)
+		}
+		podSpec := deployment.Spec.Template.Spec
+		mounts := podSpec.Containers[0].VolumeMounts
+		if len(podSpec.Volumes) != 4 || len(mounts) != 4 {
+			t.Fatalf("volumes = %+v, mounts = %+v", podSpec.Volumes, mounts)
+		}
+		if claim := podSpec.Volumes[0].PersistentVolumeClaim; claim == nil || claim.ClaimName != "db-data" {
+			t.Errorf("pvc volume = %+v", podSpec.Volumes[0])
+		}
+		if dir := podSpec.Volumes[1].EmptyDir; dir == nil || dir.Medium != v1.StorageMediumMemory || dir.SizeLimit.String() != "64Mi" {
+			t.Errorf("emptyDir volume = %+v", podSpec.Volumes[1])
+		}
+		if podSpec.Volumes[2].ConfigMap.Name != "db-conf" || podSpec.Volumes[3].Secret.SecretName != "db-certs" {
+			t.Errorf("volumes = %+v", podSpec.Volumes)
+		}
+		if m := mounts[2]; m.Name != "conf" || m.SubPath != "my.cnf" || !m.ReadOnly || m.MountPath != "/etc/mysql/conf.d/my.cnf" {
+			t.Errorf("mount = %+v", m)
+		}
+
+		pvcs := cli.CoreV1().PersistentVolumeClaims("apps")
+		pvc, err := pvcs.Get(ctx, "db-data", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if *pvc.Spec.StorageClassName != "nfs" || pvc.Spec.AccessModes[0] != v1.ReadWriteOnce || !isManaged(pvc) {
+			t.Errorf("pvc = %+v", pvc)
+		}
+
+		// 只扩容，不缩容
+		for _, size := range []string{"2g", "1g"} {
+			if _, err := cli.PodDeploy(ctx, newOpt(false, size)); err != nil {
+				t.Fatalf("PodDeploy() error = %v", err)
+			}
+			if pvc, err = pvcs.Get(ctx, "db-data", metav1.GetOptions{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := pvc.Spec.Resources.Requests[v1.ResourceStorage]; got.Cmp(resource.MustParse("2G")) != 0 {
+				t.Errorf("size = %s after deploying %s", got.String(), size)
+			}
+		}
+
+		// PodDelete 保留 PVC
+		if err := cli.PodDelete(ctx, "db"); err != nil {
+			t.Fatalf("PodDelete() error = %v", err)
+		}
+		if _, err := pvcs.Get(ctx, "db-data", metav1.GetOptions{}); err != nil {
+			t.Errorf("pvc should be kept: %v", err)
+		}
+	})
+
+	t.Run("statefulset", func(t *testing.T) {
+		cli := NewFakeClient(ctx, "apps", config...)
+		if _, err := cli.PodDeploy(ctx, newOpt(true, "1g")); err != nil {
+			t.Fatalf("PodDeploy() error = %v", err)
+		}
+		sts, err := cli.AppsV1().StatefulSets("apps").Get(ctx, "db", metav1.GetOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		templates := sts.Spec.VolumeClaimTemplates
+		if len(templates) != 1 || templates[0].Name != "data" || *templates[0].Spec.StorageClassName != "nfs" {
+			t.Fatalf("volumeClaimTemplates = %+v", templates)
+		}
+		if len(sts.Spec.Template.Spec.Volumes) != 3 || len(sts.Spec.Template.Spec.Containers[0].VolumeMounts) != 4 {
+			t.Errorf("pod spec = %+v", sts.Spec.Template.Spec)
+		}
+		pvcs, err := cli.CoreV1().PersistentVolumeClaims("apps").List(ctx, metav1.ListOptions{})
+		if err != nil || len(pvcs.Items) != 0 {
+			t.Errorf("standalone pvcs = %+v, %v", pvcs, err)
+		}
+
+		// volumeClaimTemplates 不变时可以更新，变化时返回明确的错误
+		if _, err := cli.PodDeploy(ctx, newOpt(true, "1G")); err != nil {
+			t.Fatalf("PodDeploy() error = %v", err)
+		}
+		_, err = cli.PodDeploy(ctx, newOpt(true, "2g"))
+		if err == nil || !strings.Contains(err.Error(), "cannot be changed") {
+			t.Errorf("PodDeploy() error = %v, want volume claim templates change rejected", err)
+		}
+	})
+}